@@ -4,26 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"os/signal"
 	"reflect"
-	"runtime"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/donkeywon/golib/buildinfo"
-	"github.com/donkeywon/golib/consts"
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/log"
 	"github.com/donkeywon/golib/plugin"
 	"github.com/donkeywon/golib/runner"
-	"github.com/donkeywon/golib/util/paths"
+	"github.com/donkeywon/golib/util/handover"
+	"github.com/donkeywon/golib/util/jsons"
 	"github.com/donkeywon/golib/util/reflects"
 	"github.com/donkeywon/golib/util/signals"
 	"github.com/donkeywon/golib/util/v"
 	"github.com/goccy/go-yaml"
-	"github.com/goccy/go-yaml/ast"
-	"github.com/goccy/go-yaml/parser"
 	"github.com/jessevdk/go-flags"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/text/cases"
@@ -32,13 +32,25 @@ import (
 
 type DaemonType string
 
+const (
+	cfgKeyBoot = "boot"
+	cfgKeyLog  = "log"
+)
+
 type Daemon interface {
 	runner.Runner
 	plugin.Plugin
 }
 
+// Readier is an optional interface of Daemon to report it is ready to serve, e.g. listening.
+// Ready is closed after the daemon is ready, daemons of next level are started after it rather than
+// after it is started, and the old process is told to drain on handover after all daemons are ready.
+type Readier interface {
+	Ready() <-chan struct{}
+}
+
 var (
-	_daemonTypes       []DaemonType // dependencies in order
+	_daemonTypes       []DaemonType // in registration order
 	_additionalCfgKeys []string
 	_additionalCfgMap  = make(map[string]any)
 	_b                 *booter
@@ -74,6 +86,7 @@ func SetLogLevel(lvl string) {
 
 // Reg register a Daemon creator and its config creator.
 func Reg(typ DaemonType, creator plugin.Creator[Daemon], cfgCreator plugin.CfgCreator[any]) {
+	plugin.Helper()
 	if !slices.Contains(_daemonTypes, typ) {
 		_daemonTypes = append(_daemonTypes, typ)
 	}
@@ -82,6 +95,9 @@ func Reg(typ DaemonType, creator plugin.Creator[Daemon], cfgCreator plugin.CfgCr
 
 // RegCfg register additional config, cfg type must be pointer.
 func RegCfg(name string, cfg any) {
+	if name == cfgKeyBoot || name == cfgKeyLog {
+		panic("reserved cfg name: " + name)
+	}
 	if _, exists := _additionalCfgMap[name]; exists {
 		panic("duplicate register cfg: " + name)
 	}
@@ -93,10 +109,11 @@ func RegCfg(name string, cfg any) {
 }
 
 func Get[D Daemon](typ DaemonType) D {
-	d, exists := _b.daemonsMap[typ]
+	d, exists := _b.getDaemon(typ)
 	if !exists {
 		panic(fmt.Errorf("daemon %s not exists, register first or get after created", typ))
 	}
+	_b.recordGet(typ)
 	dd, ok := d.(D)
 	if !ok {
 		panic(fmt.Errorf("daemon %s is not type of %s", typ, reflect.TypeOf((*D)(nil)).Elem()))
@@ -105,12 +122,20 @@ func Get[D Daemon](typ DaemonType) D {
 }
 
 type options struct {
-	CfgPath        string `env:"CFG_PATH" description:"config file path"   long:"config"  short:"c"`
-	PrintVersion   bool   `               description:"print version info" long:"version" short:"v"`
+	CfgPath        []string `env:"CFG_PATH"  env-delim:"," description:"config file path, can be specified multiple times, later overrides former" long:"config"       short:"c"`
+	CfgDir         string   `env:"CFG_DIR"                 description:"config directory, files in it are loaded in lexical order after config files"    long:"config-dir"`
+	CfgURL         []string `env:"CFG_URL"   env-delim:"," description:"remote config url, loaded after config files and config directory"             long:"config-url"`
+	PrintConfig    bool     `                              description:"print effective config with the source of each value and exit"                  long:"print-config"`
+	ValidateConfig bool     `                              description:"validate config and exit, exit non-zero and print field errors if invalid"      long:"validate-config"`
+	PrintSchema    bool     `                              description:"print JSON Schema of config and exit"                                          long:"print-schema"`
+	PrintVersion   bool     `                              description:"print version info"                                                            long:"version"      short:"v"`
+	sources        []Source
 	envPrefix      string
 	onConfigLoaded map[DaemonType]OnConfigLoadedFunc
 	onCreated      map[DaemonType]OnCreatedFunc
 	onInitialized  map[DaemonType]OnInitializedFunc
+	supervise      map[DaemonType]*runner.SupervisorCfg
+	dependsOn      map[DaemonType][]DaemonType
 }
 
 func createOptions() *options {
@@ -118,6 +143,8 @@ func createOptions() *options {
 		onConfigLoaded: make(map[DaemonType]OnConfigLoadedFunc),
 		onCreated:      make(map[DaemonType]OnCreatedFunc),
 		onInitialized:  make(map[DaemonType]OnInitializedFunc),
+		supervise:      make(map[DaemonType]*runner.SupervisorCfg),
+		dependsOn:      make(map[DaemonType][]DaemonType),
 	}
 }
 
@@ -125,25 +152,48 @@ type booter struct {
 	runner.Runner
 	*options
 
+	cfg        *Cfg
+	cfgMu      sync.RWMutex
 	cfgMap     map[string]any
+	cfgKeys    []string
+	cfgOrigins map[string]string
 	logCfg     *log.Cfg
 	flagParser *flags.Parser
-
-	daemonsMap map[DaemonType]Daemon
-	errg       *errgroup.Group
+	cmd        *command // active command
+	args       []string // positional args left after flags
+
+	defaultLogCfg log.Cfg
+	reloadMu      sync.Mutex
+	cfgSnapshot   map[string]any // copy of cfg of last load, used to diff on reload
+
+	daemonsMu   sync.RWMutex
+	daemonsMap  map[DaemonType]Daemon
+	initing     DaemonType   // daemon being initialized by initDaemons
+	initGets    []DaemonType // daemons got by Get while initing
+	supervisors map[DaemonType]*runner.Supervisor
+	abandoned   map[DaemonType]chan struct{} // closed if daemon is stuck after stop grace period
+	levels      [][]DaemonType               // daemons sorted by dependencies
+	errg        *errgroup.Group
 }
 
 func create(opt ...Option) *booter {
 	b := &booter{
-		Runner:     runner.Create("boot"),
-		logCfg:     log.NewCfg(),
-		options:    createOptions(),
-		daemonsMap: make(map[DaemonType]Daemon, len(_daemonTypes)),
+		Runner:      runner.Create("boot"),
+		cfg:         NewCfg(),
+		logCfg:      log.NewCfg(),
+		options:     createOptions(),
+		daemonsMap:  make(map[DaemonType]Daemon, len(_daemonTypes)),
+		supervisors: make(map[DaemonType]*runner.Supervisor),
+		abandoned:   make(map[DaemonType]chan struct{}, len(_daemonTypes)),
+	}
+	for _, t := range _daemonTypes {
+		b.abandoned[t] = make(chan struct{})
 	}
 
 	for _, o := range opt {
 		o(b)
 	}
+	b.defaultLogCfg = *b.logCfg
 
 	return b
 }
@@ -154,9 +204,8 @@ func (b *booter) Init() error {
 	// use default logger as temp logger
 	reflects.SetFirstMatchedField(b.Runner, log.Default())
 
-	var cfgKeys []string
-	b.cfgMap, cfgKeys = b.buildCfgMap()
-	b.flagParser, err = buildFlagParser(b.options, b.cfgMap, cfgKeys)
+	b.cfgMap, b.cfgKeys = b.buildCfgMap()
+	b.flagParser, err = buildFlagParser(b.options, b.cfgMap, b.cfgKeys)
 	if err != nil {
 		return errs.Wrap(err, "build flag parser failed")
 	}
@@ -170,18 +219,29 @@ func (b *booter) Init() error {
 		os.Exit(1)
 	}
 	if b.options.PrintVersion {
-		fmt.Fprint(os.Stdout,
-			"Version:"+buildinfo.Version+"\n"+
-				"BuildTime:"+buildinfo.BuildTime+"\n"+
-				"CommitTime:"+buildinfo.CommitTime+"\n"+
-				"Revision:"+buildinfo.Revision+"\n"+
-				"GoVersion:"+runtime.Version()+"\n"+
-				"Arch:"+runtime.GOARCH+"\n")
+		printVersion(os.Stdout)
 		os.Exit(0)
 	}
 
-	err = b.loadCfg()
+	b.cmd = activeCommand(b.flagParser)
+	if b.cmd.skipCfg {
+		os.Exit(b.execCommand(os.Stderr))
+	}
+
+	if b.options.PrintSchema {
+		err = b.printSchema(os.Stdout)
+		if err != nil {
+			return errs.Wrap(err, "print schema failed")
+		}
+		os.Exit(0)
+	}
+
+	b.cfgOrigins, err = b.loadCfg()
 	if err != nil {
+		if b.options.ValidateConfig || b.cmd.run != nil {
+			fmt.Fprintln(os.Stderr, "load config failed:", err)
+			os.Exit(1)
+		}
 		return errs.Wrap(err, "load cfg failed")
 	}
 
@@ -189,10 +249,27 @@ func (b *booter) Init() error {
 		f(b.cfgMap[string(t)])
 	}
 
-	err = b.validateCfg()
+	if b.options.PrintConfig {
+		err = b.printCfg(os.Stdout, b.cfgOrigins)
+		if err != nil {
+			return errs.Wrap(err, "print cfg failed")
+		}
+		os.Exit(0)
+	}
+
+	if b.options.ValidateConfig {
+		os.Exit(b.validateOnly(os.Stdout, os.Stderr))
+	}
+
+	if b.cmd.run != nil {
+		os.Exit(b.execCommand(os.Stderr))
+	}
+
+	err = validateCfgMap(b.cfgMap)
 	if err != nil {
 		return errs.Wrap(err, "validate cfg failed")
 	}
+	b.cfgSnapshot = snapshotCfgMap(b.cfgMap)
 
 	l, err := b.buildLogger()
 	if err != nil {
@@ -213,6 +290,17 @@ func (b *booter) Init() error {
 	b.errg, ctx = errgroup.WithContext(b.Ctx())
 	b.createDaemons(ctx)
 
+	dependsOn, requiredBy := b.declaredDeps()
+	err = checkSupervised(slices.Collect(maps.Keys(b.options.supervise)), expandDeps(_daemonTypes, dependsOn, nil))
+	if err != nil {
+		return errs.Wrap(err, "check supervised daemons failed")
+	}
+	b.levels, err = sortDaemons(_daemonTypes, expandDeps(_daemonTypes, dependsOn, requiredBy))
+	if err != nil {
+		return errs.Wrap(err, "sort daemons failed")
+	}
+	b.Debug("daemon levels", "levels", b.levels)
+
 	err = b.initDaemons()
 	if err != nil {
 		return errs.Wrap(err, "init daemons failed")
@@ -227,29 +315,21 @@ func (b *booter) Init() error {
 }
 
 func (b *booter) Start() error {
-	for _, daemonType := range _daemonTypes {
-		daemon := b.daemonsMap[daemonType]
-		b.errg.Go(func() error {
-			e := runner.Run(daemon)
-			select {
-			case <-b.Ctx().Done():
-				return nil
-			case <-b.Stopping():
-				return nil
-			default:
-			}
-
-			if e != nil {
-				b.Error("daemon failed", e, "daemon", daemon.Name())
-			} else {
-				b.Error("daemon done, should not happen", nil, "daemon", daemon.Name())
-				e = errs.Errorf("daemon %s done, should not happen", daemon.Name())
-			}
-			runner.Stop(b)
-			b.AppendError(e)
-			return e
-		})
+	ready := true
+	for _, level := range b.levels {
+		if !b.startDaemons(level) {
+			ready = false
+			break
+		}
+	}
+	if ready {
+		// tell the old process to drain and exit if started by upgrade handover
+		err := handover.Ready()
+		if err != nil {
+			b.Error("report handover ready failed", err)
+		}
 	}
+	go b.watchCfg()
 
 	termSigCh := make(chan os.Signal, 1)
 	signal.Notify(termSigCh, signals.TermSignals...)
@@ -282,34 +362,229 @@ func (b *booter) Stop() error {
 		return nil
 	default:
 	}
-	for i := len(_daemonTypes) - 1; i >= 0; i-- {
-		runner.StopAndWait(b.daemonsMap[_daemonTypes[i]])
+	for i := len(b.levels) - 1; i >= 0; i-- {
+		b.stopDaemons(b.levels[i])
 	}
 	return nil
 }
 
+// Check report unhealthy if any daemon is not running, e.g. not started yet or failed,
+// implements runner.HealthChecker so that readiness covers daemons without their own checks.
+func (b *booter) Check(context.Context) error {
+	var errss []error
+	for _, daemonType := range _daemonTypes {
+		d, exists := b.getDaemon(daemonType)
+		if !exists {
+			continue
+		}
+		s := d.State()
+		if s == runner.StateRunning {
+			continue
+		}
+		err := errs.Errorf("daemon %s is %s", daemonType, s)
+		if s.Terminal() && d.Err() != nil {
+			err = errors.Join(err, d.Err())
+		}
+		errss = append(errss, err)
+	}
+	return errors.Join(errss...)
+}
+
+// startDaemons start daemons of a level in parallel and wait them started, or ready if they are Readier,
+// return false if booter is stopping.
+func (b *booter) startDaemons(level []DaemonType) bool {
+	daemons := make([]runner.Runner, len(level))
+	for i, daemonType := range level {
+		daemon := b.daemonRunner(daemonType)
+		daemons[i] = daemon
+		abandoned := b.abandoned[daemonType]
+		b.errg.Go(func() error {
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- runner.Run(daemon)
+			}()
+
+			var e error
+			select {
+			case e = <-runErr:
+			case <-abandoned:
+				// stuck on stop, do not block exiting
+				return nil
+			}
+			select {
+			case <-b.Ctx().Done():
+				return nil
+			case <-b.Stopping():
+				return nil
+			default:
+			}
+
+			if e != nil {
+				b.Error("daemon failed", e, "daemon", daemon.Name())
+			} else {
+				b.Error("daemon done, should not happen", nil, "daemon", daemon.Name())
+				e = errs.Errorf("daemon %s done, should not happen", daemon.Name())
+			}
+			runner.Stop(b)
+			b.AppendError(e)
+			return e
+		})
+	}
+
+	for i, daemon := range daemons {
+		select {
+		case <-daemon.Started():
+		case <-b.Stopping():
+			return false
+		}
+		if !b.waitReady(level[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// waitReady wait daemon ready if it is Readier, return false if booter is stopping.
+// A daemon done before ready is not waited, booter is stopped by it unless it is supervised.
+func (b *booter) waitReady(daemonType DaemonType) bool {
+	d, _ := b.getDaemon(daemonType)
+	r, ok := d.(Readier)
+	if !ok {
+		return true
+	}
+	select {
+	case <-r.Ready():
+	case <-d.Done():
+		b.Warn("daemon done before ready", "daemon", daemonType)
+	case <-b.Stopping():
+		return false
+	}
+	return true
+}
+
+// stopDaemons stop daemons of a level in parallel and wait them done.
+func (b *booter) stopDaemons(level []DaemonType) {
+	var wg sync.WaitGroup
+	for _, daemonType := range level {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := runner.StopWithTimeout(b.daemonRunner(daemonType), b.cfg.stopGracePeriod(daemonType))
+			if err != nil {
+				b.Error("stop daemon failed", err, "daemon", daemonType)
+				b.AppendError(err)
+			}
+			if errors.Is(err, runner.ErrStopTimeout) {
+				close(b.abandoned[daemonType])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (b *booter) beginInit(typ DaemonType) {
+	b.daemonsMu.Lock()
+	defer b.daemonsMu.Unlock()
+	b.initing = typ
+	b.initGets = nil
+}
+
+func (b *booter) endInit() []DaemonType {
+	b.daemonsMu.Lock()
+	defer b.daemonsMu.Unlock()
+	gets := b.initGets
+	b.initing, b.initGets = "", nil
+	return gets
+}
+
+// recordGet record daemons got by the daemon being initialized, they must not be supervised.
+func (b *booter) recordGet(typ DaemonType) {
+	b.daemonsMu.Lock()
+	defer b.daemonsMu.Unlock()
+	if b.initing != "" {
+		b.initGets = append(b.initGets, typ)
+	}
+}
+
+func (b *booter) getDaemon(typ DaemonType) (Daemon, bool) {
+	b.daemonsMu.RLock()
+	defer b.daemonsMu.RUnlock()
+	d, exists := b.daemonsMap[typ]
+	return d, exists
+}
+
+func (b *booter) setDaemon(typ DaemonType, d Daemon) {
+	b.daemonsMu.Lock()
+	defer b.daemonsMu.Unlock()
+	b.daemonsMap[typ] = d
+}
+
+// daemonRunner return the supervisor of daemon if supervised, otherwise the daemon itself.
+func (b *booter) daemonRunner(typ DaemonType) runner.Runner {
+	if s, ok := b.supervisors[typ]; ok {
+		return s
+	}
+	d, _ := b.getDaemon(typ)
+	return d
+}
+
+func (b *booter) createDaemon(daemonType DaemonType) Daemon {
+	daemon := plugin.CreateWithCfg[Daemon](daemonType, b.getCfg(string(daemonType)))
+	b.setDaemon(daemonType, daemon)
+
+	onCreated := b.options.onCreated[daemonType]
+	if onCreated != nil {
+		onCreated()
+	}
+	return daemon
+}
+
 func (b *booter) createDaemons(ctx context.Context) {
 	for _, daemonType := range _daemonTypes {
-		daemon := plugin.CreateWithCfg[Daemon](daemonType, b.cfgMap[string(daemonType)])
-		daemon.SetCtx(ctx)
-		daemon.Inherit(b)
-		b.daemonsMap[daemonType] = daemon
-
-		onCreated := b.options.onCreated[daemonType]
-		if onCreated != nil {
-			onCreated()
+		daemon := b.createDaemon(daemonType)
+
+		supervisorCfg, supervised := b.options.supervise[daemonType]
+		if !supervised {
+			daemon.SetCtx(ctx)
+			daemon.Inherit(b)
+			continue
 		}
+
+		// the first instance is created here like others, supervisor recreates it on restart
+		first := daemon
+		s := runner.NewSupervisor(string(daemonType)+"-supervisor", supervisorCfg)
+		s.SetCtx(ctx)
+		s.Inherit(b)
+		s.Add(&runner.ChildSpec{
+			Name:    string(daemonType),
+			Restart: runner.Permanent,
+			Creator: func() runner.Runner {
+				if first != nil {
+					d := first
+					first = nil
+					return d
+				}
+				return b.createDaemon(daemonType)
+			},
+		})
+		b.supervisors[daemonType] = s
 	}
 }
 
 func (b *booter) initDaemons() error {
 	var err error
-	for _, daemonType := range _daemonTypes {
-		daemon := b.daemonsMap[daemonType]
-		err = runner.Init(daemon)
+	for _, daemonType := range slices.Concat(b.levels...) {
+		b.beginInit(daemonType)
+		err = runner.Init(b.daemonRunner(daemonType))
+		gets := b.endInit()
 		if err != nil {
 			return errs.Wrapf(err, "init daemon %s failed", daemonType)
 		}
+		for _, t := range gets {
+			if _, supervised := b.options.supervise[t]; supervised && t != daemonType {
+				return errs.Errorf("daemon %s is supervised but %s gets it on init, what it registered is lost on restart", t, daemonType)
+			}
+		}
 
 		onInitialized := b.options.onInitialized[daemonType]
 		if onInitialized != nil {
@@ -320,60 +595,61 @@ func (b *booter) initDaemons() error {
 }
 
 func (b *booter) loadCfgFromFlags() error {
-	_, err := b.flagParser.Parse()
+	var err error
+	b.args, err = b.flagParser.Parse()
 	return err
 }
 
-func (b *booter) loadCfgFromFile() error {
-	cfgPath := b.options.CfgPath
-	if cfgPath == "" {
-		cfgPath = consts.CfgPath
-		if !paths.FileExist(cfgPath) {
-			return nil
-		}
-	} else if !paths.FileExist(cfgPath) {
-		return errs.Errorf("cfg file not exists: %s", cfgPath)
+// loadCfg load cfg from sources and then env and flags, return origins of values.
+func (b *booter) loadCfg() (map[string]string, error) {
+	sources, err := b.cfgSources()
+	if err != nil {
+		return nil, err
 	}
-
-	f, err := os.ReadFile(cfgPath)
+	origins, err := loadCfgSources(b.Ctx(), sources, b.cfgMap)
 	if err != nil {
-		return errs.Wrap(err, "read cfg file failed")
+		return nil, err
 	}
-
-	af, err := parser.ParseBytes(f, 0)
+	err = b.loadCfgFromFlags()
 	if err != nil {
-		return errs.Wrap(err, "parse cfg file failed")
+		return nil, errs.Wrap(err, "load cfg from flags failed")
 	}
-
-	var (
-		node ast.Node
-		yp   *yaml.Path
-	)
-	for name, cfg := range b.cfgMap {
-		yp, err = yaml.PathString("$." + name)
-		if err != nil {
-			return errs.Wrapf(err, "invalid cfg name: %s", name)
-		}
-		node, err = yp.FilterFile(af)
-		if errors.Is(err, yaml.ErrNotFoundNode) {
-			continue
-		}
-
-		err = yaml.NodeToValue(node, cfg)
-		if err != nil {
-			return errs.Wrapf(err, "unmarshal cfg fail: %s", name)
-		}
+	for path, origin := range flagOrigins(b.flagParser, b.cfgKeys) {
+		setOrigin(origins, path, origin)
 	}
+	return origins, nil
+}
 
-	return nil
+func (b *booter) printSchema(w io.Writer) error {
+	s, err := buildSchema(b.options, b.newCfgMap(), b.cfgKeys)
+	if err != nil {
+		return err
+	}
+	enc := jsons.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
 }
 
-func (b *booter) loadCfg() error {
-	return errors.Join(b.loadCfgFromFile(), b.loadCfgFromFlags())
+// printCfg print cfg in yaml, values are commented with their origins.
+func (b *booter) printCfg(w io.Writer, origins map[string]string) error {
+	doc := make(yaml.MapSlice, 0, len(b.cfgKeys))
+	for _, name := range b.cfgKeys {
+		doc = append(doc, yaml.MapItem{Key: name, Value: b.cfgMap[name]})
+	}
+	cm := make(yaml.CommentMap, len(origins))
+	for path, origin := range origins {
+		cm["$."+path] = []*yaml.Comment{yaml.LineComment(" " + origin)}
+	}
+	bs, err := yaml.MarshalWithOptions(doc, yaml.WithComment(cm))
+	if err != nil {
+		return errs.Wrap(err, "marshal cfg failed")
+	}
+	_, err = fmt.Fprintf(w, "# values without comment are defaults\n%s", bs)
+	return err
 }
 
-func (b *booter) validateCfg() error {
-	for name, cfg := range b.cfgMap {
+func validateCfgMap(cfgMap map[string]any) error {
+	for name, cfg := range cfgMap {
 		if !reflects.IsStructPointer(cfg) {
 			continue
 		}
@@ -385,13 +661,25 @@ func (b *booter) validateCfg() error {
 	return nil
 }
 
+func (b *booter) getCfg(name string) any {
+	b.cfgMu.RLock()
+	defer b.cfgMu.RUnlock()
+	return b.cfgMap[name]
+}
+
+func (b *booter) setCfg(name string, cfg any) {
+	b.cfgMu.Lock()
+	defer b.cfgMu.Unlock()
+	b.cfgMap[name] = cfg
+}
+
 func (b *booter) buildLogger() (log.Logger, error) {
 	return b.logCfg.Build()
 }
 
 func (b *booter) buildCfgMap() (map[string]any, []string) {
-	cfgKeys := make([]string, 0, len(_daemonTypes)+len(_additionalCfgKeys)+1)
-	cfgKeys = append(cfgKeys, "log")
+	cfgKeys := make([]string, 0, len(_daemonTypes)+len(_additionalCfgKeys)+2)
+	cfgKeys = append(cfgKeys, cfgKeyBoot, cfgKeyLog)
 
 	cfgMap := make(map[string]any)
 	for _, daemonType := range _daemonTypes {
@@ -403,7 +691,8 @@ func (b *booter) buildCfgMap() (map[string]any, []string) {
 		cfgMap[name] = cfg
 		cfgKeys = append(cfgKeys, name)
 	}
-	cfgMap["log"] = b.logCfg
+	cfgMap[cfgKeyBoot] = b.cfg
+	cfgMap[cfgKeyLog] = b.logCfg
 	return cfgMap, cfgKeys
 }
 
@@ -420,6 +709,11 @@ func buildFlagParser(base *options, cfgMap map[string]any, cfgKeys []string) (*f
 	}
 	g.EnvNamespace = strings.ToUpper(base.envPrefix)
 
+	err = addCommands(parser)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, name := range cfgKeys {
 		if !reflects.IsStructPointer(cfgMap[name]) {
 			continue
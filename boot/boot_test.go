@@ -0,0 +1,116 @@
+package boot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/donkeywon/golib/log"
+	"github.com/donkeywon/golib/runner"
+	"github.com/donkeywon/golib/util/reflects"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+type stuckDaemon struct {
+	runner.Runner
+	release chan struct{}
+}
+
+func (d *stuckDaemon) Start() error {
+	<-d.release
+	return d.Runner.Start()
+}
+
+func TestStopStuckDaemon(t *testing.T) {
+	b := create()
+	b.SetCtx(context.Background())
+	l, err := log.NewCfg().Build()
+	require.NoError(t, err)
+	reflects.SetFirstMatchedField(b.Runner, l)
+	b.cfg.StopGracePeriod = 10 * time.Millisecond
+
+	d := &stuckDaemon{Runner: runner.Create("stuck"), release: make(chan struct{})}
+	defer close(d.release)
+	d.SetCtx(b.Ctx())
+	d.Inherit(b)
+	b.setDaemon("stuck", d)
+	b.abandoned["stuck"] = make(chan struct{})
+	require.NoError(t, runner.Init(d))
+
+	b.errg, _ = errgroup.WithContext(b.Ctx())
+	require.True(t, b.startDaemons([]DaemonType{"stuck"}))
+	b.stopDaemons([]DaemonType{"stuck"})
+
+	done := make(chan struct{})
+	go func() {
+		b.errg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "wait daemons blocked by stuck daemon")
+	}
+	require.ErrorIs(t, b.Err(), runner.ErrStopTimeout)
+}
+
+type readyDaemon struct {
+	runner.Runner
+	ready chan struct{}
+}
+
+func (d *readyDaemon) Ready() <-chan struct{} {
+	return d.ready
+}
+
+func (d *readyDaemon) Start() error {
+	time.Sleep(50 * time.Millisecond)
+	close(d.ready)
+	<-d.Stopping()
+	return nil
+}
+
+func TestStartDaemonsWaitReady(t *testing.T) {
+	b := create()
+	b.SetCtx(context.Background())
+	l, err := log.NewCfg().Build()
+	require.NoError(t, err)
+	reflects.SetFirstMatchedField(b.Runner, l)
+
+	d := &readyDaemon{Runner: runner.Create("ready"), ready: make(chan struct{})}
+	d.SetCtx(b.Ctx())
+	d.Inherit(b)
+	b.setDaemon("ready", d)
+	b.abandoned["ready"] = make(chan struct{})
+	require.NoError(t, runner.Init(d))
+
+	b.errg, _ = errgroup.WithContext(b.Ctx())
+	require.True(t, b.startDaemons([]DaemonType{"ready"}))
+	select {
+	case <-d.ready:
+	default:
+		require.FailNow(t, "daemon is not ready after started")
+	}
+	b.stopDaemons([]DaemonType{"ready"})
+}
+
+func TestCheckDaemons(t *testing.T) {
+	defer func(types []DaemonType) { _daemonTypes = types }(_daemonTypes)
+	_daemonTypes = []DaemonType{"started", "initialized"}
+
+	b := create()
+	started := runner.Create("started")
+	started.SetCtx(context.Background())
+	require.NoError(t, runner.Init(started))
+	go runner.Start(started)
+	<-started.Started()
+	defer runner.Stop(started)
+	initialized := runner.Create("initialized")
+	initialized.SetCtx(context.Background())
+	require.NoError(t, runner.Init(initialized))
+	b.setDaemon("started", started)
+	b.setDaemon("initialized", initialized)
+
+	require.EqualError(t, b.Check(context.Background()), "daemon initialized is initialized")
+}
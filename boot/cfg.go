@@ -0,0 +1,26 @@
+package boot
+
+import "time"
+
+const (
+	DefaultStopGracePeriod = 30 * time.Second
+)
+
+type Cfg struct {
+	StopGracePeriod       time.Duration            `env:"STOP_GRACE_PERIOD"        long:"stop-grace-period"        yaml:"stopGracePeriod"       description:"grace period to wait each daemon stop before cancel it, 0 means wait forever"`
+	DaemonStopGracePeriod map[string]time.Duration `env:"DAEMON_STOP_GRACE_PERIOD" long:"daemon-stop-grace-period" yaml:"daemonStopGracePeriod" description:"stop grace period of specific daemon, e.g. httpd:10s" env-delim:","`
+	DisableCfgWatch       bool                     `env:"DISABLE_CFG_WATCH"        long:"disable-cfg-watch"        yaml:"disableCfgWatch"       description:"disable reloading cfg on cfg file changes, SIGHUP still works"`
+}
+
+func NewCfg() *Cfg {
+	return &Cfg{
+		StopGracePeriod: DefaultStopGracePeriod,
+	}
+}
+
+func (c *Cfg) stopGracePeriod(typ DaemonType) time.Duration {
+	if d, ok := c.DaemonStopGracePeriod[string(typ)]; ok {
+		return d
+	}
+	return c.StopGracePeriod
+}
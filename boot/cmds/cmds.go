@@ -0,0 +1,162 @@
+// Package cmds register admin commands of task, pipeline and kvs to boot, import it for side effects:
+//
+//	app task submit -f task.yaml
+//	app pipeline run -f pipeline.yaml
+//	app kvs dump -f kvs.yaml
+package cmds
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/donkeywon/golib/boot"
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/kvs"
+	"github.com/donkeywon/golib/pipeline"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/runner"
+	"github.com/donkeywon/golib/task"
+	"github.com/donkeywon/golib/util/interp"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/signals"
+	"github.com/donkeywon/golib/util/yamls"
+	"github.com/goccy/go-yaml"
+)
+
+func init() {
+	Load()
+}
+
+type fileOptions struct {
+	File string `short:"f" long:"file" required:"true" description:"yaml or json file"`
+}
+
+func Load() {
+	taskOpts := &fileOptions{}
+	boot.RegCommand("task submit", "run a task described in file in process and print its result", taskOpts, func([]string) error {
+		return submitTask(taskOpts.File, os.Stdout)
+	})
+	pipelineOpts := &fileOptions{}
+	boot.RegCommand("pipeline run", "run a pipeline described in file and print its result", pipelineOpts, func([]string) error {
+		return runPipeline(pipelineOpts.File, os.Stdout)
+	})
+	kvsOpts := &fileOptions{}
+	boot.RegCommand("kvs dump", "print all key values of kvs described in file", kvsOpts, func([]string) error {
+		return dumpKVS(kvsOpts.File, os.Stdout)
+	})
+}
+
+func submitTask(path string, w io.Writer) error {
+	cfg := task.NewCfg()
+	err := unmarshalFile(path, cfg)
+	if err != nil {
+		return err
+	}
+
+	t := plugin.CreateWithCfg[*task.Task](task.PluginTypeTask, cfg)
+	err = run(t)
+	if err != nil {
+		return err
+	}
+	return printResult(w, t.Result(), t.Err())
+}
+
+func runPipeline(path string, w io.Writer) error {
+	cfg := pipeline.NewCfg()
+	err := unmarshalFile(path, cfg)
+	if err != nil {
+		return err
+	}
+	// only env can be referenced outside of task
+	cfg, err = interp.Copy(cfg, interp.NewResolver(map[string]any{"env": interp.Env}))
+	if err != nil {
+		return errs.Wrapf(err, "resolve reference failed: %s", path)
+	}
+
+	p := plugin.CreateWithCfg[*pipeline.Pipeline](pipeline.PluginTypePipeline, cfg)
+	err = run(p)
+	if err != nil {
+		return err
+	}
+	return printResult(w, p.Result(), p.Err())
+}
+
+func dumpKVS(path string, w io.Writer) error {
+	bs, err := readFileAsJSON(path)
+	if err != nil {
+		return err
+	}
+	cfg := &kvs.Cfg{}
+	err = jsons.Unmarshal(bs, cfg)
+	if err != nil {
+		return errs.Wrapf(err, "unmarshal kvs cfg failed: %s", path)
+	}
+	if cfg.Cfg == nil {
+		return errs.Errorf("kvs type %s is not registered", cfg.Type)
+	}
+
+	s := plugin.CreateWithCfg[kvs.KVS](cfg.Type, cfg.Cfg)
+	err = s.Open()
+	if err != nil {
+		return errs.Wrap(err, "open kvs failed")
+	}
+	defer s.Close()
+
+	all, err := s.LoadAll()
+	if err != nil {
+		return errs.Wrap(err, "load all from kvs failed")
+	}
+	return yamls.NewEncoder(w).Encode(all)
+}
+
+// run r until done or interrupted.
+func run(r runner.Runner) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), slices.Concat(signals.IntSignals, signals.TermSignals)...)
+	defer cancel()
+
+	r.SetCtx(ctx)
+	err := runner.Init(r)
+	if err != nil {
+		return errs.Wrapf(err, "init %s failed", r.Name())
+	}
+	runner.Run(r)
+	return nil
+}
+
+// printResult print result in yaml, return err so that the command exits non-zero if failed.
+func printResult(w io.Writer, result any, err error) error {
+	e := yamls.NewEncoder(w).Encode(result)
+	if e != nil {
+		return errs.Wrap(e, "print result failed")
+	}
+	return err
+}
+
+// unmarshalFile unmarshal yaml or json file, yaml is converted to json first
+// because custom unmarshalers of plugin cfgs only recognize json.
+func unmarshalFile(path string, v any) error {
+	bs, err := readFileAsJSON(path)
+	if err != nil {
+		return err
+	}
+	err = jsons.Unmarshal(bs, v)
+	if err != nil {
+		return errs.Wrapf(err, "unmarshal file failed: %s", path)
+	}
+	return nil
+}
+
+func readFileAsJSON(path string) ([]byte, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.Wrapf(err, "read file failed: %s", path)
+	}
+	bs, err = yaml.YAMLToJSON(bs)
+	if err != nil {
+		return nil, errs.Wrapf(err, "convert file to json failed: %s", path)
+	}
+	return bs, nil
+}
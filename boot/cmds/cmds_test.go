@@ -0,0 +1,25 @@
+package cmds
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitTask(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`id: t1
+type: test
+steps:
+  - type: cmd
+    cfg:
+      command: [echo, hello]
+`), 0o644))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, submitTask(path, buf))
+	require.Contains(t, buf.String(), "hello")
+}
@@ -0,0 +1,183 @@
+package boot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/donkeywon/golib/buildinfo"
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/jessevdk/go-flags"
+)
+
+const cmdRun = "run"
+
+// CommandFunc run a command with the positional args left after flags.
+// Config is loaded before CommandFunc is called and can be got by GetCfg, daemons are not created.
+type CommandFunc func(args []string) error
+
+type command struct {
+	path    string
+	short   string
+	data    any
+	run     CommandFunc
+	skipCfg bool // run before config loaded, for commands not relying on config
+}
+
+var _commands []*command
+
+func init() {
+	regCommand(&command{path: cmdRun, short: "run daemons, it is the default command"})
+	regCommand(&command{path: "version", short: "print version info", skipCfg: true, run: func([]string) error {
+		return printVersion(os.Stdout)
+	}})
+	regCommand(&command{path: "config schema", short: "print JSON Schema of config", skipCfg: true, run: func([]string) error {
+		return _b.printSchema(os.Stdout)
+	}})
+	regCommand(&command{path: "config check", short: "validate config and print field errors if invalid", run: func([]string) error {
+		if _b.validateOnly(os.Stdout, os.Stderr) != 0 {
+			return errs.New("config is invalid")
+		}
+		return nil
+	}})
+	regCommand(&command{path: "plugin list", short: "print registered plugins with their registration sites", skipCfg: true, run: func([]string) error {
+		return printPlugins(os.Stdout)
+	}})
+	regCommand(&command{path: "plugin schema", short: "print JSON Schema of cfgs of registered plugins", skipCfg: true, run: func([]string) error {
+		enc := jsons.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(PluginSchemas())
+	}})
+	regCommand(&command{path: "config print", short: "print effective config with the source of each value", run: func([]string) error {
+		return _b.printCfg(os.Stdout, _b.cfgOrigins)
+	}})
+}
+
+// RegCommand register a subcommand, so the binary can be used as its own admin CLI, e.g. app task submit -f task.yaml.
+// path is words separated by space, data is a struct pointer holding flags of the command and may be nil.
+// Commands are run after config loaded instead of daemons, "run" is the default command which runs daemons.
+// Register a command with the same path again replaces it.
+func RegCommand(path string, short string, data any, f CommandFunc) {
+	if f == nil {
+		panic("nil command func")
+	}
+	regCommand(&command{path: path, short: short, data: data, run: f})
+}
+
+func regCommand(c *command) {
+	c.path = strings.Join(strings.Fields(c.path), " ")
+	if c.path == "" {
+		panic("empty command path")
+	}
+	idx := slices.IndexFunc(_commands, func(cc *command) bool { return cc.path == c.path })
+	if idx >= 0 {
+		_commands[idx] = c
+		return
+	}
+	_commands = append(_commands, c)
+}
+
+// GetCfg return the loaded cfg of daemon or RegCfg, it is mostly used by commands.
+func GetCfg[C any](name string) C {
+	if _b == nil {
+		panic("GetCfg must called after Boot")
+	}
+	cfg, ok := _b.getCfg(name).(C)
+	if !ok {
+		panic(fmt.Errorf("cfg %s is not type of %s", name, reflect.TypeFor[C]()))
+	}
+	return cfg
+}
+
+// addCommands add registered commands to parser, parent words of path are added as commands without flags.
+func addCommands(parser *flags.Parser) error {
+	parser.SubcommandsOptional = true
+	// commands are run by booter after config loaded rather than during parsing
+	parser.CommandHandler = func(flags.Commander, []string) error { return nil }
+
+	for _, c := range _commands {
+		parent := parser.Command
+		words := strings.Fields(c.path)
+		for i, word := range words {
+			sub := parent.Find(word)
+			if sub == nil {
+				var (
+					data  any = &struct{}{}
+					short     = strings.Join(words[:i+1], " ") + " commands"
+					err   error
+				)
+				if i == len(words)-1 {
+					short = c.short
+					if c.data != nil {
+						data = c.data
+					}
+				}
+				sub, err = parent.AddCommand(word, short, short, data)
+				if err != nil {
+					return errs.Wrapf(err, "add command %s failed", c.path)
+				}
+			}
+			parent = sub
+		}
+	}
+	return nil
+}
+
+// activeCommand return the command specified in args, it is run if no command specified.
+func activeCommand(parser *flags.Parser) *command {
+	var words []string
+	for c := parser.Active; c != nil; c = c.Active {
+		words = append(words, c.Name)
+	}
+	path := strings.Join(words, " ")
+	if path == "" {
+		path = cmdRun
+	}
+	for _, c := range _commands {
+		if c.path == path {
+			return c
+		}
+	}
+	return nil
+}
+
+// execCommand run the active command and return the exit code.
+func (b *booter) execCommand(stderr io.Writer) int {
+	err := b.cmd.run(b.args)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s failed: %v\n", b.cmd.path, err)
+		return 1
+	}
+	return 0
+}
+
+func printVersion(w io.Writer) error {
+	_, err := fmt.Fprint(w,
+		"Version:"+buildinfo.Version+"\n"+
+			"BuildTime:"+buildinfo.BuildTime+"\n"+
+			"CommitTime:"+buildinfo.CommitTime+"\n"+
+			"Revision:"+buildinfo.Revision+"\n"+
+			"GoVersion:"+runtime.Version()+"\n"+
+			"Arch:"+runtime.GOARCH+"\n")
+	return err
+}
+
+func printPlugins(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tTYPE\tSITE")
+	for _, e := range plugin.List() {
+		site := e.Site
+		if len(e.Replaced) > 0 {
+			site += " (replaces " + strings.Join(e.Replaced, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%v\t%s\n", e.Kind, e.Type, site)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,34 @@
+package boot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveCommand(t *testing.T) {
+	data := &struct {
+		File string `short:"f" long:"file"`
+	}{}
+	RegCommand("test  sub", "test command", data, func([]string) error { return nil })
+
+	b := create()
+	cfgMap, cfgKeys := b.buildCfgMap()
+	parser, err := buildFlagParser(b.options, cfgMap, cfgKeys)
+	require.NoError(t, err)
+	args, err := parser.ParseArgs([]string{"test", "sub", "-f", "x.yaml", "arg"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"arg"}, args)
+	require.Equal(t, "test sub", activeCommand(parser).path)
+	require.Equal(t, "x.yaml", data.File)
+
+	parser, err = buildFlagParser(b.options, cfgMap, cfgKeys)
+	require.NoError(t, err)
+	_, err = parser.ParseArgs(nil)
+	require.NoError(t, err)
+	require.Equal(t, cmdRun, activeCommand(parser).path)
+	require.Nil(t, activeCommand(parser).run)
+
+	_, err = parser.ParseArgs([]string{"test"})
+	require.Error(t, err)
+}
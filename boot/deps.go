@@ -0,0 +1,137 @@
+package boot
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+// All stands for all other registered daemons in DependsOn and RequiredBy.
+const All DaemonType = "*"
+
+// Dependent is an optional interface of Daemon to declare the daemons it depends on.
+// Dependencies are initialized and started before it and stopped after it.
+// DependsOn is called after config is set, so dependencies can vary with config.
+type Dependent interface {
+	DependsOn() []DaemonType
+}
+
+// Prerequisite is an optional interface of Daemon to declare the daemons depend on it,
+// e.g. upd is RequiredBy All so that it is stopped after all other daemons.
+type Prerequisite interface {
+	RequiredBy() []DaemonType
+}
+
+// sortDaemons sort daemon types by dependencies into levels, daemons in the same level
+// do not depend on each other. Order in each level follows the order of types.
+func sortDaemons(types []DaemonType, deps map[DaemonType][]DaemonType) ([][]DaemonType, error) {
+	for t, ds := range deps {
+		for _, d := range ds {
+			if d == t {
+				return nil, errs.Errorf("daemon %s depends on itself", t)
+			}
+			if !slices.Contains(types, d) {
+				return nil, errs.Errorf("daemon %s depends on %s which is not registered", t, d)
+			}
+		}
+	}
+
+	remaining := slices.Clone(types)
+	var levels [][]DaemonType
+	for len(remaining) > 0 {
+		var level, rest []DaemonType
+		for _, t := range remaining {
+			ready := true
+			for _, d := range deps[t] {
+				if slices.Contains(remaining, d) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				level = append(level, t)
+			} else {
+				rest = append(rest, t)
+			}
+		}
+		if len(level) == 0 {
+			names := make([]string, len(rest))
+			for i, t := range rest {
+				names[i] = string(t)
+			}
+			return nil, errs.Errorf("dependency cycle between daemons: %s", strings.Join(names, ", "))
+		}
+		levels = append(levels, level)
+		remaining = rest
+	}
+	return levels, nil
+}
+
+// checkSupervised return error if a supervised daemon is depended on by others,
+// what they registered into it on init, e.g. routes of httpd, is lost when supervisor recreates it.
+func checkSupervised(supervised []DaemonType, deps map[DaemonType][]DaemonType) error {
+	for t, ds := range deps {
+		for _, d := range ds {
+			if slices.Contains(supervised, d) {
+				return errs.Errorf("daemon %s is supervised but %s depends on it", d, t)
+			}
+		}
+	}
+	return nil
+}
+
+// declaredDeps return dependencies declared by options and Dependent, and those declared by Prerequisite.
+func (b *booter) declaredDeps() (map[DaemonType][]DaemonType, map[DaemonType][]DaemonType) {
+	var (
+		dependsOn  = make(map[DaemonType][]DaemonType, len(_daemonTypes))
+		requiredBy = make(map[DaemonType][]DaemonType, len(_daemonTypes))
+	)
+	for _, t := range _daemonTypes {
+		dependsOn[t] = append(dependsOn[t], b.options.dependsOn[t]...)
+		d, _ := b.getDaemon(t)
+		if dd, ok := d.(Dependent); ok {
+			dependsOn[t] = append(dependsOn[t], dd.DependsOn()...)
+		}
+		if p, ok := d.(Prerequisite); ok {
+			requiredBy[t] = p.RequiredBy()
+		}
+	}
+	return dependsOn, requiredBy
+}
+
+// expandDeps merge dependsOn and requiredBy of types into dependencies, All is expanded.
+func expandDeps(types []DaemonType, dependsOn map[DaemonType][]DaemonType, requiredBy map[DaemonType][]DaemonType) map[DaemonType][]DaemonType {
+	deps := make(map[DaemonType][]DaemonType, len(types))
+	add := func(t DaemonType, d DaemonType) {
+		if !slices.Contains(deps[t], d) {
+			deps[t] = append(deps[t], d)
+		}
+	}
+	for _, t := range types {
+		for _, d := range dependsOn[t] {
+			if d != All {
+				add(t, d)
+				continue
+			}
+			for _, o := range types {
+				// daemons both depend on All are not depend on each other
+				if o != t && !slices.Contains(dependsOn[o], All) {
+					add(t, o)
+				}
+			}
+		}
+		for _, r := range requiredBy[t] {
+			if r != All {
+				add(r, t)
+				continue
+			}
+			for _, o := range types {
+				if o != t && !slices.Contains(requiredBy[o], All) {
+					add(o, t)
+				}
+			}
+		}
+	}
+	return deps
+}
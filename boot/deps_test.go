@@ -0,0 +1,46 @@
+package boot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortDaemons(t *testing.T) {
+	types := []DaemonType{"upd", "dbp", "metricsd", "httpd", "taskd"}
+	levels, err := sortDaemons(types, map[DaemonType][]DaemonType{
+		"dbp":      {"metricsd", "upd"},
+		"metricsd": {"httpd", "upd"},
+		"httpd":    {"upd"},
+		"taskd":    {"upd"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]DaemonType{{"upd"}, {"httpd", "taskd"}, {"metricsd"}, {"dbp"}}, levels)
+
+	_, err = sortDaemons(types, map[DaemonType][]DaemonType{
+		"dbp":      {"metricsd"},
+		"metricsd": {"dbp"},
+	})
+	require.ErrorContains(t, err, "dependency cycle between daemons: dbp, metricsd")
+
+	_, err = sortDaemons(types, map[DaemonType][]DaemonType{"dbp": {"profd"}})
+	require.ErrorContains(t, err, "not registered")
+}
+
+func TestCheckSupervised(t *testing.T) {
+	deps := expandDeps([]DaemonType{"httpd", "metricsd", "dbp"}, map[DaemonType][]DaemonType{
+		"metricsd": {"httpd"},
+		"dbp":      {All},
+	}, nil)
+	require.NoError(t, checkSupervised(nil, deps))
+	require.ErrorContains(t, checkSupervised([]DaemonType{"httpd"}, deps), "daemon httpd is supervised but")
+	require.NoError(t, checkSupervised([]DaemonType{"dbp"}, deps))
+
+	b := create(Supervise("httpd", nil))
+	b.recordGet("httpd")
+	b.beginInit("upd")
+	b.recordGet("httpd")
+	require.Equal(t, []DaemonType{"httpd"}, b.endInit())
+	b.recordGet("taskd")
+	require.Empty(t, b.initGets)
+}
@@ -1,6 +1,9 @@
 package boot
 
-import "github.com/donkeywon/golib/log"
+import (
+	"github.com/donkeywon/golib/log"
+	"github.com/donkeywon/golib/runner"
+)
 
 type OnConfigLoadedFunc func(any)
 type OnCreatedFunc func()
@@ -8,9 +11,24 @@ type OnInitializedFunc func()
 
 type Option func(*booter)
 
-func CfgPath(cfgPath string) Option {
+// CfgPath add cfg files, it is overridden by --config.
+func CfgPath(cfgPath ...string) Option {
 	return func(b *booter) {
-		b.options.CfgPath = cfgPath
+		b.options.CfgPath = append(b.options.CfgPath, cfgPath...)
+	}
+}
+
+// CfgDir set cfg directory, it is overridden by --config-dir.
+func CfgDir(dir string) Option {
+	return func(b *booter) {
+		b.options.CfgDir = dir
+	}
+}
+
+// CfgSource add cfg sources which are loaded after --config-url.
+func CfgSource(src ...Source) Option {
+	return func(b *booter) {
+		b.options.sources = append(b.options.sources, src...)
 	}
 }
 
@@ -43,3 +61,23 @@ func OnInitialized(t DaemonType, f OnInitializedFunc) Option {
 		b.options.onInitialized[t] = f
 	}
 }
+
+// Supervise run daemon under a runner.Supervisor, the daemon will be recreated
+// and restarted when it exits instead of stopping the whole process.
+// A daemon which others depend on or get on init, e.g. httpd that others register routes into,
+// can not be supervised, boot fails on init because what they registered is lost on restart.
+func Supervise(t DaemonType, cfg *runner.SupervisorCfg) Option {
+	return func(b *booter) {
+		if cfg == nil {
+			cfg = runner.NewSupervisorCfg()
+		}
+		b.options.supervise[t] = cfg
+	}
+}
+
+// DependsOn declare dependencies of daemon in addition to those declared by Dependent.
+func DependsOn(t DaemonType, deps ...DaemonType) Option {
+	return func(b *booter) {
+		b.options.dependsOn[t] = append(b.options.dependsOn[t], deps...)
+	}
+}
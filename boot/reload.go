@@ -0,0 +1,290 @@
+package boot
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/log"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/util/reflects"
+	"github.com/donkeywon/golib/util/signals"
+	"github.com/fsnotify/fsnotify"
+)
+
+// cfgReloadDebounce merge file events in a short period, editors may write a file several times on save.
+const cfgReloadDebounce = 200 * time.Millisecond
+
+// Reloadable is an optional interface of Daemon to apply changed config without restart.
+// Reload is called with the new validated config when the config of daemon changed,
+// it should return error without applying anything if some changed fields are not reloadable.
+// The daemon should keep newCfg on success, it is also used when the daemon is recreated.
+type Reloadable interface {
+	Reload(newCfg any) error
+}
+
+// Reload re-read config sources, env and flags, then apply the changed config to daemons.
+// Config is also reloaded on SIGHUP and cfg file changes.
+func Reload() error {
+	if _b == nil {
+		panic("Reload must called after Boot")
+	}
+	return _b.reload()
+}
+
+func (b *booter) reload() error {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	cfgMap := b.newCfgMap()
+	opts := *b.options
+	flagParser, err := buildFlagParser(&opts, cfgMap, b.cfgKeys)
+	if err != nil {
+		return errs.Wrap(err, "build flag parser failed")
+	}
+	sources, err := b.cfgSources()
+	if err != nil {
+		return err
+	}
+	_, err = loadCfgSources(b.Ctx(), sources, cfgMap)
+	if err != nil {
+		return err
+	}
+	_, err = flagParser.Parse()
+	if err != nil {
+		return errs.Wrap(err, "load cfg from flags failed")
+	}
+	err = validateCfgMap(cfgMap)
+	if err != nil {
+		return errs.Wrap(err, "validate cfg failed")
+	}
+
+	var reloadErrs []error
+	snapshot := snapshotCfgMap(cfgMap)
+	for _, name := range b.cfgKeys {
+		if reflect.DeepEqual(snapshot[name], b.cfgSnapshot[name]) {
+			continue
+		}
+		err = b.applyCfg(name, cfgMap[name])
+		if name == cfgKeyLog {
+			// level is applied even if other fields are not reloadable, snapshot what is in effect
+			// so that the level is not applied again and only the other fields are reported
+			b.cfgSnapshot[name] = reflects.DeepCopy(b.getCfg(name))
+		}
+		if err != nil {
+			b.Warn("cfg changed but not applied", "name", name, "err", err)
+			reloadErrs = append(reloadErrs, err)
+			continue
+		}
+		b.cfgSnapshot[name] = snapshot[name]
+		b.Info("cfg reloaded", "name", name)
+	}
+	return errors.Join(reloadErrs...)
+}
+
+func (b *booter) applyCfg(name string, newCfg any) error {
+	if name == cfgKeyLog {
+		return b.reloadLogCfg(newCfg.(*log.Cfg))
+	}
+
+	d, exists := b.getDaemon(DaemonType(name))
+	if !exists {
+		return errs.Errorf("cfg %s is not reloadable, restart required", name)
+	}
+	r, ok := d.(Reloadable)
+	if !ok {
+		return errs.Errorf("daemon %s is not reloadable, restart required", name)
+	}
+	err := r.Reload(newCfg)
+	if err != nil {
+		return errs.Wrapf(err, "reload daemon %s failed", name)
+	}
+	b.setCfg(name, newCfg)
+	return nil
+}
+
+// reloadLogCfg apply log level, other log fields are not reloadable.
+func (b *booter) reloadLogCfg(newCfg *log.Cfg) error {
+	b.cfgMu.Lock()
+	cur := *b.logCfg
+	if newCfg.Level != cur.Level {
+		b.SetLogLevel(newCfg.Level)
+		cur.Level = newCfg.Level
+		// replace rather than modify, the old one may be read by others
+		b.logCfg = &cur
+		b.cfgMap[cfgKeyLog] = b.logCfg
+	}
+	b.cfgMu.Unlock()
+	changed := changedFields(&cur, newCfg)
+	if len(changed) > 0 {
+		return errs.Errorf("log %s not reloadable, restart required", strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// changedFields return yaml names of fields which are different between a and b.
+func changedFields(a, b any) []string {
+	av, bv := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var changed []string
+	for i := range av.NumField() {
+		if reflect.DeepEqual(av.Field(i).Interface(), bv.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(av.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "" {
+			name = av.Type().Field(i).Name
+		}
+		changed = append(changed, name)
+	}
+	return changed
+}
+
+// newCfgMap create cfg with default values to load into, additional cfgs have no
+// creator so they are copied from current.
+func (b *booter) newCfgMap() map[string]any {
+	cfgMap := make(map[string]any, len(b.cfgKeys))
+	for _, daemonType := range _daemonTypes {
+		cfgMap[string(daemonType)] = plugin.CreateCfg[any](daemonType)
+	}
+	for name, cfg := range _additionalCfgMap {
+		rv := reflect.ValueOf(cfg)
+		if rv.Kind() != reflect.Pointer || rv.IsNil() {
+			cfgMap[name] = cfg
+			continue
+		}
+		cp := reflect.New(rv.Elem().Type())
+		cp.Elem().Set(rv.Elem())
+		cfgMap[name] = cp.Interface()
+	}
+	logCfg := b.defaultLogCfg
+	cfgMap[cfgKeyBoot] = NewCfg()
+	cfgMap[cfgKeyLog] = &logCfg
+	return cfgMap
+}
+
+// snapshotCfgMap deep copy cfgs to diff, they are compared by value rather than marshaled,
+// because secrets are redacted when marshaled.
+func snapshotCfgMap(cfgMap map[string]any) map[string]any {
+	snapshot := make(map[string]any, len(cfgMap))
+	for name, cfg := range cfgMap {
+		snapshot[name] = reflects.DeepCopy(cfg)
+	}
+	return snapshot
+}
+
+// watchCfg reload cfg on reload signals and cfg file changes until booter stopping.
+func (b *booter) watchCfg() {
+	sigCh := make(chan os.Signal, 1)
+	if len(signals.ReloadSignals) > 0 {
+		signal.Notify(sigCh, signals.ReloadSignals...)
+		defer signal.Stop(sigCh)
+	}
+
+	var (
+		events    <-chan fsnotify.Event
+		watchErrs <-chan error
+	)
+	files, dir := b.cfgWatchPaths()
+	if (len(files) > 0 || dir != "") && !b.cfg.DisableCfgWatch {
+		watcher, err := watchCfgPaths(files, dir)
+		if err != nil {
+			b.Error("watch cfg failed", err, "files", files, "dir", dir)
+		} else {
+			defer watcher.Close()
+			events, watchErrs = watcher.Events, watcher.Errors
+		}
+	}
+
+	debounce := time.NewTimer(cfgReloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-b.Stopping():
+			return
+		case sig := <-sigCh:
+			b.Info("received signal, reload cfg", "signal", sig.String())
+			b.reloadAndLog()
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if !e.Has(fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove) {
+				continue
+			}
+			name := filepath.Clean(e.Name)
+			if slices.Contains(files, name) || dir != "" && filepath.Dir(name) == dir && isCfgFile(name) {
+				debounce.Reset(cfgReloadDebounce)
+			}
+		case err, ok := <-watchErrs:
+			if !ok {
+				watchErrs = nil
+				continue
+			}
+			b.Error("cfg watcher error", err)
+		case <-debounce.C:
+			b.Info("cfg file changed, reload cfg")
+			b.reloadAndLog()
+		}
+	}
+}
+
+// cfgWatchPaths return abs path of local cfg files and cfg dir.
+func (b *booter) cfgWatchPaths() ([]string, string) {
+	var (
+		files []string
+		dir   string
+	)
+	sources, _ := b.cfgSources()
+	for _, src := range sources {
+		if fs, ok := src.(*fileSource); ok {
+			if p, err := filepath.Abs(fs.path); err == nil {
+				files = append(files, p)
+			}
+		}
+	}
+	if b.options.CfgDir != "" {
+		dir, _ = filepath.Abs(b.options.CfgDir)
+	}
+	return files, dir
+}
+
+// watchCfgPaths watch cfg dir and the dirs of cfg files, editors and config management
+// usually replace the file by rename.
+func watchCfgPaths(files []string, dir string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errs.Wrap(err, "create notify watcher failed")
+	}
+	dirs := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		dirs = append(dirs, filepath.Dir(f))
+	}
+	if dir != "" {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+	for _, d := range slices.Compact(dirs) {
+		err = watcher.Add(d)
+		if err != nil {
+			watcher.Close()
+			return nil, errs.Wrapf(err, "watch cfg dir failed: %s", d)
+		}
+	}
+	return watcher, nil
+}
+
+func (b *booter) reloadAndLog() {
+	err := b.reload()
+	if err != nil {
+		b.Error("reload cfg failed", err)
+	}
+}
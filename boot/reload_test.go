@@ -0,0 +1,73 @@
+package boot
+
+import (
+	"testing"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/log"
+	"github.com/donkeywon/golib/runner"
+	"github.com/donkeywon/golib/util/reflects"
+	"github.com/donkeywon/golib/util/secret"
+	"github.com/stretchr/testify/require"
+)
+
+type reloadableCfg struct {
+	Size     int           `yaml:"size"`
+	Password secret.String `yaml:"password"`
+}
+
+type reloadable struct {
+	runner.Runner
+	cfg *reloadableCfg
+}
+
+func (r *reloadable) Reload(newCfg any) error {
+	cfg := newCfg.(*reloadableCfg)
+	if cfg.Size <= 0 {
+		return errs.New("size must gt 0")
+	}
+	r.cfg = cfg
+	return nil
+}
+
+func TestApplyCfg(t *testing.T) {
+	b := create()
+	b.cfgMap, b.cfgKeys = b.buildCfgMap()
+	l, err := log.NewCfg().Build()
+	require.NoError(t, err)
+	reflects.SetFirstMatchedField(b.Runner, l)
+
+	d := &reloadable{Runner: runner.Create("reloadable"), cfg: &reloadableCfg{Size: 1}}
+	b.setDaemon("reloadable", d)
+	b.setDaemon("static", runner.Create("static"))
+
+	require.NoError(t, b.applyCfg("reloadable", &reloadableCfg{Size: 2}))
+	require.Equal(t, 2, d.cfg.Size)
+	require.Equal(t, d.cfg, b.getCfg("reloadable"))
+
+	require.Error(t, b.applyCfg("reloadable", &reloadableCfg{Size: 0}))
+	require.Equal(t, 2, d.cfg.Size)
+
+	require.ErrorContains(t, b.applyCfg("static", &reloadableCfg{}), "not reloadable")
+
+	logCfg := *b.logCfg
+	logCfg.Level = "debug"
+	require.NoError(t, b.applyCfg(cfgKeyLog, &logCfg))
+	require.Equal(t, "debug", b.logCfg.Level)
+	logCfg.Format = "json"
+	logCfg.Level = "warn"
+	err = b.applyCfg(cfgKeyLog, &logCfg)
+	require.ErrorContains(t, err, "log format not reloadable")
+	require.NotContains(t, err.Error(), "level")
+	require.Equal(t, "warn", b.logCfg.Level)
+	require.NotEqual(t, "json", b.logCfg.Format)
+}
+
+func TestSnapshotCfgMap(t *testing.T) {
+	cfg := &reloadableCfg{Size: 1, Password: "old"}
+	snapshot := snapshotCfgMap(map[string]any{"reloadable": cfg})
+	require.Equal(t, cfg, snapshot["reloadable"])
+
+	cfg.Password = "new"
+	require.NotEqual(t, cfg, snapshot["reloadable"])
+}
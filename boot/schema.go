@@ -0,0 +1,335 @@
+package boot
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/secret"
+	"github.com/jessevdk/go-flags"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema of cfg, x-env and x-flag are the env key and flag name of the value.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	WriteOnly            bool               `json:"writeOnly,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Env                  string             `json:"x-env,omitempty"`
+	Flag                 string             `json:"x-flag,omitempty"`
+}
+
+// JSONSchema generate JSON Schema of all registered daemon cfgs and RegCfg cfgs,
+// opt is the same as Boot, e.g. EnvPrefix affects x-env.
+func JSONSchema(opt ...Option) ([]byte, error) {
+	b := create(opt...)
+	cfgMap, cfgKeys := b.buildCfgMap()
+	s, err := buildSchema(b.options, cfgMap, cfgKeys)
+	if err != nil {
+		return nil, err
+	}
+	return jsons.MarshalIndent(s, "", "  ")
+}
+
+func buildSchema(opts *options, cfgMap map[string]any, cfgKeys []string) (*Schema, error) {
+	parser, err := buildFlagParser(opts, cfgMap, cfgKeys)
+	if err != nil {
+		return nil, errs.Wrap(err, "build flag parser failed")
+	}
+	flagOpts := flagOptions(parser, cfgKeys)
+
+	root := &Schema{
+		Schema:     jsonSchemaDraft,
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+	for _, name := range cfgKeys {
+		g := &schemaGen{flagOpts: flagOpts, visiting: make(map[reflect.Type]bool)}
+		s := g.gen(reflect.ValueOf(cfgMap[name]), name)
+
+		// dotted names are nested in cfg file, see loadCfgSources
+		parent := root
+		parts := strings.Split(name, ".")
+		for _, p := range parts[:len(parts)-1] {
+			if parent.Properties[p] == nil {
+				parent.Properties[p] = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+			}
+			parent = parent.Properties[p]
+		}
+		parent.Properties[parts[len(parts)-1]] = s
+	}
+	return root, nil
+}
+
+// flagOptions return flag options keyed by path like httpd.addr.
+func flagOptions(parser *flags.Parser, cfgKeys []string) map[string]*flags.Option {
+	opts := make(map[string]*flags.Option)
+	for _, g := range parser.Groups() {
+		for _, name := range cfgKeys {
+			if g.Namespace == "" || strings.ReplaceAll(name, ".", "_") != g.Namespace {
+				continue
+			}
+			for _, opt := range g.Options() {
+				opts[name+"."+yamlFieldName(opt.Field())] = opt
+			}
+		}
+	}
+	return opts
+}
+
+type schemaGen struct {
+	flagOpts map[string]*flags.Option
+	visiting map[reflect.Type]bool
+	// embedded structs without tag are inlined like encoding/json, plugin cfgs are decoded from JSON
+	inlineEmbedded bool
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	secretType          = reflect.TypeFor[secret.String]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// gen generate schema of v, v is used to fill default values and may be invalid.
+func (g *schemaGen) gen(v reflect.Value, path string) *Schema {
+	t := v.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if v.IsValid() && !v.IsNil() {
+			v = v.Elem()
+		} else {
+			v = reflect.Value{}
+		}
+	}
+
+	s := &Schema{}
+	if opt := g.flagOpts[path]; opt != nil {
+		s.Env = opt.EnvKeyWithNamespace()
+		if opt.LongName != "" {
+			s.Flag = "--" + opt.LongNameWithNamespace()
+		}
+	}
+
+	switch {
+	case t == durationType:
+		s.Type = []string{"string", "integer"}
+		s.Format = "duration"
+		if v.IsValid() && !v.IsZero() {
+			s.Default = time.Duration(v.Int()).String()
+		}
+		return s
+	case t == secretType:
+		s.Type = "string"
+		s.WriteOnly = true
+		return s
+	case t.Kind() != reflect.String && reflect.PointerTo(t).Implements(textUnmarshalerType):
+		s.Type = "string"
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.Type = "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.Type = "integer"
+		s.Minimum = ptr(0.0)
+	case reflect.Float32, reflect.Float64:
+		s.Type = "number"
+	case reflect.String:
+		s.Type = "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s.Type = "string"
+			break
+		}
+		s.Type = "array"
+		s.Items = g.gen(reflect.New(t.Elem()).Elem(), path+"[]")
+		s.Items.Env, s.Items.Flag = "", ""
+	case reflect.Map:
+		s.Type = "object"
+		s.AdditionalProperties = g.gen(reflect.New(t.Elem()).Elem(), path+".*")
+		s.AdditionalProperties.Env, s.AdditionalProperties.Flag = "", ""
+	case reflect.Struct:
+		if g.visiting[t] {
+			s.Type = "object"
+			return s
+		}
+		g.visiting[t] = true
+		g.genStruct(s, t, v, path)
+		delete(g.visiting, t)
+		return s
+	default:
+		// interface, e.g. plugin cfg decided by type at runtime
+		return s
+	}
+
+	if v.IsValid() && !v.IsZero() && v.CanInterface() {
+		s.Default = v.Interface()
+	}
+	return s
+}
+
+func (g *schemaGen) genStruct(s *Schema, t reflect.Type, v reflect.Value, path string) {
+	s.Type = "object"
+	s.Properties = make(map[string]*Schema)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = v.Field(i)
+		} else {
+			fv = reflect.New(f.Type).Elem()
+		}
+
+		if strings.Contains(tag, ",inline") || (g.inlineEmbedded && f.Anonymous && tag == "") {
+			inline := &Schema{}
+			g.genStruct(inline, derefType(f.Type), derefValue(fv), path)
+			for k, p := range inline.Properties {
+				s.Properties[k] = p
+			}
+			s.Required = append(s.Required, inline.Required...)
+			continue
+		}
+
+		name := yamlFieldName(f)
+		fs := g.gen(fv, path+"."+name)
+		if fs.Description == "" {
+			fs.Description = f.Tag.Get("description")
+		}
+		if applyValidateTag(fs, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// applyValidateTag apply rules of validator tag to s, return whether the field is required.
+// Only rules which have equivalents in JSON Schema are applied, rules after dive are ignored.
+func applyValidateTag(s *Schema, tag string) bool {
+	var required bool
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "min", "gte":
+			applyBound(s, param, &s.Minimum, &s.MinLength, &s.MinItems)
+		case "max", "lte":
+			applyBound(s, param, &s.Maximum, &s.MaxLength, &s.MaxItems)
+		case "gt":
+			applyBound(s, param, &s.ExclusiveMinimum, nil, nil)
+		case "lt":
+			applyBound(s, param, &s.ExclusiveMaximum, nil, nil)
+		case "len":
+			applyBound(s, param, &s.Minimum, &s.MinLength, &s.MinItems)
+			applyBound(s, param, &s.Maximum, &s.MaxLength, &s.MaxItems)
+		case "oneof":
+			for _, e := range strings.Fields(param) {
+				s.Enum = append(s.Enum, e)
+			}
+		case "url", "uri":
+			s.Format = "uri"
+		case "email":
+			s.Format = "email"
+		case "ip", "ipv4", "ipv6", "hostname":
+			s.Format = name
+		}
+	}
+	return required
+}
+
+func applyBound(s *Schema, param string, num **float64, length **int, items **int) {
+	switch s.Type {
+	case "integer", "number":
+		f, err := strconv.ParseFloat(param, 64)
+		if err == nil {
+			*num = &f
+		}
+	case "string":
+		n, err := strconv.Atoi(param)
+		if err == nil && length != nil {
+			*length = &n
+		}
+	case "array":
+		n, err := strconv.Atoi(param)
+		if err == nil && items != nil {
+			*items = &n
+		}
+	}
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func derefValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// PluginSchemas generate JSON Schema of cfgs of all registered plugins keyed by plugin kind and type,
+// plugins registered without cfg creator are omitted.
+func PluginSchemas() map[string]map[string]*Schema {
+	m := make(map[string]map[string]*Schema)
+	for _, e := range plugin.List() {
+		cfg := e.NewCfg()
+		if cfg == nil {
+			continue
+		}
+		if m[e.Kind] == nil {
+			m[e.Kind] = make(map[string]*Schema)
+		}
+		g := &schemaGen{visiting: make(map[reflect.Type]bool), inlineEmbedded: true}
+		s := g.gen(reflect.ValueOf(cfg), "")
+		s.Description = "registered at " + e.Site
+		m[e.Kind][fmt.Sprint(e.Type)] = s
+	}
+	return m
+}
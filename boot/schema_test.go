@@ -0,0 +1,76 @@
+package boot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/util/secret"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaTestPool struct {
+	Name string `yaml:"name" validate:"required"`
+	Size int    `yaml:"size" validate:"gte=1"`
+}
+
+type schemaTestCfg struct {
+	Addr    string            `yaml:"addr"    env:"ADDR"    long:"addr"    description:"listen addr" validate:"required"`
+	Mode    string            `yaml:"mode"    validate:"oneof=a b"`
+	Timeout time.Duration     `yaml:"timeout" env:"TIMEOUT" long:"timeout"`
+	Pwd     secret.String     `yaml:"pwd"`
+	Pools   []*schemaTestPool `yaml:"pools"   validate:"dive"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+func TestBuildSchema(t *testing.T) {
+	b := create(EnvPrefix("app"))
+	cfg := &schemaTestCfg{Mode: "a", Timeout: time.Second, Pools: []*schemaTestPool{{Name: "default", Size: 1}}}
+	s, err := buildSchema(b.options, map[string]any{"srv.http": cfg}, []string{"srv.http"})
+	require.NoError(t, err)
+
+	srv := s.Properties["srv"].Properties["http"]
+	require.Equal(t, "object", srv.Type)
+	require.Equal(t, []string{"addr"}, srv.Required)
+
+	addr := srv.Properties["addr"]
+	require.Equal(t, "string", addr.Type)
+	require.Equal(t, "listen addr", addr.Description)
+	require.Equal(t, "APP_SRV_HTTP_ADDR", addr.Env)
+	require.Equal(t, "--srv_http-addr", addr.Flag)
+
+	require.Equal(t, []any{"a", "b"}, srv.Properties["mode"].Enum)
+	require.Equal(t, "1s", srv.Properties["timeout"].Default)
+	require.True(t, srv.Properties["pwd"].WriteOnly)
+	require.Equal(t, "array", srv.Properties["pools"].Type)
+	require.Equal(t, 1.0, *srv.Properties["pools"].Items.Properties["size"].Minimum)
+	require.Equal(t, "string", srv.Properties["labels"].AdditionalProperties.Type)
+}
+
+func TestValidateCfgFields(t *testing.T) {
+	cfg := &schemaTestCfg{Mode: "c", Pools: []*schemaTestPool{{Name: "default", Size: 1}, {Size: 0}}}
+	fieldErrs, otherErrs := validateCfgFields(map[string]any{"srv": cfg}, []string{"srv"})
+	require.Empty(t, otherErrs)
+
+	var errs []string
+	for _, e := range fieldErrs {
+		errs = append(errs, e.Error())
+	}
+	require.ElementsMatch(t, []string{
+		"srv.addr: failed on rule required",
+		"srv.mode: failed on rule oneof=a b",
+		"srv.pools[1].name: failed on rule required",
+		"srv.pools[1].size: failed on rule gte=1",
+	}, errs)
+}
+
+func TestPluginSchemas(t *testing.T) {
+	_, restore := plugin.Scoped()
+	defer restore()
+	plugin.Reg(DaemonType("schematest"), func() *schemaTestCfg { return &schemaTestCfg{} }, func() *schemaTestCfg { return &schemaTestCfg{Mode: "a"} })
+
+	s := PluginSchemas()["*boot.schemaTestCfg"]["schematest"]
+	require.NotNil(t, s)
+	require.Contains(t, s.Description, "boot/schema_test.go:")
+	require.Equal(t, "a", s.Properties["mode"].Default)
+}
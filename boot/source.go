@@ -0,0 +1,353 @@
+package boot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/donkeywon/golib/consts"
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/httpc"
+	"github.com/donkeywon/golib/util/httpu"
+	"github.com/donkeywon/golib/util/paths"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/jessevdk/go-flags"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Format is the format of cfg content.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+const DefaultHTTPSourceTimeout = 10 * time.Second
+
+// Source is a cfg source loaded after local cfg files, e.g. a remote config center.
+//
+// Cfg is loaded in the following order, later overrides former:
+//  1. default values
+//  2. cfg files by --config in order, or the default cfg file if exists
+//  3. files in --config-dir in lexical order
+//  4. sources by --config-url and CfgSource in order
+//  5. env
+//  6. flags
+//
+// Mappings are merged and other values including sequences are replaced.
+type Source interface {
+	// Name is shown as the origin of values in --print-config.
+	Name() string
+	Load(ctx context.Context) ([]byte, Format, error)
+}
+
+// FormatOf guess format by file extension, default is yaml.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+func isCfgFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json", ".toml":
+		return true
+	default:
+		return false
+	}
+}
+
+type fileSource struct {
+	path string
+}
+
+// NewFileSource create a Source reading a local cfg file, format is guessed by extension.
+func NewFileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+func (f *fileSource) Name() string {
+	return "file:" + f.path
+}
+
+func (f *fileSource) Load(context.Context) ([]byte, Format, error) {
+	bs, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, "", errs.Wrap(err, "read cfg file failed")
+	}
+	return bs, FormatOf(f.path), nil
+}
+
+// HTTPSource load cfg by GET an url, format is guessed by Content-Type and then url extension if not specified.
+type HTTPSource struct {
+	URL     string
+	Format  Format
+	Timeout time.Duration
+	Header  http.Header
+}
+
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{
+		URL:     url,
+		Timeout: DefaultHTTPSourceTimeout,
+	}
+}
+
+func (h *HTTPSource) Name() string {
+	return "http:" + h.URL
+}
+
+func (h *HTTPSource) Load(ctx context.Context) ([]byte, Format, error) {
+	var (
+		buf         bytes.Buffer
+		contentType string
+	)
+	opts := []httpc.Option{
+		httpc.CheckStatusCode(http.StatusOK),
+		httpc.RespOptionFunc(func(resp *http.Response) error {
+			contentType = resp.Header.Get(httpu.HeaderContentType)
+			return nil
+		}),
+		httpc.ToBytesBuffer(&buf),
+	}
+	if h.Header != nil {
+		opts = append(opts, httpc.WithHeader(h.Header))
+	}
+	_, err := httpc.Get(ctx, h.Timeout, h.URL, opts...)
+	if err != nil {
+		return nil, "", errs.Wrapf(err, "get cfg from %s failed", h.URL)
+	}
+
+	format := h.Format
+	if format == "" {
+		switch {
+		case strings.HasPrefix(contentType, httpu.MIMEJSON):
+			format = FormatJSON
+		case strings.HasPrefix(contentType, httpu.MIMETOML):
+			format = FormatTOML
+		case strings.HasPrefix(contentType, httpu.MIMEYAML), strings.HasPrefix(contentType, httpu.MIMEYAML2):
+			format = FormatYAML
+		default:
+			format = FormatOf(h.URL)
+		}
+	}
+	return buf.Bytes(), format, nil
+}
+
+// cfgSources return all sources in order of precedence, env and flags are not included.
+func (b *booter) cfgSources() ([]Source, error) {
+	var sources []Source
+
+	cfgPaths := b.options.CfgPath
+	if len(cfgPaths) == 0 && paths.FileExist(consts.CfgPath) {
+		cfgPaths = []string{consts.CfgPath}
+	}
+	for _, cfgPath := range cfgPaths {
+		if !paths.FileExist(cfgPath) {
+			return nil, errs.Errorf("cfg file not exists: %s", cfgPath)
+		}
+		sources = append(sources, NewFileSource(cfgPath))
+	}
+
+	if b.options.CfgDir != "" {
+		entries, err := os.ReadDir(b.options.CfgDir)
+		if err != nil {
+			return nil, errs.Wrapf(err, "read cfg dir failed: %s", b.options.CfgDir)
+		}
+		// ReadDir returns entries sorted by filename
+		for _, entry := range entries {
+			if entry.IsDir() || !isCfgFile(entry.Name()) {
+				continue
+			}
+			sources = append(sources, NewFileSource(filepath.Join(b.options.CfgDir, entry.Name())))
+		}
+	}
+
+	for _, url := range b.options.CfgURL {
+		sources = append(sources, NewHTTPSource(url))
+	}
+	sources = append(sources, b.options.sources...)
+	return sources, nil
+}
+
+// loadCfgSources merge sources in order and load into cfgMap, return origins of values keyed by path like httpd.addr.
+func loadCfgSources(ctx context.Context, sources []Source, cfgMap map[string]any) (map[string]string, error) {
+	var (
+		merged  = make(map[string]any)
+		origins = make(map[string]string)
+	)
+	for _, src := range sources {
+		data, format, err := src.Load(ctx)
+		if err != nil {
+			return nil, errs.Wrapf(err, "load cfg source %s failed", src.Name())
+		}
+		af, err := parseCfg(data, format)
+		if err != nil {
+			return nil, errs.Wrapf(err, "parse cfg source %s failed", src.Name())
+		}
+		for _, doc := range af.Docs {
+			if doc.Body == nil {
+				continue
+			}
+			var m map[string]any
+			err = yaml.NodeToValue(doc.Body, &m)
+			if err != nil {
+				return nil, errs.Wrapf(err, "decode cfg source %s failed", src.Name())
+			}
+			mergeMap(merged, m)
+		}
+		err = filterCfg(af, cfgMap, func(name string, node ast.Node) error {
+			recordOrigins(node, name, src.Name(), origins)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bs, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, errs.Wrap(err, "marshal merged cfg failed")
+	}
+	af, err := parser.ParseBytes(bs, 0)
+	if err != nil {
+		return nil, errs.Wrap(err, "parse merged cfg failed")
+	}
+	err = filterCfg(af, cfgMap, func(name string, node ast.Node) error {
+		err := yaml.NodeToValue(node, cfgMap[name])
+		if err != nil {
+			return errs.Wrapf(err, "unmarshal cfg fail: %s", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return origins, nil
+}
+
+// mergeMap merge src into dst, mappings are merged recursively and other values are replaced.
+func mergeMap(dst map[string]any, src map[string]any) {
+	for k, sv := range src {
+		sm, ok := sv.(map[string]any)
+		if !ok {
+			dst[k] = sv
+			continue
+		}
+		dm, ok := dst[k].(map[string]any)
+		if !ok {
+			dm = make(map[string]any, len(sm))
+			dst[k] = dm
+		}
+		mergeMap(dm, sm)
+	}
+}
+
+func parseCfg(data []byte, format Format) (*ast.File, error) {
+	switch format {
+	case FormatTOML:
+		var m map[string]any
+		err := toml.Unmarshal(data, &m)
+		if err != nil {
+			return nil, errs.Wrap(err, "unmarshal toml failed")
+		}
+		data, err = yaml.Marshal(m)
+		if err != nil {
+			return nil, errs.Wrap(err, "convert toml to yaml failed")
+		}
+	case FormatYAML, FormatJSON, "":
+		// json is a subset of yaml
+	default:
+		return nil, errs.Errorf("unknown cfg format: %s", format)
+	}
+	return parser.ParseBytes(data, 0)
+}
+
+// filterCfg call f with the node of each cfg in af.
+func filterCfg(af *ast.File, cfgMap map[string]any, f func(name string, node ast.Node) error) error {
+	for name := range cfgMap {
+		yp, err := yaml.PathString("$." + name)
+		if err != nil {
+			return errs.Wrapf(err, "invalid cfg name: %s", name)
+		}
+		node, err := yp.FilterFile(af)
+		if errors.Is(err, yaml.ErrNotFoundNode) || node == nil {
+			continue
+		}
+		err = f(name, node)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordOrigins record origin of leaf values under node, sequences are treated as leaves since they are replaced as a whole.
+func recordOrigins(node ast.Node, path string, origin string, origins map[string]string) {
+	switch n := node.(type) {
+	case *ast.AnchorNode:
+		recordOrigins(n.Value, path, origin, origins)
+	case *ast.MappingNode:
+		for _, v := range n.Values {
+			recordOrigins(v, path, origin, origins)
+		}
+	case *ast.MappingValueNode:
+		key := n.Key.String()
+		if s, ok := n.Key.(ast.ScalarNode); ok {
+			key = fmt.Sprint(s.GetValue())
+		}
+		recordOrigins(n.Value, path+"."+key, origin, origins)
+	default:
+		setOrigin(origins, path, origin)
+	}
+}
+
+// setOrigin set origin of path, a value overrides all values under it from former sources.
+func setOrigin(origins map[string]string, path string, origin string) {
+	for p := range origins {
+		if strings.HasPrefix(p, path+".") {
+			delete(origins, p)
+		}
+	}
+	origins[path] = origin
+}
+
+// flagOrigins return origins of values set by env or flags.
+func flagOrigins(parser *flags.Parser, cfgKeys []string) map[string]string {
+	origins := make(map[string]string)
+	for path, opt := range flagOptions(parser, cfgKeys) {
+		switch {
+		case opt.IsSet() && !opt.IsSetDefault():
+			origins[path] = "flag:--" + opt.LongNameWithNamespace()
+		case opt.EnvKeyWithNamespace() != "":
+			if _, ok := os.LookupEnv(opt.EnvKeyWithNamespace()); ok {
+				origins[path] = "env:" + opt.EnvKeyWithNamespace()
+			}
+		}
+	}
+	return origins
+}
+
+func yamlFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
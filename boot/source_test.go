@@ -0,0 +1,56 @@
+package boot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type sourceTestCfg struct {
+	Addr   string            `yaml:"addr"`
+	Size   int               `yaml:"size"`
+	Tags   []string          `yaml:"tags"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+func TestLoadCfgSources(t *testing.T) {
+	dir := t.TempDir()
+	confd := filepath.Join(dir, "conf.d")
+	require.NoError(t, os.Mkdir(confd, 0o755))
+
+	writeFile(t, filepath.Join(dir, "base.yaml"), "srv:\n  addr: :80\n  size: 1\n  tags: [a, b]\n  labels:\n    env: dev\n")
+	writeFile(t, filepath.Join(confd, "10-size.json"), `{"srv": {"size": 2, "labels": {"zone": "z1"}}}`)
+	writeFile(t, filepath.Join(confd, "20-tags.toml"), "[srv]\ntags = [\"c\"]\nsize = 3\n")
+	writeFile(t, filepath.Join(confd, "ignored.txt"), "srv: {addr: ignored}")
+
+	b := create(CfgPath(filepath.Join(dir, "base.yaml")), CfgDir(confd))
+	sources, err := b.cfgSources()
+	require.NoError(t, err)
+	require.Len(t, sources, 3)
+
+	cfg := &sourceTestCfg{Addr: ":8080"}
+	origins, err := loadCfgSources(t.Context(), sources, map[string]any{"srv": cfg})
+	require.NoError(t, err)
+	require.Equal(t, &sourceTestCfg{
+		Addr:   ":80",
+		Size:   3,
+		Tags:   []string{"c"},
+		Labels: map[string]string{"env": "dev", "zone": "z1"},
+	}, cfg)
+	require.Equal(t, "file:"+filepath.Join(dir, "base.yaml"), origins["srv.addr"])
+	require.Equal(t, "file:"+filepath.Join(confd, "20-tags.toml"), origins["srv.size"])
+	require.Equal(t, "file:"+filepath.Join(confd, "10-size.json"), origins["srv.labels.zone"])
+
+	b.cfgMap = map[string]any{"srv": cfg}
+	b.cfgKeys = []string{"srv"}
+	buf := &bytes.Buffer{}
+	require.NoError(t, b.printCfg(buf, origins))
+	require.Contains(t, buf.String(), "addr: :80 # file:"+filepath.Join(dir, "base.yaml"))
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
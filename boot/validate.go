@@ -0,0 +1,109 @@
+package boot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/donkeywon/golib/util/reflects"
+	"github.com/donkeywon/golib/util/v"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a validation error of cfg field, Path is like httpd.addr or taskd.pools[0].name.
+type FieldError struct {
+	Path string
+	Rule string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": failed on rule " + e.Rule
+}
+
+// validateCfgFields validate all cfgs and return field errors,
+// errors can not be attributed to a field are returned as is.
+func validateCfgFields(cfgMap map[string]any, cfgKeys []string) ([]*FieldError, []error) {
+	var (
+		fieldErrs []*FieldError
+		otherErrs []error
+	)
+	for _, name := range cfgKeys {
+		cfg := cfgMap[name]
+		if !reflects.IsStructPointer(cfg) {
+			continue
+		}
+		err := v.Struct(cfg)
+		if err == nil {
+			continue
+		}
+		var ves validator.ValidationErrors
+		if !errors.As(err, &ves) {
+			otherErrs = append(otherErrs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		for _, fe := range ves {
+			rule := fe.Tag()
+			if fe.Param() != "" {
+				rule += "=" + fe.Param()
+			}
+			fieldErrs = append(fieldErrs, &FieldError{
+				Path: name + yamlPath(reflect.TypeOf(cfg), fe.StructNamespace()),
+				Rule: rule,
+			})
+		}
+	}
+	return fieldErrs, otherErrs
+}
+
+// validateOnly validate loaded cfgs and print the result, return the exit code.
+func (b *booter) validateOnly(stdout io.Writer, stderr io.Writer) int {
+	fieldErrs, otherErrs := validateCfgFields(b.cfgMap, b.cfgKeys)
+	if len(fieldErrs) == 0 && len(otherErrs) == 0 {
+		fmt.Fprintln(stdout, "config is valid")
+		return 0
+	}
+	for _, e := range fieldErrs {
+		fmt.Fprintln(stderr, e.Error())
+	}
+	for _, e := range otherErrs {
+		fmt.Fprintln(stderr, e.Error())
+	}
+	return 1
+}
+
+// yamlPath convert struct namespace of validator like Cfg.Pools[0].Name to yaml path like .pools[0].name.
+func yamlPath(t reflect.Type, structNamespace string) string {
+	parts := strings.Split(structNamespace, ".")
+	if len(parts) <= 1 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i, p := range parts[1:] {
+		name, idx, _ := strings.Cut(p, "[")
+		t = derefType(t)
+		if t.Kind() != reflect.Struct {
+			sb.WriteString("." + strings.Join(parts[i+1:], "."))
+			break
+		}
+		f, ok := t.FieldByName(name)
+		if !ok {
+			sb.WriteString("." + strings.Join(parts[i+1:], "."))
+			break
+		}
+		if !strings.Contains(f.Tag.Get("yaml"), ",inline") {
+			sb.WriteString("." + yamlFieldName(f))
+		}
+		t = f.Type
+		if idx != "" {
+			sb.WriteString("[" + idx)
+			t = derefType(t)
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+	}
+	return sb.String()
+}
@@ -14,7 +14,11 @@ const (
 	FieldCmdExitCode = "exit"
 	FieldCmdSignaled = "signaled"
 
-	FieldResult = "result"
+	FieldResult       = "result"
+	FieldSkipped      = "skipped"
+	FieldErr          = "err"
+	FieldAttempts     = "attempts"
+	FieldRetryHistory = "retryHistory"
 
 	FieldFtpCode = "ftpCode"
 	FieldFtpMsg  = "ftpMsg"
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/secret"
 )
 
 const (
@@ -13,7 +14,7 @@ const (
 type PoolCfg struct {
 	Name             string        `json:"name"                yaml:"name"`
 	Type             string        `json:"type"                yaml:"type"`
-	DSN              string        `json:"dsn"                 yaml:"dsn"`
+	DSN              secret.String `json:"dsn"                 yaml:"dsn"`
 	MaxIdle          int           `json:"max_idle"            yaml:"maxIdle"`
 	MaxOpen          int           `json:"max_open"            yaml:"maxOpen"`
 	MaxLifeTime      time.Duration `json:"max_life_time"       yaml:"maxLifeTime"`
@@ -3,6 +3,7 @@ package dbp
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/donkeywon/golib/boot"
@@ -15,6 +16,7 @@ import (
 const DaemonTypeDBP boot.DaemonType = "dbp"
 
 var _ DBP = (*dbp)(nil)
+var _ boot.Dependent = (*dbp)(nil)
 
 type DBP interface {
 	boot.Daemon
@@ -100,7 +102,7 @@ func New() boot.Daemon {
 
 func (d *dbp) Init() error {
 	for _, dbCfg := range d.cfg.Pools {
-		db, err := sql.Open(dbCfg.Type, dbCfg.DSN)
+		db, err := sql.Open(dbCfg.Type, dbCfg.DSN.Value())
 		if err != nil {
 			return errs.Wrapf(err, "open db failed, name: %s, type: %s", dbCfg.Name, dbCfg.Type)
 		}
@@ -125,6 +127,13 @@ func (d *dbp) Init() error {
 	return d.Runner.Init()
 }
 
+func (d *dbp) DependsOn() []boot.DaemonType {
+	if d.cfg.EnableExportMetrics {
+		return []boot.DaemonType{metricsd.DaemonTypeMetricsd}
+	}
+	return nil
+}
+
 func (d *dbp) SetCfg(cfg any) {
 	d.cfg = cfg.(*Cfg)
 }
@@ -156,12 +165,32 @@ func (d *dbp) waitDBReady(db *sql.DB, name string, typ string, maxWait time.Dura
 	}
 }
 
-func (d *dbp) checkDBReady(db *sql.DB, query string) error {
+func (d *dbp) checkDBReady(db *sql.DB, readyQuery string) error {
+	return d.checkDB(d.Ctx(), db, readyQuery)
+}
+
+func (d *dbp) checkDB(ctx context.Context, db *sql.DB, query string) error {
 	if query == "" {
-		return db.PingContext(d.Ctx())
+		return db.PingContext(ctx)
 	}
 
-	_, err := db.ExecContext(d.Ctx(), query)
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+// Check ping all pools, implements runner.HealthChecker.
+func (d *dbp) Check(ctx context.Context) error {
+	var err error
+	for _, dbCfg := range d.cfg.Pools {
+		db := d.dbs[dbCfg.Name]
+		if db == nil {
+			continue
+		}
+		e := d.checkDB(ctx, db, dbCfg.ReadyQuery)
+		if e != nil {
+			err = errors.Join(err, errs.Wrapf(e, "check db failed, name: %s, type: %s", dbCfg.Name, dbCfg.Type))
+		}
+	}
 	return err
 }
 
@@ -10,6 +10,11 @@ const (
 	DefaultReadTimeout       = 60 * time.Second
 	DefaultReadHeaderTimeout = 5 * time.Second
 	DefaultIdleTimeout       = 30 * time.Second
+	DefaultRunnerDumpPath    = "/debug/runners"
+	DefaultHealthzPath       = "/healthz"
+	DefaultReadyzPath        = "/readyz"
+	DefaultHealthTimeout     = 5 * time.Second
+	DefaultShutdownTimeout   = 10 * time.Second
 )
 
 type Cfg struct {
@@ -18,6 +23,13 @@ type Cfg struct {
 	ReadTimeout       time.Duration `env:"READ_TIMEOUT"        long:"read-timeout"        yaml:"readTimeout"                           description:"maximum duration for reading the entire request, including the body. A zero or negative value means there will be no timeout."`
 	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" long:"read-header-timeout" yaml:"readHeaderTimeout"                     description:"the amount of time allowed to read request headers"`
 	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT"        long:"idle-timeout"        yaml:"idleTimeout"                           description:"maximum amount of time to wait for the next request when keep-alives are enabled"`
+	EnableRunnerDump  bool          `env:"ENABLE_RUNNER_DUMP"  long:"enable-runner-dump"  yaml:"enableRunnerDump"                      description:"serve the live runner tree as json"`
+	RunnerDumpPath    string        `env:"RUNNER_DUMP_PATH"    long:"runner-dump-path"    yaml:"runnerDumpPath"                        description:"runner tree dump http endpoint path"`
+	DisableHealth     bool          `env:"DISABLE_HEALTH"      long:"disable-health"      yaml:"disableHealth"                         description:"disable liveness and readiness http endpoint"`
+	HealthzPath       string        `env:"HEALTHZ_PATH"        long:"healthz-path"        yaml:"healthzPath"                           description:"liveness http endpoint path"`
+	ReadyzPath        string        `env:"READYZ_PATH"         long:"readyz-path"         yaml:"readyzPath"                            description:"readiness http endpoint path"`
+	HealthTimeout     time.Duration `env:"HEALTH_TIMEOUT"      long:"health-timeout"      yaml:"healthTimeout"                         description:"timeout of readiness checks"`
+	ShutdownTimeout   time.Duration `env:"SHUTDOWN_TIMEOUT"    long:"shutdown-timeout"    yaml:"shutdownTimeout"                       description:"maximum duration to drain in-flight requests on stop, connections are closed after it. A zero or negative value means no draining."`
 }
 
 func NewCfg() *Cfg {
@@ -26,6 +38,11 @@ func NewCfg() *Cfg {
 		ReadTimeout:       DefaultReadTimeout,
 		ReadHeaderTimeout: DefaultReadHeaderTimeout,
 		IdleTimeout:       DefaultIdleTimeout,
+		RunnerDumpPath:    DefaultRunnerDumpPath,
+		HealthzPath:       DefaultHealthzPath,
+		ReadyzPath:        DefaultReadyzPath,
+		HealthTimeout:     DefaultHealthTimeout,
+		ShutdownTimeout:   DefaultShutdownTimeout,
 	}
 }
 
@@ -1,16 +1,23 @@
 package httpd
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
 	"github.com/donkeywon/golib/boot"
+	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/runner"
+	"github.com/donkeywon/golib/util/handover"
+	"github.com/donkeywon/golib/util/httpu"
 )
 
 const DaemonTypeHTTPd boot.DaemonType = "httpd"
 
-var _ HTTPd = (*httpd)(nil)
+var (
+	_ HTTPd        = (*httpd)(nil)
+	_ boot.Readier = (*httpd)(nil)
+)
 
 type HTTPd interface {
 	boot.Daemon
@@ -37,11 +44,14 @@ type httpd struct {
 	patterns    []string
 	handlers    []http.Handler
 	middlewares []func(http.Handler) http.Handler
+
+	ready chan struct{} // closed after listening
 }
 
 func New() boot.Daemon {
 	return &httpd{
 		Runner: runner.Create(string(DaemonTypeHTTPd)),
+		ready:  make(chan struct{}),
 	}
 }
 
@@ -50,6 +60,14 @@ func (h *httpd) Init() error {
 		h.r = http.NewServeMux()
 	}
 
+	if h.cfg.EnableRunnerDump {
+		h.HandleFunc(h.cfg.RunnerDumpPath, h.dumpRunners)
+	}
+	if !h.cfg.DisableHealth {
+		h.HandleFunc(h.cfg.HealthzPath, h.healthz)
+		h.HandleFunc(h.cfg.ReadyzPath, h.readyz)
+	}
+
 	for i := range h.patterns {
 		h.r.Handle(h.patterns[i], h.buildHandlerChain(h.handlers[i]))
 	}
@@ -59,11 +77,35 @@ func (h *httpd) Init() error {
 }
 
 func (h *httpd) Start() error {
-	return h.s.ListenAndServe()
+	// listener may be inherited from the old process on upgrade
+	l, err := handover.Listen(string(DaemonTypeHTTPd), "tcp", h.cfg.Addr)
+	if err != nil {
+		return errs.Wrapf(err, "listen on %s failed", h.cfg.Addr)
+	}
+	// connections are accepted by Serve after listening, they are queued in backlog before
+	close(h.ready)
+	return h.s.Serve(l)
+}
+
+// Ready is closed after listening, implements boot.Readier.
+func (h *httpd) Ready() <-chan struct{} {
+	return h.ready
 }
 
+// Stop drain in-flight requests until ShutdownTimeout, then close remaining connections,
+// e.g. long-lived or hijacked ones.
 func (h *httpd) Stop() error {
-	return h.s.Close()
+	if h.cfg.ShutdownTimeout <= 0 {
+		return h.s.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.ShutdownTimeout)
+	defer cancel()
+	err := h.s.Shutdown(ctx)
+	if err != nil {
+		h.Warn("drain in-flight requests failed, close connections", "err", err)
+		return h.s.Close()
+	}
+	return nil
 }
 
 func (h *httpd) AppendError(err ...error) {
@@ -108,3 +150,32 @@ func (h *httpd) buildHandlerChain(next http.Handler) http.Handler {
 	}
 	return handler
 }
+
+func (h *httpd) dumpRunners(w http.ResponseWriter, _ *http.Request) {
+	httpu.RespJSON(w, http.StatusOK, runner.Dump(runner.Root(h)))
+}
+
+func (h *httpd) healthz(w http.ResponseWriter, _ *http.Request) {
+	root := runner.Root(h)
+	if !runner.Alive(root) {
+		httpu.RespJSON(w, http.StatusServiceUnavailable, map[string]any{"alive": false, "state": root.State()})
+		return
+	}
+	httpu.RespJSON(w, http.StatusOK, map[string]any{"alive": true, "state": root.State()})
+}
+
+func (h *httpd) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.cfg.HealthTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.cfg.HealthTimeout)
+		defer cancel()
+	}
+
+	health := runner.CheckHealth(ctx, runner.Root(h))
+	if !health.Healthy {
+		httpu.RespJSON(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	httpu.RespJSON(w, http.StatusOK, health)
+}
@@ -1,11 +1,13 @@
 package metricsd
 
 import (
+	"context"
 	"reflect"
 	"sync"
 
 	"github.com/donkeywon/golib/boot"
 	"github.com/donkeywon/golib/daemon/httpd"
+	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/runner"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
@@ -15,6 +17,7 @@ import (
 const DaemonTypeMetricsd boot.DaemonType = "metricsd"
 
 var _ Metricsd = (*metricsd)(nil)
+var _ boot.Dependent = (*metricsd)(nil)
 
 type Metricsd interface {
 	boot.Daemon
@@ -62,6 +65,19 @@ func (p *metricsd) Init() error {
 	return p.Runner.Init()
 }
 
+func (p *metricsd) DependsOn() []boot.DaemonType {
+	return []boot.DaemonType{httpd.DaemonTypeHTTPd}
+}
+
+// Check gather the registry, implements runner.HealthChecker.
+func (p *metricsd) Check(context.Context) error {
+	_, err := p.reg.Gather()
+	if err != nil {
+		return errs.Wrap(err, "gather metrics failed")
+	}
+	return nil
+}
+
 func (p *metricsd) SetCfg(cfg any) {
 	p.cfg = cfg.(*Cfg)
 }
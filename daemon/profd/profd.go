@@ -23,6 +23,7 @@ import (
 const DaemonTypeProfd boot.DaemonType = "profd"
 
 var _ Profd = (*profd)(nil)
+var _ boot.Dependent = (*profd)(nil)
 
 type Profd interface {
 	boot.Daemon
@@ -50,6 +51,10 @@ func New() boot.Daemon {
 	}
 }
 
+func (p *profd) DependsOn() []boot.DaemonType {
+	return []boot.DaemonType{httpd.DaemonTypeHTTPd}
+}
+
 func (p *profd) Init() error {
 	p.httpd = boot.Get[httpd.HTTPd](httpd.DaemonTypeHTTPd)
 
@@ -217,33 +222,11 @@ func (p *profd) ipAllowed(w http.ResponseWriter, r *http.Request) bool {
 	if p.allowedIPsGetter == nil {
 		return true
 	}
-
-	ips := p.allowedIPsGetter()
-	if len(ips) == 0 {
-		return true
-	}
-
-	remoteIP := httpu.GetRealRemoteIP(r)
-	if _, exist := ips[remoteIP]; exist {
-		return true
-	}
-
-	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-	return false
+	return httpu.IPAllowed(w, r, p.allowedIPsGetter())
 }
 
 func (p *profd) auth(w http.ResponseWriter, r *http.Request) bool {
-	if p.cfg.WebAuthUser == "" && p.cfg.WebAuthPwd == "" {
-		return true
-	}
-	user, pass, ok := r.BasicAuth()
-	if ok && user == p.cfg.WebAuthUser && pass == p.cfg.WebAuthPwd {
-		return true
-	}
-
-	w.Header().Set("WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`)
-	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-	return false
+	return httpu.BasicAuth(w, r, p.cfg.WebAuthUser, p.cfg.WebAuthPwd.Value())
 }
 
 func (p *profd) prettytrace(w http.ResponseWriter, r *http.Request) {
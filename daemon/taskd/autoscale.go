@@ -0,0 +1,149 @@
+package taskd
+
+import (
+	"context"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/proc"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+const (
+	DefaultAutoscaleInterval      = 10
+	DefaultAutoscaleMaxCPUPercent = 80
+	DefaultAutoscaleMaxMemPercent = 80
+)
+
+// AutoscaleCfg is cfg of autoscaling size of a pool every Interval seconds.
+// Pool grows by Step if there are at least QueueDepth tasks queued, and shrinks by Step if workers are idle.
+// Pool shrinks instead of growing if host cpu or memory usage in percent reaches MaxCPUPercent or MaxMemPercent,
+// or memory of taskd process and its children reaches MaxProcMemory in bytes, 0 means unlimited.
+type AutoscaleCfg struct {
+	MinSize       int     `json:"minSize"                 validate:"gte=1"                yaml:"minSize"`
+	MaxSize       int     `json:"maxSize"                 validate:"gtefield=MinSize"     yaml:"maxSize"`
+	Interval      int     `json:"interval,omitempty"      validate:"gte=0"                yaml:"interval,omitempty"`
+	Step          int     `json:"step,omitempty"          validate:"gte=0"                yaml:"step,omitempty"`
+	QueueDepth    int     `json:"queueDepth,omitempty"    validate:"gte=0"                yaml:"queueDepth,omitempty"`
+	MaxCPUPercent float64 `json:"maxCpuPercent,omitempty" validate:"gte=0,lte=100"        yaml:"maxCpuPercent,omitempty"`
+	MaxMemPercent float64 `json:"maxMemPercent,omitempty" validate:"gte=0,lte=100"        yaml:"maxMemPercent,omitempty"`
+	MaxProcMemory uint64  `json:"maxProcMemory,omitempty" yaml:"maxProcMemory,omitempty"`
+}
+
+func (c *AutoscaleCfg) interval() time.Duration {
+	if c.Interval <= 0 {
+		return DefaultAutoscaleInterval * time.Second
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+// hostLoad is sampled usage of host and taskd.
+type hostLoad struct {
+	CPUPercent float64
+	MemPercent float64
+	ProcMemory uint64
+}
+
+// desired return size of pool under load, size is the current size.
+func (c *AutoscaleCfg) desired(size int, running int, waiting int, load *hostLoad) int {
+	step := max(c.Step, 1)
+	queueDepth := max(c.QueueDepth, 1)
+	maxCPU := c.MaxCPUPercent
+	if maxCPU == 0 {
+		maxCPU = DefaultAutoscaleMaxCPUPercent
+	}
+	maxMem := c.MaxMemPercent
+	if maxMem == 0 {
+		maxMem = DefaultAutoscaleMaxMemPercent
+	}
+
+	switch {
+	case load.CPUPercent >= maxCPU || load.MemPercent >= maxMem || c.MaxProcMemory > 0 && load.ProcMemory >= c.MaxProcMemory:
+		size -= step
+	case waiting >= queueDepth:
+		size += step
+	case waiting == 0 && running < size:
+		// shrink idle workers, but not below running tasks
+		size = max(size-step, running)
+	}
+	return min(max(size, c.MinSize), c.MaxSize)
+}
+
+// sampleHostLoad sample cpu usage since last sampling, the first sampling is since boot.
+func sampleHostLoad(ctx context.Context, withProcMemory bool) (*hostLoad, error) {
+	cpuPercents, err := cpu.PercentWithContext(ctx, 0, false)
+	if err != nil {
+		return nil, errs.Wrap(err, "get cpu percent failed")
+	}
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, errs.Wrap(err, "get memory usage failed")
+	}
+
+	load := &hostLoad{MemPercent: vm.UsedPercent}
+	if len(cpuPercents) > 0 {
+		load.CPUPercent = cpuPercents[0]
+	}
+	if withProcMemory {
+		load.ProcMemory, err = proc.CalcSelfProcTreeMemoryUsage(ctx)
+		if err != nil {
+			return nil, errs.Wrap(err, "get memory usage of processes failed")
+		}
+	}
+	return load, nil
+}
+
+// autoscale resize pools with autoscale cfg until taskd is stopping.
+func (td *taskd) autoscale() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-td.Stopping():
+			return
+		case now := <-ticker.C:
+			td.autoscalePools(now)
+		}
+	}
+}
+
+func (td *taskd) autoscalePools(now time.Time) {
+	var (
+		due            []*pool
+		withProcMemory bool
+	)
+	for _, p := range td.listPools() {
+		cfg := p.autoscaleCfg()
+		if cfg == nil || now.Sub(p.lastScaled) < cfg.interval() {
+			continue
+		}
+		p.lastScaled = now
+		due = append(due, p)
+		withProcMemory = withProcMemory || cfg.MaxProcMemory > 0
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	load, err := sampleHostLoad(td.Ctx(), withProcMemory)
+	if err != nil {
+		td.Error("sample host load failed", err)
+		return
+	}
+	for _, p := range due {
+		cfg := p.autoscaleCfg()
+		if cfg == nil {
+			continue
+		}
+		size := p.MaxConcurrency()
+		newSize := cfg.desired(size, int(p.RunningWorkers()), int(p.WaitingTasks()), load)
+		if newSize == size {
+			continue
+		}
+		td.Info("autoscale pool", "pool", p.name(), "from", size, "to", newSize,
+			"running", p.RunningWorkers(), "waiting", p.WaitingTasks(),
+			"cpu_percent", load.CPUPercent, "mem_percent", load.MemPercent, "proc_memory", load.ProcMemory)
+		p.Resize(newSize)
+	}
+}
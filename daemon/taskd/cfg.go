@@ -1,19 +1,43 @@
 package taskd
 
+import (
+	"github.com/donkeywon/golib/kvs"
+	"github.com/donkeywon/golib/ratelimit"
+	"github.com/donkeywon/golib/util/secret"
+)
+
 const (
 	DefaultPool      = "default"
 	DefaultPoolSize  = 64
 	DefaultQueueSize = 1024
 )
 
+// PoolCfg is cfg of a pool, tasks beyond Size are queued by priority and fair shared between tenants.
+// Tenant of a task is its label TenantLabel, or its type if TenantLabel is empty.
+// Weights are weights of tenants in fair share, default is 1. Size is adjusted by Autoscale if it is set.
 type PoolCfg struct {
-	Name      string `json:"name"      yaml:"name"      validate:"required"`
-	Size      int    `json:"size"      yaml:"size"      validate:"required"`
-	QueueSize int    `json:"queueSize" yaml:"queueSize" validate:"required"`
+	Name        string         `json:"name"                  yaml:"name"                  validate:"required"`
+	Size        int            `json:"size"                  yaml:"size"                  validate:"required"`
+	QueueSize   int            `json:"queueSize"             yaml:"queueSize"             validate:"required"`
+	TenantLabel string         `json:"tenantLabel,omitempty" yaml:"tenantLabel,omitempty"`
+	Weights     map[string]int `json:"weights,omitempty"     yaml:"weights,omitempty"`
+	Autoscale   *AutoscaleCfg  `json:"autoscale,omitempty"   yaml:"autoscale,omitempty"`
 }
 
 type Cfg struct {
 	Pools []*PoolCfg `json:"pools" yaml:"pools" env:"POOLS"`
+	// Store persists unfinished tasks, they are reloaded and resumed from their checkpoint on start.
+	// Tasks are only kept in memory if it is nil.
+	Store *kvs.Cfg `json:"store" yaml:"store"`
+	// StoreSecretKey is base64 of a 16, 24 or 32 bytes AES key, secrets in cfgs of steps are encrypted by it
+	// when tasks are persisted, e.g. env://TASKD_SECRET_KEY. If it is empty secrets are not persisted,
+	// tasks whose secrets can not be restored are not recovered.
+	StoreSecretKey secret.String `json:"storeSecretKey" yaml:"storeSecretKey"`
+	// Schedules added on init, schedules can also be added by Taskd.AddSchedule.
+	Schedules []*ScheduleCfg `json:"schedules" yaml:"schedules"`
+	// RateLimits are fixed rate limits shared by pipelines of all tasks, they are reloadable.
+	// A pipeline waits on one by rate limiter type shared with its name.
+	RateLimits map[string]*ratelimit.FixedRateLimiterCfg `json:"rateLimits" yaml:"rateLimits"`
 }
 
 func NewCfg() *Cfg {
@@ -0,0 +1,290 @@
+package taskd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/runner"
+	"github.com/donkeywon/golib/task"
+)
+
+// maxFinishedTasks is the max number of finished tasks whose results are kept for dependencies.
+const maxFinishedTasks = 4096
+
+// waitingTask is a created task waiting for its dependencies, it is initialized and submitted when they are met.
+type waitingTask struct {
+	t         *task.Task
+	extra     *task.HookExtraData
+	afterInit []task.Hook
+}
+
+// createWait create a task with dependencies, it is submitted if dependencies are all done,
+// otherwise it is held as waiting until they are.
+func (td *taskd) createWait(ctx context.Context, taskCfg *task.Cfg, wait bool, extra *task.HookExtraData, afterInit ...task.Hook) (*task.Task, error) {
+	t, err := td.create(ctx, taskCfg, extra)
+	if err != nil {
+		td.unmarkTaskID(taskCfg.ID)
+		return nil, errs.Wrap(err, "create task failed")
+	}
+
+	td.mu.Lock()
+	ready, err := td.checkDependencies(taskCfg)
+	if err == nil && !ready {
+		delete(td.taskIDMap, taskCfg.ID)
+		td.taskWaitingMap[taskCfg.ID] = &waitingTask{t: t, extra: extra, afterInit: afterInit}
+	}
+	td.mu.Unlock()
+	if err != nil {
+		td.unmarkTaskID(taskCfg.ID)
+		return nil, err
+	}
+
+	if ready {
+		err = td.init(t, extra, afterInit...)
+		if err != nil {
+			td.unmarkTaskID(taskCfg.ID)
+			return nil, errs.Wrap(err, "init task failed")
+		}
+		err = td.saveSubmit(t, wait)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	td.Info("task is waiting for dependencies", "task_id", taskCfg.ID)
+	err = td.saveTask(t, taskStateWaiting)
+	if err != nil {
+		td.Error("persist waiting task failed", err, "task_id", taskCfg.ID)
+	}
+	if wait {
+		select {
+		case <-t.Done():
+		case <-ctx.Done():
+		}
+	}
+	return t, nil
+}
+
+// checkDependencies return whether dependencies of cfg are all done and met, td.mu must be held.
+// A dependency which is pending, running, paused or waiting is not done.
+func (td *taskd) checkDependencies(cfg *task.Cfg) (bool, error) {
+	if td.hasCycle(cfg.ID, cfg.DependsOn, make(map[string]bool)) {
+		return false, errs.Wrapf(ErrDependencyCycle, "task %s", cfg.ID)
+	}
+
+	ready := true
+	for _, dep := range cfg.DependsOn {
+		if td.isTaskActive(dep.TaskID) {
+			ready = false
+			continue
+		}
+		succeeded, finished := td.finishedMap[dep.TaskID]
+		if !finished {
+			return false, errs.Wrapf(ErrDependencyNotExists, "task %s depends on %s", cfg.ID, dep.TaskID)
+		}
+		if !dep.Met(succeeded) {
+			return false, errs.Wrapf(ErrDependencyNotMet, "task %s depends on %s with condition %s", cfg.ID, dep.TaskID, dep.Condition)
+		}
+	}
+	return ready, nil
+}
+
+// hasCycle return whether taskID is reachable from deps through waiting tasks, td.mu must be held.
+// Only waiting tasks can have unfinished dependencies, so a cycle must go through them.
+func (td *taskd) hasCycle(taskID string, deps []*task.Dependency, visited map[string]bool) bool {
+	for _, dep := range deps {
+		if dep.TaskID == taskID {
+			return true
+		}
+		if visited[dep.TaskID] {
+			continue
+		}
+		visited[dep.TaskID] = true
+		w, exists := td.taskWaitingMap[dep.TaskID]
+		if exists && td.hasCycle(taskID, w.t.Cfg.DependsOn, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTaskActive return whether task is not finished, td.mu must be held.
+func (td *taskd) isTaskActive(taskID string) bool {
+	_, exists := td.taskIDMap[taskID]
+	if exists {
+		return true
+	}
+	_, exists = td.taskPausedMap[taskID]
+	if exists {
+		return true
+	}
+	_, exists = td.taskWaitingMap[taskID]
+	return exists
+}
+
+// markTaskFinished record and persist result of a finished task.
+func (td *taskd) markTaskFinished(taskID string, succeeded bool) {
+	dropped := td.recordFinished(taskID, succeeded)
+	td.saveFinished(taskID, succeeded)
+	if dropped != "" {
+		td.deleteFinished(dropped)
+	}
+}
+
+// recordFinished record result of a finished task, the oldest is dropped and returned if too many.
+func (td *taskd) recordFinished(taskID string, succeeded bool) string {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	if _, exists := td.finishedMap[taskID]; !exists {
+		td.finishedIDs = append(td.finishedIDs, taskID)
+	}
+	td.finishedMap[taskID] = succeeded
+	if len(td.finishedIDs) <= maxFinishedTasks {
+		return ""
+	}
+	dropped := td.finishedIDs[0]
+	delete(td.finishedMap, dropped)
+	td.finishedIDs = td.finishedIDs[1:]
+	return dropped
+}
+
+// resolveWaiting submit waiting tasks whose dependencies are all done after taskID is done,
+// tasks whose dependencies are not met are canceled.
+func (td *taskd) resolveWaiting(taskID string) {
+	select {
+	case <-td.Stopping():
+		// keep waiting in store, resolved on next start
+		return
+	default:
+	}
+
+	type resolved struct {
+		w   *waitingTask
+		err error
+	}
+	var rs []resolved
+	td.mu.Lock()
+	for id, w := range td.taskWaitingMap {
+		if !dependsOn(w.t.Cfg, taskID) {
+			continue
+		}
+		ready, err := td.checkDependencies(w.t.Cfg)
+		if err == nil && !ready {
+			continue
+		}
+		delete(td.taskWaitingMap, id)
+		if err == nil {
+			td.taskIDMap[id] = struct{}{}
+		}
+		rs = append(rs, resolved{w: w, err: err})
+	}
+	td.mu.Unlock()
+
+	for _, r := range rs {
+		if r.err != nil {
+			td.cancelWaiting(r.w, r.err, false)
+			continue
+		}
+		td.submitWaiting(r.w)
+	}
+}
+
+// submitWaiting init and submit a waiting task whose dependencies are met.
+func (td *taskd) submitWaiting(w *waitingTask) {
+	err := td.init(w.t, w.extra, w.afterInit...)
+	if err != nil {
+		td.unmarkTaskID(w.t.Cfg.ID)
+		td.deleteTask(w.t.Cfg.ID)
+		td.markTaskFinished(w.t.Cfg.ID, false)
+		td.Error("init waiting task failed", err, "task_id", w.t.Cfg.ID)
+		td.hookTask(w.t, err, td.doneHooks, "done", w.extra)
+		td.resolveWaiting(w.t.Cfg.ID)
+		return
+	}
+
+	err = td.saveSubmit(w.t, false)
+	if err != nil && !errors.Is(err, ErrStopping) {
+		// e.g. queue is full or pool is deleted, it will never run, fail it like its init failed
+		w.t.AppendError(err)
+		runner.Stop(w.t)
+		td.markTaskFinished(w.t.Cfg.ID, false)
+		td.Error("submit waiting task failed", err, "task_id", w.t.Cfg.ID)
+		td.hookTask(w.t, w.t.Err(), td.doneHooks, "done", w.extra)
+		td.resolveWaiting(w.t.Cfg.ID)
+		return
+	}
+	if err != nil {
+		return
+	}
+	td.Info("submit waiting task", "task_id", w.t.Cfg.ID)
+}
+
+// cancelWaiting cancel a waiting task which is removed from waiting map,
+// waiting tasks depend on it are canceled too if cascade, otherwise they are resolved as it failed.
+func (td *taskd) cancelWaiting(w *waitingTask, err error, cascade bool) {
+	taskID := w.t.Cfg.ID
+	if err != nil {
+		w.t.AppendError(err)
+	}
+	runner.Stop(w.t)
+	td.deleteTask(taskID)
+	td.markTaskFinished(taskID, false)
+	td.Info("cancel waiting task", "task_id", taskID, "reason", err)
+	td.hookTask(w.t, w.t.Err(), td.doneHooks, "done", w.extra)
+
+	if cascade {
+		td.cancelDownstream(taskID)
+	} else {
+		td.resolveWaiting(taskID)
+	}
+}
+
+// cancelDownstream cancel waiting tasks depend on taskID directly or indirectly, because taskID is stopped.
+func (td *taskd) cancelDownstream(taskID string) {
+	var ws []*waitingTask
+	td.mu.Lock()
+	for id, w := range td.taskWaitingMap {
+		if dependsOn(w.t.Cfg, taskID) {
+			delete(td.taskWaitingMap, id)
+			ws = append(ws, w)
+		}
+	}
+	td.mu.Unlock()
+
+	for _, w := range ws {
+		td.cancelWaiting(w, errs.Wrapf(ErrUpstreamTaskStopped, "upstream task %s", taskID), true)
+	}
+}
+
+// stopWaiting stop waiting tasks on taskd stopping, they are kept in store.
+func (td *taskd) stopWaiting() {
+	td.mu.RLock()
+	ws := make([]*waitingTask, 0, len(td.taskWaitingMap))
+	for _, w := range td.taskWaitingMap {
+		ws = append(ws, w)
+	}
+	td.mu.RUnlock()
+
+	for _, w := range ws {
+		runner.Stop(w.t)
+	}
+}
+
+func (td *taskd) unmarkTaskIfWaiting(taskID string) *waitingTask {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	w := td.taskWaitingMap[taskID]
+	delete(td.taskWaitingMap, taskID)
+	return w
+}
+
+func dependsOn(cfg *task.Cfg, taskID string) bool {
+	for _, dep := range cfg.DependsOn {
+		if dep.TaskID == taskID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package taskd
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alitto/pond/v2"
+	"github.com/donkeywon/golib/task"
+)
+
+var (
+	ErrQueueFull         = errors.New("queue full")
+	ErrPoolAlreadyExists = errors.New("pool already exists")
+	ErrPoolDraining      = errors.New("pool draining")
+	ErrPoolBusy          = errors.New("pool has queued or running tasks")
+)
+
+// pool run tasks by a pond.Pool, tasks beyond its max concurrency are queued in pool rather than pond.
+// Queued tasks are dispatched by priority, tasks with the same priority are shared between tenants
+// by their weights, so that a tenant with many tasks can not starve others.
+type pool struct {
+	pond.Pool
+
+	mu      sync.Mutex
+	cfg     *PoolCfg
+	seq     uint64 // seq of items submitted
+	round   uint64 // seq of items dispatched
+	waiting int
+	running int
+	tenants map[string]*tenant
+
+	draining bool
+	deleted  bool
+	drained  chan struct{} // closed when pool is draining and no task is queued or running
+
+	lastScaled time.Time // accessed by autoscaler only
+}
+
+type tenant struct {
+	running    int
+	dispatched uint64 // round of last dispatch, tenants dispatched earlier go first on tie
+	items      queueItems
+}
+
+type queueItem struct {
+	f        func()
+	reject   func(error) // run instead of f if it can not run
+	priority int
+	seq      uint64
+	done     chan struct{}
+}
+
+// queueItems is a heap of items, higher priority first, then first in first out.
+type queueItems []*queueItem
+
+func (q queueItems) Len() int { return len(q) }
+
+func (q queueItems) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q queueItems) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *queueItems) Push(x any) { *q = append(*q, x.(*queueItem)) }
+
+func (q *queueItems) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
+
+func newPool(cfg *PoolCfg) *pool {
+	return &pool{
+		Pool:    pond.NewPool(cfg.Size),
+		cfg:     cfg,
+		tenants: make(map[string]*tenant),
+		drained: make(chan struct{}),
+	}
+}
+
+// submit queue f of task, the returned chan is closed after f is done.
+// ErrQueueFull is returned if there are QueueSize tasks queued.
+// If pool is stopped before f runs, reject is run with the error instead, the chan is closed after it is done.
+func (p *pool) submit(cfg *task.Cfg, f func(), reject func(error)) (<-chan struct{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.deleted {
+		return nil, ErrPoolNotExists
+	}
+	if p.draining {
+		return nil, ErrPoolDraining
+	}
+	if p.cfg.QueueSize > 0 && p.waiting >= p.cfg.QueueSize {
+		return nil, ErrQueueFull
+	}
+
+	name := p.tenantOf(cfg)
+	tn, exists := p.tenants[name]
+	if !exists {
+		tn = &tenant{}
+		p.tenants[name] = tn
+	}
+	p.seq++
+	item := &queueItem{f: f, reject: reject, priority: cfg.Priority, seq: p.seq, done: make(chan struct{})}
+	heap.Push(&tn.items, item)
+	p.waiting++
+
+	p.dispatch()
+	return item.done, nil
+}
+
+// dispatch run queued tasks until max concurrency is reached, p.mu must be held.
+func (p *pool) dispatch() {
+	for p.waiting > 0 && p.running < p.MaxConcurrency() {
+		name, tn := p.next()
+		item := heap.Pop(&tn.items).(*queueItem)
+		p.round++
+		tn.running++
+		tn.dispatched = p.round
+		p.waiting--
+		p.running++
+
+		err := p.Go(func() {
+			defer p.finish(name, item)
+			item.f()
+		})
+		if err != nil {
+			// pool is stopped, reject without p.mu held, it may submit other tasks
+			p.running--
+			tn.running--
+			go func() {
+				defer close(item.done)
+				item.reject(err)
+			}()
+		}
+	}
+}
+
+// next return tenant of the next task to run, p.mu must be held.
+// It is the tenant with the highest priority task, and the least running tasks by weight on tie.
+func (p *pool) next() (string, *tenant) {
+	var (
+		name string
+		tn   *tenant
+	)
+	for n, t := range p.tenants {
+		if t.items.Len() == 0 {
+			continue
+		}
+		if tn == nil || p.before(n, t, name, tn) {
+			name, tn = n, t
+		}
+	}
+	return name, tn
+}
+
+func (p *pool) before(n1 string, t1 *tenant, n2 string, t2 *tenant) bool {
+	if pr1, pr2 := t1.items[0].priority, t2.items[0].priority; pr1 != pr2 {
+		return pr1 > pr2
+	}
+	// t1.running/w1 < t2.running/w2
+	if share1, share2 := t1.running*p.weight(n2), t2.running*p.weight(n1); share1 != share2 {
+		return share1 < share2
+	}
+	return t1.dispatched < t2.dispatched
+}
+
+func (p *pool) finish(name string, item *queueItem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	close(item.done)
+	p.running--
+	tn := p.tenants[name]
+	tn.running--
+	if tn.running == 0 && tn.items.Len() == 0 {
+		delete(p.tenants, name)
+	}
+	p.dispatch()
+	p.checkDrained()
+}
+
+// drain reject new tasks, the returned chan is closed when queued and running tasks are all done.
+func (p *pool) drain() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.draining {
+		p.draining = true
+		p.checkDrained()
+	}
+	return p.drained
+}
+
+// checkDrained close drained if pool is draining and idle, p.mu must be held.
+func (p *pool) checkDrained() {
+	if p.draining && p.waiting == 0 && p.running == 0 {
+		select {
+		case <-p.drained:
+		default:
+			close(p.drained)
+		}
+	}
+}
+
+// delete mark pool deleted if it is idle, it rejects new tasks after deleted.
+func (p *pool) delete() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.waiting > 0 || p.running > 0 {
+		return ErrPoolBusy
+	}
+	p.deleted = true
+	return nil
+}
+
+func (p *pool) name() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg.Name
+}
+
+func (p *pool) autoscaleCfg() *AutoscaleCfg {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg.Autoscale
+}
+
+func (p *pool) tenantOf(cfg *task.Cfg) string {
+	if p.cfg.TenantLabel != "" {
+		return cfg.Labels[p.cfg.TenantLabel]
+	}
+	return string(cfg.Type)
+}
+
+func (p *pool) weight(name string) int {
+	w := p.cfg.Weights[name]
+	if w <= 0 {
+		return 1
+	}
+	return w
+}
+
+// Resize change max concurrency and dispatch queued tasks if it is increased.
+func (p *pool) Resize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Pool.Resize(size)
+	p.dispatch()
+}
+
+// reload apply cfg, tasks already queued are kept even if queue size is decreased.
+func (p *pool) reload(cfg *PoolCfg) {
+	p.mu.Lock()
+	p.cfg = cfg
+	p.mu.Unlock()
+	if p.MaxConcurrency() != cfg.Size {
+		p.Resize(cfg.Size)
+	}
+}
+
+func (p *pool) QueueSize() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg.QueueSize
+}
+
+// WaitingTasks return number of tasks queued.
+func (p *pool) WaitingTasks() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return uint64(p.waiting)
+}
+
+// RunningWorkers return number of tasks running.
+func (p *pool) RunningWorkers() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return int64(p.running)
+}
@@ -0,0 +1,46 @@
+package taskd
+
+import (
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/ratelimit"
+	"github.com/donkeywon/golib/runner"
+)
+
+func validateRateLimits(cfgs map[string]*ratelimit.FixedRateLimiterCfg) error {
+	for name, cfg := range cfgs {
+		err := cfg.Validate()
+		if err != nil {
+			return errs.Wrapf(err, "invalid rate limit %s", name)
+		}
+	}
+	return nil
+}
+
+// applyRateLimits create added shared rate limiters and reload existing ones, td.pmu must be held.
+// Rate limiters not in cfgs are kept, because pipelines may be waiting on them.
+func (td *taskd) applyRateLimits(cfgs map[string]*ratelimit.FixedRateLimiterCfg) error {
+	for name, cfg := range cfgs {
+		rl, exists := td.rateLimiters[name]
+		if exists {
+			if *rl.FixedRateLimiterCfg != *cfg {
+				td.Info("reload rate limit", "name", name, "n", cfg.N, "burst", cfg.Burst)
+			}
+			err := rl.Reload(cfg)
+			if err != nil {
+				return errs.Wrapf(err, "reload rate limit %s failed", name)
+			}
+			continue
+		}
+
+		rl = ratelimit.NewFixedRateLimiter()
+		rl.FixedRateLimiterCfg = cfg
+		rl.Inherit(td)
+		err := runner.Init(rl)
+		if err != nil {
+			return errs.Wrapf(err, "init rate limit %s failed", name)
+		}
+		td.rateLimiters[name] = rl
+		ratelimit.SetShared(name, rl)
+	}
+	return nil
+}
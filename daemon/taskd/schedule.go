@@ -0,0 +1,389 @@
+package taskd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/task"
+	"github.com/donkeywon/golib/util/cron"
+	"github.com/donkeywon/golib/util/rands"
+	"github.com/donkeywon/golib/util/reflects"
+	"github.com/donkeywon/golib/util/v"
+)
+
+type OverlapPolicy string
+
+const (
+	// OverlapSkip skip the tick if the last instance is not done.
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue run the tick after the last instance is done.
+	OverlapQueue OverlapPolicy = "queue"
+	// OverlapReplace stop the last instance and run the tick.
+	OverlapReplace OverlapPolicy = "replace"
+)
+
+const DefaultScheduleMaxHistory = 10
+
+var (
+	ErrScheduleNotExists     = errors.New("schedule not exists")
+	ErrScheduleAlreadyExists = errors.New("schedule already exists")
+)
+
+// ScheduleCfg create a task instance from Task on every tick of Cron or Interval in seconds,
+// the instance id is the schedule id followed by the tick time in unix nano.
+// Ticks are delayed randomly up to Jitter seconds. Cron is evaluated in TimeZone, default is local.
+type ScheduleCfg struct {
+	ID         string        `json:"id"         validate:"required" yaml:"id"`
+	Cron       string        `json:"cron"       yaml:"cron"`
+	Interval   int           `json:"interval"   yaml:"interval"`
+	TimeZone   string        `json:"timeZone"   yaml:"timeZone"`
+	Jitter     int           `json:"jitter"     yaml:"jitter"`
+	Overlap    OverlapPolicy `json:"overlap"    yaml:"overlap"`
+	MaxHistory int           `json:"maxHistory" yaml:"maxHistory"`
+	Paused     bool          `json:"paused"     yaml:"paused"`
+	Task       *task.Cfg     `json:"task"       validate:"required" yaml:"task"`
+}
+
+func NewScheduleCfg() *ScheduleCfg {
+	return &ScheduleCfg{
+		Overlap:    OverlapSkip,
+		MaxHistory: DefaultScheduleMaxHistory,
+	}
+}
+
+// ScheduleRun is a tick of schedule, TaskID is empty if the tick is skipped.
+// Err is the error of submitting or running the instance.
+type ScheduleRun struct {
+	TaskID  string    `json:"taskId"          yaml:"taskId"`
+	Time    time.Time `json:"time"            yaml:"time"`
+	Skipped bool      `json:"skipped"         yaml:"skipped"`
+	Err     string    `json:"err,omitempty"   yaml:"err,omitempty"`
+}
+
+type ScheduleInfo struct {
+	Cfg     *ScheduleCfg   `json:"cfg"     yaml:"cfg"`
+	Paused  bool           `json:"paused"  yaml:"paused"`
+	Next    time.Time      `json:"next"    yaml:"next"`
+	Queued  int            `json:"queued"  yaml:"queued"`
+	History []*ScheduleRun `json:"history" yaml:"history"` // latest MaxHistory ticks, oldest first
+}
+
+type schedule struct {
+	cfg  *ScheduleCfg
+	cron *cron.Schedule
+	loc  *time.Location
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	paused  bool
+	deleted bool
+	next    time.Time
+	queued  int
+	last    *task.Task
+	history []*ScheduleRun
+}
+
+func (td *taskd) AddSchedule(cfg *ScheduleCfg) error {
+	select {
+	case <-td.Stopping():
+		return ErrStopping
+	default:
+	}
+
+	s, err := td.newSchedule(cfg)
+	if err != nil {
+		return errs.Wrapf(err, "invalid schedule: %s", cfg.ID)
+	}
+
+	td.smu.Lock()
+	defer td.smu.Unlock()
+	if _, exists := td.schedules[cfg.ID]; exists {
+		return ErrScheduleAlreadyExists
+	}
+	td.schedules[cfg.ID] = s
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		td.arm(s)
+	}
+	td.Info("schedule added", "schedule_id", cfg.ID, "cron", cfg.Cron, "interval", cfg.Interval, "paused", s.paused)
+	return nil
+}
+
+func (td *taskd) DeleteSchedule(scheduleID string) error {
+	td.smu.Lock()
+	s, exists := td.schedules[scheduleID]
+	delete(td.schedules, scheduleID)
+	td.smu.Unlock()
+	if !exists {
+		return ErrScheduleNotExists
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = true
+	s.disarm()
+	return nil
+}
+
+// PauseSchedule stop creating instances, the running instance is not affected.
+func (td *taskd) PauseSchedule(scheduleID string) error {
+	s := td.getSchedule(scheduleID)
+	if s == nil {
+		return ErrScheduleNotExists
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = true
+	s.disarm()
+	return nil
+}
+
+func (td *taskd) ResumeSchedule(scheduleID string) error {
+	s := td.getSchedule(scheduleID)
+	if s == nil {
+		return ErrScheduleNotExists
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		return nil
+	}
+	s.paused = false
+	td.arm(s)
+	td.drain(s)
+	return nil
+}
+
+func (td *taskd) ListSchedules() []*ScheduleInfo {
+	td.smu.Lock()
+	schedules := make([]*schedule, 0, len(td.schedules))
+	for _, s := range td.schedules {
+		schedules = append(schedules, s)
+	}
+	td.smu.Unlock()
+
+	infos := make([]*ScheduleInfo, len(schedules))
+	for i, s := range schedules {
+		s.mu.Lock()
+		infos[i] = &ScheduleInfo{
+			Cfg:     s.cfg,
+			Paused:  s.paused,
+			Next:    s.next,
+			Queued:  s.queued,
+			History: make([]*ScheduleRun, len(s.history)),
+		}
+		for j, r := range s.history {
+			rr := *r
+			infos[i].History[j] = &rr
+		}
+		s.mu.Unlock()
+	}
+	return infos
+}
+
+func (td *taskd) newSchedule(cfg *ScheduleCfg) (*schedule, error) {
+	if cfg.Task != nil && cfg.Task.ID == "" {
+		cfg.Task.ID = cfg.ID
+	}
+	if cfg.Overlap == "" {
+		cfg.Overlap = OverlapSkip
+	}
+	if cfg.MaxHistory <= 0 {
+		cfg.MaxHistory = DefaultScheduleMaxHistory
+	}
+	err := v.Struct(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &schedule{cfg: cfg, paused: cfg.Paused, loc: time.Local}
+	switch {
+	case cfg.Cron != "" && cfg.Interval > 0:
+		return nil, errs.New("cron and interval are exclusive")
+	case cfg.Cron != "":
+		s.cron, err = cron.Parse(cfg.Cron)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.Interval <= 0:
+		return nil, errs.New("cron or interval is required")
+	}
+	if cfg.TimeZone != "" {
+		s.loc, err = time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid time zone: %s", cfg.TimeZone)
+		}
+	}
+	switch cfg.Overlap {
+	case OverlapSkip, OverlapQueue, OverlapReplace:
+	default:
+		return nil, errs.Errorf("unknown overlap policy: %s", cfg.Overlap)
+	}
+	if td.getPool(cfg.Task) == nil {
+		return nil, ErrPoolNotExists
+	}
+	return s, nil
+}
+
+func (td *taskd) getSchedule(scheduleID string) *schedule {
+	td.smu.Lock()
+	defer td.smu.Unlock()
+	return td.schedules[scheduleID]
+}
+
+// arm set timer of next tick, s.mu must be held.
+func (td *taskd) arm(s *schedule) {
+	now := time.Now()
+	if s.cron != nil {
+		s.next = s.cron.Next(now.In(s.loc))
+		if s.next.IsZero() {
+			td.Warn("schedule has no next tick", "schedule_id", s.cfg.ID, "cron", s.cfg.Cron)
+			return
+		}
+	} else {
+		s.next = now.Add(time.Duration(s.cfg.Interval) * time.Second)
+	}
+	at := s.next
+	delay := at.Sub(now) + time.Duration(rands.RandInt(0, s.cfg.Jitter*1000))*time.Millisecond
+	s.timer = time.AfterFunc(delay, func() {
+		td.fire(s, at)
+	})
+}
+
+// disarm stop timer of next tick, s.mu must be held.
+func (s *schedule) disarm() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.next = time.Time{}
+}
+
+func (td *taskd) fire(s *schedule, at time.Time) {
+	s.mu.Lock()
+	if s.paused || s.deleted {
+		s.mu.Unlock()
+		return
+	}
+	select {
+	case <-td.Stopping():
+		s.mu.Unlock()
+		return
+	default:
+	}
+	td.arm(s)
+
+	last := s.last
+	if last == nil || !td.IsTaskExists(last.Cfg.ID) {
+		td.launch(s, at)
+		s.mu.Unlock()
+		return
+	}
+
+	switch s.cfg.Overlap {
+	case OverlapSkip:
+		td.Info("last instance is not done, skip", "schedule_id", s.cfg.ID, "task_id", last.Cfg.ID)
+		s.record(&ScheduleRun{Time: at, Skipped: true})
+		s.mu.Unlock()
+	case OverlapQueue:
+		td.Info("last instance is not done, queue", "schedule_id", s.cfg.ID, "task_id", last.Cfg.ID)
+		s.queued++
+		s.mu.Unlock()
+	case OverlapReplace:
+		s.mu.Unlock()
+		td.Info("last instance is not done, replace", "schedule_id", s.cfg.ID, "task_id", last.Cfg.ID)
+		err := td.StopTask(last.Cfg.ID)
+		if err != nil && !errors.Is(err, ErrTaskNotExists) {
+			td.Error("stop last instance failed", err, "schedule_id", s.cfg.ID, "task_id", last.Cfg.ID)
+		}
+		<-last.Done()
+
+		s.mu.Lock()
+		if !s.paused && !s.deleted {
+			td.launch(s, at)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// launch submit an instance of s, s.mu must be held.
+func (td *taskd) launch(s *schedule, at time.Time) {
+	cfg := reflects.DeepCopy(s.cfg.Task)
+	cfg.ID = fmt.Sprintf("%s-%d", s.cfg.ID, at.UnixNano())
+	r := &ScheduleRun{TaskID: cfg.ID, Time: at}
+	s.record(r)
+
+	td.smu.Lock()
+	td.instances[cfg.ID] = s
+	td.smu.Unlock()
+
+	t, err := td.SubmitTask(cfg)
+	if err != nil {
+		td.smu.Lock()
+		delete(td.instances, cfg.ID)
+		td.smu.Unlock()
+		td.Error("submit scheduled task failed", err, "schedule_id", s.cfg.ID, "task_id", cfg.ID)
+		r.Err = err.Error()
+		return
+	}
+	s.last = t
+}
+
+// onInstanceDone record error of instance and launch a queued tick, it is a done hook of task.
+func (td *taskd) onInstanceDone(t *task.Task, err error, _ *task.HookExtraData) {
+	td.smu.Lock()
+	s, exists := td.instances[t.Cfg.ID]
+	delete(td.instances, t.Cfg.ID)
+	td.smu.Unlock()
+	if !exists {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		for _, r := range s.history {
+			if r.TaskID == t.Cfg.ID {
+				r.Err = err.Error()
+			}
+		}
+	}
+	td.drain(s)
+}
+
+// drain launch a queued tick if the last instance is done, s.mu must be held.
+func (td *taskd) drain(s *schedule) {
+	if s.queued == 0 || s.paused || s.deleted {
+		return
+	}
+	if s.last != nil && td.IsTaskExists(s.last.Cfg.ID) {
+		return
+	}
+	s.queued--
+	td.launch(s, time.Now())
+}
+
+// record append r to history, s.mu must be held.
+func (s *schedule) record(r *ScheduleRun) {
+	s.history = append(s.history, r)
+	if len(s.history) > s.cfg.MaxHistory {
+		s.history = s.history[len(s.history)-s.cfg.MaxHistory:]
+	}
+}
+
+func (td *taskd) stopSchedules() {
+	td.smu.Lock()
+	defer td.smu.Unlock()
+	for _, s := range td.schedules {
+		s.mu.Lock()
+		s.disarm()
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,387 @@
+package taskd
+
+import (
+	"cmp"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/kvs"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/task"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/secret"
+)
+
+const (
+	storeKeyPrefix         = "task/"
+	storeKeyFinishedPrefix = "finished/"
+)
+
+type taskState string
+
+const (
+	taskStatePending taskState = "pending"
+	taskStateRunning taskState = "running"
+	taskStatePaused  taskState = "paused"
+	taskStateWaiting taskState = "waiting"
+)
+
+// taskRecord is the persisted state of an unfinished task, values of task and steps are restored on resume.
+// Secrets are secrets in Cfg by their paths, sealed by Cfg.StoreSecretKey, because secrets are redacted when Cfg is marshaled.
+type taskRecord struct {
+	Cfg            *task.Cfg         `json:"cfg"`
+	Secrets        map[string]string `json:"secrets,omitempty"`
+	State          taskState         `json:"state"`
+	Data           map[string]any    `json:"data"`
+	StepsData      []map[string]any  `json:"stepsData"`
+	DeferStepsData []map[string]any  `json:"deferStepsData"`
+}
+
+// finishedRecord is the persisted result of a finished task, for dependencies of tasks recovered.
+type finishedRecord struct {
+	Succeeded bool  `json:"succeeded"`
+	Time      int64 `json:"time"`
+}
+
+func newTaskRecord(t *task.Task, state taskState) *taskRecord {
+	r := &taskRecord{
+		Cfg:   t.Cfg,
+		State: state,
+		Data:  t.LoadAll(),
+	}
+	for _, st := range t.Steps() {
+		r.StepsData = append(r.StepsData, st.LoadAll())
+	}
+	for _, st := range t.DeferSteps() {
+		r.DeferStepsData = append(r.DeferStepsData, st.LoadAll())
+	}
+	return r
+}
+
+// newSecretAEAD create AES-GCM to seal persisted secrets from base64 of a 16, 24 or 32 bytes key, nil if key is empty.
+func newSecretAEAD(key secret.String) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, nil
+	}
+	bs, err := base64.StdEncoding.DecodeString(key.Value())
+	if err != nil {
+		return nil, errs.Wrap(err, "decode store secret key failed")
+	}
+	block, err := aes.NewCipher(bs)
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid store secret key")
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSecrets encrypt secrets in Cfg by aead, secrets are not kept if aead is nil,
+// the task is not recovered then.
+func (r *taskRecord) sealSecrets(aead cipher.AEAD) error {
+	var errss []error
+	secret.Walk(r.Cfg, func(path string, s secret.String) secret.String {
+		if s == "" || aead == nil {
+			return s
+		}
+		nonce := make([]byte, aead.NonceSize())
+		_, err := rand.Read(nonce)
+		if err != nil {
+			errss = append(errss, errs.Wrapf(err, "generate nonce of secret %s failed", path))
+			return s
+		}
+		if r.Secrets == nil {
+			r.Secrets = make(map[string]string)
+		}
+		// path is authenticated, so a sealed secret can not be moved to another field
+		r.Secrets[path] = base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(s.Value()), []byte(path)))
+		return s
+	})
+	return errors.Join(errss...)
+}
+
+// restoreSecrets set secrets in Cfg back to plaintext, error is returned if any secret can not be restored.
+func (r *taskRecord) restoreSecrets(aead cipher.AEAD) error {
+	var (
+		errss    []error
+		restored int
+	)
+	secret.Walk(r.Cfg, func(path string, s secret.String) secret.String {
+		sealed, exists := r.Secrets[path]
+		if exists && aead != nil {
+			v, err := openSecret(aead, path, sealed)
+			if err != nil {
+				errss = append(errss, errs.Wrapf(err, "open secret %s failed", path))
+				return s
+			}
+			restored++
+			return secret.String(v)
+		}
+		if s.Value() == secret.Redacted {
+			errss = append(errss, errs.Errorf("secret %s is redacted", path))
+		}
+		return s
+	})
+	if restored != len(r.Secrets) {
+		errss = append(errss, errs.Errorf("%d of %d secrets are restored", restored, len(r.Secrets)))
+	}
+	return errors.Join(errss...)
+}
+
+func openSecret(aead cipher.AEAD, path string, sealed string) (string, error) {
+	bs, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(bs) < aead.NonceSize() {
+		return "", errs.New("sealed secret too short")
+	}
+	v, err := aead.Open(nil, bs[:aead.NonceSize()], bs[aead.NonceSize():], []byte(path))
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
+
+// restore values to t, it must be called after steps of t are created.
+func (r *taskRecord) restore(t *task.Task) {
+	for k, v := range r.Data {
+		t.Store(k, v)
+	}
+	for i, st := range t.Steps() {
+		if i < len(r.StepsData) {
+			for k, v := range r.StepsData[i] {
+				st.Store(k, v)
+			}
+		}
+	}
+	for i, st := range t.DeferSteps() {
+		if i < len(r.DeferStepsData) {
+			for k, v := range r.DeferStepsData[i] {
+				st.Store(k, v)
+			}
+		}
+	}
+}
+
+func (td *taskd) openStore() error {
+	if td.cfg.Store == nil {
+		return nil
+	}
+	if td.cfg.Store.Cfg == nil {
+		return errs.Errorf("kvs type %s is not registered", td.cfg.Store.Type)
+	}
+	td.store = plugin.CreateWithCfg[kvs.KVS](td.cfg.Store.Type, td.cfg.Store.Cfg)
+	err := td.store.Open()
+	if err != nil {
+		td.store = nil
+		return errs.Wrap(err, "open store failed")
+	}
+	return nil
+}
+
+func (td *taskd) closeStore() {
+	if td.store == nil {
+		return
+	}
+	err := td.store.Close()
+	if err != nil {
+		td.Error("close store failed", err)
+	}
+}
+
+func (td *taskd) saveTask(t *task.Task, state taskState) error {
+	if td.store == nil {
+		return nil
+	}
+	r := newTaskRecord(t, state)
+	err := r.sealSecrets(td.secretAEAD)
+	if err != nil {
+		return err
+	}
+	s, err := jsons.MarshalString(r)
+	if err != nil {
+		return errs.Wrap(err, "marshal task record failed")
+	}
+	return td.store.Store(storeKeyPrefix+t.Cfg.ID, s)
+}
+
+// persistTask save t and log on failure, a task is not failed by its persistence.
+func (td *taskd) persistTask(t *task.Task, state taskState) {
+	err := td.saveTask(t, state)
+	if err != nil {
+		td.Error("persist task failed", err, "task_id", t.Cfg.ID, "state", state)
+	}
+}
+
+func (td *taskd) deleteTask(taskID string) {
+	if td.store == nil {
+		return
+	}
+	err := td.store.Del(storeKeyPrefix + taskID)
+	if err != nil {
+		td.Error("delete persisted task failed", err, "task_id", taskID)
+	}
+}
+
+// loadTasks load all unfinished tasks from store.
+func (td *taskd) loadTasks() ([]*taskRecord, error) {
+	var (
+		records []*taskRecord
+		errss   []error
+	)
+	err := td.store.Range(func(k string, v any) bool {
+		taskID, ok := strings.CutPrefix(k, storeKeyPrefix)
+		if !ok {
+			return true
+		}
+		s, ok := v.(string)
+		if !ok {
+			errss = append(errss, errs.Errorf("invalid task record: %s", taskID))
+			return true
+		}
+		r := &taskRecord{}
+		err := jsons.UnmarshalString(s, r)
+		if err != nil {
+			errss = append(errss, errs.Wrapf(err, "unmarshal task record failed: %s", taskID))
+			return true
+		}
+		// resuming with redacted secrets would run the task with wrong credentials
+		err = r.restoreSecrets(td.secretAEAD)
+		if err != nil {
+			errss = append(errss, errs.Wrapf(err, "restore secrets of task failed: %s", taskID))
+			return true
+		}
+		records = append(records, r)
+		return true
+	})
+	if err != nil {
+		return nil, errs.Wrap(err, "range store failed")
+	}
+	for _, err := range errss {
+		td.Error("load task failed", err)
+	}
+	return records, nil
+}
+
+func (td *taskd) saveFinished(taskID string, succeeded bool) {
+	if td.store == nil {
+		return
+	}
+	s, err := jsons.MarshalString(&finishedRecord{Succeeded: succeeded, Time: time.Now().UnixNano()})
+	if err == nil {
+		err = td.store.Store(storeKeyFinishedPrefix+taskID, s)
+	}
+	if err != nil {
+		td.Error("persist finished task failed", err, "task_id", taskID)
+	}
+}
+
+func (td *taskd) deleteFinished(taskID string) {
+	if td.store == nil {
+		return
+	}
+	err := td.store.Del(storeKeyFinishedPrefix + taskID)
+	if err != nil {
+		td.Error("delete persisted finished task failed", err, "task_id", taskID)
+	}
+}
+
+// loadFinished load results of finished tasks from store, oldest first.
+func (td *taskd) loadFinished() {
+	type finished struct {
+		taskID string
+		r      *finishedRecord
+	}
+	var fs []finished
+	err := td.store.Range(func(k string, v any) bool {
+		taskID, ok := strings.CutPrefix(k, storeKeyFinishedPrefix)
+		if !ok {
+			return true
+		}
+		r := &finishedRecord{}
+		s, ok := v.(string)
+		if !ok || jsons.UnmarshalString(s, r) != nil {
+			td.Error("load finished task failed", errs.Errorf("invalid finished task record: %s", taskID))
+			return true
+		}
+		fs = append(fs, finished{taskID: taskID, r: r})
+		return true
+	})
+	if err != nil {
+		td.Error("range store failed", err)
+		return
+	}
+
+	slices.SortFunc(fs, func(a, b finished) int {
+		return cmp.Compare(a.r.Time, b.r.Time)
+	})
+	for _, f := range fs {
+		dropped := td.recordFinished(f.taskID, f.r.Succeeded)
+		if dropped != "" {
+			td.deleteFinished(dropped)
+		}
+	}
+}
+
+// recoverTasks resume unfinished tasks in store from their checkpoint, paused tasks are kept paused.
+// Results of finished tasks are loaded first, so that waiting tasks depend on them can be resolved.
+func (td *taskd) recoverTasks() {
+	if td.store == nil {
+		return
+	}
+	td.loadFinished()
+	records, err := td.loadTasks()
+	if err != nil {
+		td.Error("load tasks from store failed", err)
+		return
+	}
+
+	// paused tasks first, so that tasks depend on them are waiting
+	var pending []*taskRecord
+	for _, r := range records {
+		if r.State != taskStatePaused {
+			pending = append(pending, r)
+			continue
+		}
+		t, err := td.createTask(r.Cfg)
+		if err != nil {
+			td.Error("recover paused task failed", err, "task_id", r.Cfg.ID)
+			continue
+		}
+		td.markTaskRecovered(t, r)
+		td.Info("recover paused task", "task_id", r.Cfg.ID)
+	}
+
+	// a task may be loaded before its dependencies, retry it until no more task is recovered
+	for len(pending) > 0 {
+		var retry []*taskRecord
+		for _, r := range pending {
+			// dependencies of a task not waiting are done before it was submitted
+			_, err = td.createInitSubmit(td.Ctx(), r.Cfg, false, r.State == taskStateWaiting, func(t *task.Task, err error, hed *task.HookExtraData) {
+				r.restore(t)
+			})
+			if errors.Is(err, ErrDependencyNotExists) {
+				retry = append(retry, r)
+				continue
+			}
+			if err != nil {
+				td.Error("recover task failed", err, "task_id", r.Cfg.ID, "cur_step_idx", r.Cfg.CurStepIdx)
+				continue
+			}
+			td.Info("recover task", "task_id", r.Cfg.ID, "cur_step_idx", r.Cfg.CurStepIdx, "state", r.State)
+		}
+		if len(retry) == len(pending) {
+			for _, r := range retry {
+				td.Error("recover task failed", ErrDependencyNotExists, "task_id", r.Cfg.ID)
+			}
+			break
+		}
+		pending = retry
+	}
+}
@@ -2,15 +2,19 @@ package taskd
 
 import (
 	"context"
+	"crypto/cipher"
 	"errors"
+	"reflect"
 	"sync"
 
-	"github.com/alitto/pond/v2"
 	"github.com/donkeywon/golib/boot"
 	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/kvs"
 	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/ratelimit"
 	"github.com/donkeywon/golib/runner"
 	"github.com/donkeywon/golib/task"
+	"github.com/donkeywon/golib/task/step"
 	"github.com/donkeywon/golib/util/reflects"
 	"github.com/donkeywon/golib/util/v"
 )
@@ -26,9 +30,15 @@ var (
 	ErrTaskNotStarted      = errors.New("task not started")
 	ErrTaskNotPaused       = errors.New("task not paused")
 	ErrPoolNotExists       = errors.New("pool not exists")
+
+	ErrDependencyNotExists = errors.New("dependency not exists")
+	ErrDependencyNotMet    = errors.New("dependency condition not met")
+	ErrDependencyCycle     = errors.New("dependency cycle")
+	ErrUpstreamTaskStopped = errors.New("upstream task stopped")
 )
 
 var _ Taskd = (*taskd)(nil)
+var _ boot.Reloadable = (*taskd)(nil)
 
 type Taskd interface {
 	boot.Daemon
@@ -41,6 +51,7 @@ type Taskd interface {
 	IsTaskPending(taskID string) bool
 	IsTaskRunning(taskID string) bool
 	IsTaskPaused(taskID string) bool
+	IsTaskWaiting(taskID string) bool
 	ListTasks() []*task.Task
 	ListTasksCfg() []*task.Cfg
 	ListTaskIDs() []string
@@ -48,7 +59,17 @@ type Taskd interface {
 	ListRunningTaskIDs() []string
 	ListPausingTaskIDs() []string
 	ListPausedTaskIDs() []string
+	ListWaitingTaskIDs() []string
 	GetTaskCfg(taskID string) (*task.Cfg, error)
+	CreatePool(cfg *PoolCfg) error
+	ResizePool(name string, size int) error
+	DrainPool(ctx context.Context, name string) error
+	DeletePool(name string) error
+	AddSchedule(cfg *ScheduleCfg) error
+	DeleteSchedule(scheduleID string) error
+	PauseSchedule(scheduleID string) error
+	ResumeSchedule(scheduleID string) error
+	ListSchedules() []*ScheduleInfo
 	OnTaskCreate(hooks ...task.Hook)
 	OnTaskInit(hooks ...task.Hook)
 	OnTaskSubmit(hooks ...task.Hook)
@@ -63,16 +84,27 @@ type Taskd interface {
 type taskd struct {
 	runner.Runner
 
-	cfg *Cfg
-
-	pools map[string]pond.Pool
+	pmu          sync.RWMutex
+	cfg          *Cfg // guarded by pmu after init
+	pools        map[string]*pool
+	rateLimiters map[string]*ratelimit.FixedRateLimiter
+	store        kvs.KVS
+	secretAEAD   cipher.AEAD // seal secrets of persisted tasks, nil if not configured
 
 	mu               sync.RWMutex
 	taskIDMap        map[string]struct{}   // task id map include pending, except paused
 	taskMap          map[string]*task.Task // task map include pending, except paused
 	taskIDRunningMap map[string]struct{}   // running task id map
 	taskIDPausingMap map[string]struct{}
-	taskPausedMap    map[string]*task.Task // paused task map
+	taskPausedMap    map[string]*task.Task   // paused task map
+	recoveredMap     map[string]*taskRecord  // records of paused tasks recovered from store, restored on resume
+	taskWaitingMap   map[string]*waitingTask // tasks waiting for dependencies, not created
+	finishedMap      map[string]bool         // whether recently finished tasks succeeded, for dependencies
+	finishedIDs      []string                // ids in finishedMap, oldest first
+
+	smu       sync.Mutex
+	schedules map[string]*schedule
+	instances map[string]*schedule // schedule of instances not done
 
 	createHooks        []task.Hook
 	initHooks          []task.Hook
@@ -86,15 +118,23 @@ type taskd struct {
 }
 
 func New() boot.Daemon {
-	return &taskd{
+	td := &taskd{
 		Runner:           runner.Create(string(DaemonTypeTaskd)),
 		taskMap:          make(map[string]*task.Task),
 		taskIDMap:        make(map[string]struct{}),
 		taskIDRunningMap: make(map[string]struct{}),
 		taskIDPausingMap: make(map[string]struct{}),
 		taskPausedMap:    make(map[string]*task.Task),
-		pools:            make(map[string]pond.Pool),
+		recoveredMap:     make(map[string]*taskRecord),
+		taskWaitingMap:   make(map[string]*waitingTask),
+		finishedMap:      make(map[string]bool),
+		pools:            make(map[string]*pool),
+		rateLimiters:     make(map[string]*ratelimit.FixedRateLimiter),
+		schedules:        make(map[string]*schedule),
+		instances:        make(map[string]*schedule),
 	}
+	td.doneHooks = append(td.doneHooks, td.onInstanceDone)
+	return td
 }
 
 func (td *taskd) Init() error {
@@ -102,17 +142,45 @@ func (td *taskd) Init() error {
 		return errs.New("no pools")
 	}
 	for _, poolCfg := range td.cfg.Pools {
-		td.pools[poolCfg.Name] = pond.NewPool(poolCfg.Size, pond.WithQueueSize(poolCfg.QueueSize))
+		err := v.Struct(poolCfg)
+		if err != nil {
+			return errs.Wrapf(err, "invalid pool %s", poolCfg.Name)
+		}
+		td.pools[poolCfg.Name] = newPool(poolCfg)
+	}
+	err := td.applyRateLimits(td.cfg.RateLimits)
+	if err != nil {
+		return err
+	}
+	td.secretAEAD, err = newSecretAEAD(td.cfg.StoreSecretKey)
+	if err != nil {
+		return err
+	}
+	err = td.openStore()
+	if err != nil {
+		return err
+	}
+	// recover before any task is submitted, so that recovered tasks are not conflict with new tasks
+	td.recoverTasks()
+	for _, scheduleCfg := range td.cfg.Schedules {
+		err = td.AddSchedule(scheduleCfg)
+		if err != nil {
+			return err
+		}
 	}
 	return td.Runner.Init()
 }
 
 func (td *taskd) Start() error {
+	go td.autoscale()
 	<-td.Stopping()
+	td.stopSchedules()
+	td.stopWaiting()
 	td.waitAllTaskDone()
-	for _, pool := range td.pools {
+	for _, pool := range td.listPools() {
 		pool.Stop()
 	}
+	td.closeStore()
 	return td.Runner.Start()
 }
 
@@ -121,20 +189,161 @@ func (td *taskd) Stop() error {
 	return nil
 }
 
-func (td *taskd) getPool(taskCfg *task.Cfg) pond.Pool {
+// Check report unhealthy if any pool queue is full, implements runner.HealthChecker.
+func (td *taskd) Check(context.Context) error {
+	for _, pool := range td.listPools() {
+		if pool.QueueSize() > 0 && pool.WaitingTasks() >= uint64(pool.QueueSize()) {
+			return errs.Errorf("pool %s saturated, running: %d, waiting: %d", pool.name(), pool.RunningWorkers(), pool.WaitingTasks())
+		}
+	}
+	return nil
+}
+
+// Reload create added pools, resize pools and apply their queue cfgs, implements boot.Reloadable.
+// Pools not in cfg are kept, they can be deleted by DeletePool. Store and Schedules are not reloadable.
+func (td *taskd) Reload(newCfg any) error {
+	cfg := newCfg.(*Cfg)
+	td.pmu.RLock()
+	storeChanged := !reflect.DeepEqual(cfg.Store, td.cfg.Store) || cfg.StoreSecretKey != td.cfg.StoreSecretKey
+	schedulesChanged := !reflect.DeepEqual(cfg.Schedules, td.cfg.Schedules)
+	td.pmu.RUnlock()
+	if storeChanged {
+		return errs.New("store is not reloadable, restart required")
+	}
+	if schedulesChanged {
+		return errs.New("schedules are not reloadable, restart required")
+	}
+
+	for _, poolCfg := range cfg.Pools {
+		err := v.Struct(poolCfg)
+		if err != nil {
+			return errs.Wrapf(err, "invalid pool %s", poolCfg.Name)
+		}
+	}
+	err := validateRateLimits(cfg.RateLimits)
+	if err != nil {
+		return err
+	}
+
+	td.pmu.Lock()
+	defer td.pmu.Unlock()
+	for _, poolCfg := range cfg.Pools {
+		pool, exists := td.pools[poolCfg.Name]
+		if !exists {
+			td.Info("create pool", "pool", poolCfg.Name, "size", poolCfg.Size)
+			td.pools[poolCfg.Name] = newPool(poolCfg)
+			continue
+		}
+		if pool.MaxConcurrency() != poolCfg.Size {
+			td.Info("resize pool", "pool", poolCfg.Name, "from", pool.MaxConcurrency(), "to", poolCfg.Size)
+		}
+		pool.reload(poolCfg)
+	}
+	err = td.applyRateLimits(cfg.RateLimits)
+	if err != nil {
+		return err
+	}
+	td.cfg = cfg
+	return nil
+}
+
+func (td *taskd) getPool(taskCfg *task.Cfg) *pool {
+	td.pmu.RLock()
+	defer td.pmu.RUnlock()
 	return td.pools[taskCfg.Pool]
 }
 
+func (td *taskd) listPools() []*pool {
+	td.pmu.RLock()
+	defer td.pmu.RUnlock()
+	pools := make([]*pool, 0, len(td.pools))
+	for _, pool := range td.pools {
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+// CreatePool create a pool at runtime, it is not persisted in cfg.
+func (td *taskd) CreatePool(cfg *PoolCfg) error {
+	select {
+	case <-td.Stopping():
+		return ErrStopping
+	default:
+	}
+
+	err := v.Struct(cfg)
+	if err != nil {
+		return errs.Wrap(err, "invalid pool cfg")
+	}
+
+	td.pmu.Lock()
+	defer td.pmu.Unlock()
+	if _, exists := td.pools[cfg.Name]; exists {
+		return ErrPoolAlreadyExists
+	}
+	td.pools[cfg.Name] = newPool(cfg)
+	td.Info("create pool", "pool", cfg.Name, "size", cfg.Size, "queue_size", cfg.QueueSize)
+	return nil
+}
+
+// ResizePool change max concurrency of a pool, it may be changed again by autoscaler of the pool.
+func (td *taskd) ResizePool(name string, size int) error {
+	if size <= 0 {
+		return errs.Errorf("invalid pool size: %d", size)
+	}
+	pool := td.getPool(&task.Cfg{Pool: name})
+	if pool == nil {
+		return ErrPoolNotExists
+	}
+	td.Info("resize pool", "pool", name, "from", pool.MaxConcurrency(), "to", size)
+	pool.Resize(size)
+	return nil
+}
+
+// DrainPool reject new tasks of a pool and wait for its queued and running tasks done.
+// A drained pool rejects new tasks until it is deleted.
+func (td *taskd) DrainPool(ctx context.Context, name string) error {
+	pool := td.getPool(&task.Cfg{Pool: name})
+	if pool == nil {
+		return ErrPoolNotExists
+	}
+	td.Info("drain pool", "pool", name, "running", pool.RunningWorkers(), "waiting", pool.WaitingTasks())
+	select {
+	case <-pool.drain():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DeletePool delete a pool without queued or running tasks, drain it first to make sure of that.
+func (td *taskd) DeletePool(name string) error {
+	td.pmu.Lock()
+	defer td.pmu.Unlock()
+	pool, exists := td.pools[name]
+	if !exists {
+		return ErrPoolNotExists
+	}
+	err := pool.delete()
+	if err != nil {
+		return err
+	}
+	delete(td.pools, name)
+	pool.Stop()
+	td.Info("delete pool", "pool", name)
+	return nil
+}
+
 func (td *taskd) SetCfg(cfg any) {
 	td.cfg = cfg.(*Cfg)
 }
 
 func (td *taskd) SubmitTask(taskCfg *task.Cfg) (*task.Task, error) {
-	return td.createInitSubmit(td.Ctx(), taskCfg, false)
+	return td.createInitSubmit(td.Ctx(), taskCfg, false, true)
 }
 
 func (td *taskd) SubmitTaskAndWait(ctx context.Context, taskCfg *task.Cfg) (*task.Task, error) {
-	return td.createInitSubmit(ctx, taskCfg, true)
+	return td.createInitSubmit(ctx, taskCfg, true, true)
 }
 
 func (td *taskd) StopTask(taskID string) error {
@@ -147,6 +356,16 @@ func (td *taskd) StopTask(taskID string) error {
 	isPaused, _ := td.unmarkTaskIfPaused(taskID)
 	if isPaused {
 		// task is paused, just unmark it
+		td.takeRecovered(taskID)
+		td.deleteTask(taskID)
+		td.markTaskFinished(taskID, false)
+		td.cancelDownstream(taskID)
+		return nil
+	}
+
+	w := td.unmarkTaskIfWaiting(taskID)
+	if w != nil {
+		td.cancelWaiting(w, nil, true)
 		return nil
 	}
 
@@ -162,6 +381,7 @@ func (td *taskd) StopTask(taskID string) error {
 	}
 
 	runner.Stop(t)
+	td.cancelDownstream(taskID)
 	return nil
 }
 
@@ -210,28 +430,19 @@ func (td *taskd) ResumeTask(taskID string) (*task.Task, error) {
 		return nil, ErrTaskNotPaused
 	}
 
-	newT, err := td.createInitSubmit(td.Ctx(), t.Cfg, false, func(newT *task.Task, err error, hed *task.HookExtraData) {
-		data := t.LoadAll()
-		for k, v := range data {
-			newT.Store(k, v)
-		}
-
-		for i, newStep := range newT.Steps() {
-			data = t.Steps()[i].LoadAll()
-			for k, v := range data {
-				newStep.Store(k, v)
-			}
-		}
-		for i, newStep := range newT.DeferSteps() {
-			data = t.DeferSteps()[i].LoadAll()
-			for k, v := range data {
-				newStep.Store(k, v)
-			}
-		}
+	// a task recovered from store is not initialized, its values are in the record
+	r := td.takeRecovered(taskID)
+	if r == nil {
+		r = newTaskRecord(t, taskStatePaused)
+	}
+	// dependencies are done before it ran
+	newT, err := td.createInitSubmit(td.Ctx(), t.Cfg, false, false, func(newT *task.Task, err error, hed *task.HookExtraData) {
+		r.restore(newT)
 	})
 
 	if err != nil {
 		td.markTaskPaused(t)
+		td.markTaskRecovered(t, r)
 		return newT, err
 	}
 
@@ -244,7 +455,15 @@ func (td *taskd) waitAllTaskDone() {
 	}
 }
 
-func (td *taskd) createInit(ctx context.Context, taskCfg *task.Cfg, extra *task.HookExtraData, beforeInit ...task.Hook) (*task.Task, error) {
+func (td *taskd) createInit(ctx context.Context, taskCfg *task.Cfg, extra *task.HookExtraData, afterInit ...task.Hook) (*task.Task, error) {
+	t, err := td.create(ctx, taskCfg, extra)
+	if err != nil {
+		return t, err
+	}
+	return t, td.init(t, extra, afterInit...)
+}
+
+func (td *taskd) create(ctx context.Context, taskCfg *task.Cfg, extra *task.HookExtraData) (*task.Task, error) {
 	err := v.Struct(taskCfg)
 	if err != nil {
 		return nil, errs.Wrap(err, "invalid task cfg")
@@ -265,52 +484,101 @@ func (td *taskd) createInit(ctx context.Context, taskCfg *task.Cfg, extra *task.
 		return t, errs.Wrap(err, "create task failed")
 	}
 
+	if td.store != nil {
+		t.HookStepDone(td.persistStepDone)
+		t.HookDeferStepDone(td.persistStepDone)
+	}
 	t.HookStepDone(td.stepDoneHooks...)
 	t.HookDeferStepDone(td.deferStepDoneHooks...)
+	return t, nil
+}
 
-	for _, h := range beforeInit {
-		h(t, nil, extra)
+func (td *taskd) init(t *task.Task, extra *task.HookExtraData, afterInit ...task.Hook) error {
+	err := td.initTask(t)
+	if err == nil {
+		// steps are created on init
+		for _, h := range afterInit {
+			h(t, nil, extra)
+		}
 	}
-
-	err = td.initTask(t)
 	td.hookTask(t, err, td.initHooks, "init", extra)
 	if err != nil {
-		return t, errs.Wrap(err, "init task failed")
+		return errs.Wrap(err, "init task failed")
 	}
-
-	return t, nil
+	return nil
 }
 
-func (td *taskd) submit(t *task.Task, wait bool) {
+func (td *taskd) submit(t *task.Task, wait bool) error {
 	extra := &task.HookExtraData{Wait: wait}
 
 	f := func() {
 		td.markTaskRunning(t.Cfg.ID)
+		td.persistTask(t, taskStateRunning)
 
 		td.hookTask(t, nil, td.startHooks, "start", extra)
 		err := runner.Run(t)
 
 		if td.IsTaskPausing(t.Cfg.ID) {
 			td.markTaskPaused(t)
+			td.persistTask(t, taskStatePaused)
 			td.hookTask(t, nil, td.pausedHooks, "paused", nil)
 		} else {
 			td.unmarkTaskAndTaskID(t.Cfg.ID)
+			if td.isInterrupted(t) {
+				// keep it in store to resume on next start
+				td.persistTask(t, taskStatePending)
+			} else {
+				// finished is persisted first, so that dependents recovered after a crash can be resolved
+				td.markTaskFinished(t.Cfg.ID, err == nil && t.CurStepIdx >= len(t.Cfg.Steps))
+				td.deleteTask(t.Cfg.ID)
+			}
 		}
 
 		td.hookTask(t, err, td.doneHooks, "done", extra)
+		td.resolveWaiting(t.Cfg.ID)
+	}
+
+	// pool is stopped before t runs
+	reject := func(err error) {
+		td.unmarkTaskAndTaskID(t.Cfg.ID)
+		select {
+		case <-td.Stopping():
+			// keep it in store to resume on next start
+		default:
+			t.AppendError(errs.Wrapf(err, "pool %s", t.Cfg.Pool))
+			td.markTaskFinished(t.Cfg.ID, false)
+			td.deleteTask(t.Cfg.ID)
+		}
+		runner.Stop(t)
+		td.Error("task is rejected by pool", err, "task_id", t.Cfg.ID, "pool", t.Cfg.Pool)
+		td.hookTask(t, t.Err(), td.doneHooks, "done", extra)
+		td.resolveWaiting(t.Cfg.ID)
 	}
 
 	td.markTask(t)
 
-	pt := td.getPool(t.Cfg).Submit(f)
+	pool := td.getPool(t.Cfg)
+	if pool == nil {
+		td.unmarkTaskAndTaskID(t.Cfg.ID)
+		td.deleteTask(t.Cfg.ID)
+		return errs.Wrapf(ErrPoolNotExists, "pool %s", t.Cfg.Pool)
+	}
+	done, err := pool.submit(t.Cfg, f, reject)
+	if err != nil {
+		td.unmarkTaskAndTaskID(t.Cfg.ID)
+		td.deleteTask(t.Cfg.ID)
+		return errs.Wrapf(err, "pool %s", t.Cfg.Pool)
+	}
 	if wait {
-		pt.Wait()
+		<-done
 	}
 
 	td.hookTask(t, nil, td.submitHooks, "submit", extra)
+	return nil
 }
 
-func (td *taskd) createInitSubmit(ctx context.Context, taskCfg *task.Cfg, wait bool, beforeInit ...task.Hook) (*task.Task, error) {
+// createInitSubmit create, init and submit a task, if checkDeps it is held as waiting until its dependencies are done.
+func (td *taskd) createInitSubmit(ctx context.Context, taskCfg *task.Cfg, wait bool, checkDeps bool, afterInit ...task.Hook) (*task.Task, error) {
 	select {
 	case <-td.Stopping():
 		return nil, ErrStopping
@@ -328,20 +596,39 @@ func (td *taskd) createInitSubmit(ctx context.Context, taskCfg *task.Cfg, wait b
 		return nil, ErrTaskAlreadyExists
 	}
 
-	t, err := td.createInit(ctx, taskCfg, hookExtra, beforeInit...)
+	if checkDeps && len(taskCfg.DependsOn) > 0 {
+		return td.createWait(ctx, taskCfg, wait, hookExtra, afterInit...)
+	}
+
+	t, err := td.createInit(ctx, taskCfg, hookExtra, afterInit...)
 	if err != nil {
 		td.unmarkTaskID(taskCfg.ID)
 		return nil, errs.Wrap(err, "create init task failed")
 	}
 
+	err = td.saveSubmit(t, wait)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (td *taskd) saveSubmit(t *task.Task, wait bool) error {
+	err := td.saveTask(t, taskStatePending)
+	if err != nil {
+		td.unmarkTaskID(t.Cfg.ID)
+		return errs.Wrap(err, "persist task failed")
+	}
+
 	select {
 	case <-td.Stopping():
-		return nil, ErrStopping
+		// keep it in store to resume on next start
+		td.unmarkTaskID(t.Cfg.ID)
+		return ErrStopping
 	default:
 	}
 
-	td.submit(t, wait)
-	return t, nil
+	return td.submit(t, wait)
 }
 
 func (td *taskd) createTask(cfg *task.Cfg) (t *task.Task, err error) {
@@ -376,6 +663,10 @@ func (td *taskd) markTaskID(taskID string) bool {
 	if exists {
 		return false
 	}
+	_, exists = td.taskWaitingMap[taskID]
+	if exists {
+		return false
+	}
 
 	td.taskIDMap[taskID] = struct{}{}
 	return true
@@ -438,6 +729,36 @@ func (td *taskd) markTaskPaused(t *task.Task) {
 	td.taskPausedMap[t.Cfg.ID] = t
 }
 
+func (td *taskd) markTaskRecovered(t *task.Task, r *taskRecord) {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	td.taskPausedMap[t.Cfg.ID] = t
+	td.recoveredMap[t.Cfg.ID] = r
+}
+
+func (td *taskd) takeRecovered(taskID string) *taskRecord {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	r := td.recoveredMap[taskID]
+	delete(td.recoveredMap, taskID)
+	return r
+}
+
+func (td *taskd) persistStepDone(t *task.Task, _ int, _ step.Step) {
+	td.persistTask(t, taskStateRunning)
+}
+
+// isInterrupted return whether t is stopped by stopping taskd before it finished.
+func (td *taskd) isInterrupted(t *task.Task) bool {
+	select {
+	case <-td.Stopping():
+	default:
+		return false
+	}
+	// a failed task is finished even if its steps are not all run
+	return t.Err() == nil && (t.CurStepIdx < len(t.Cfg.Steps) || t.CurDeferStepIdx < len(t.Cfg.DeferSteps))
+}
+
 func (td *taskd) unmarkTaskIfPaused(taskID string) (bool, *task.Task) {
 	td.mu.Lock()
 	defer td.mu.Unlock()
@@ -452,7 +773,7 @@ func (td *taskd) unmarkTaskIfPaused(taskID string) (bool, *task.Task) {
 func (td *taskd) ListTasksCfg() []*task.Cfg {
 	td.mu.RLock()
 	defer td.mu.RUnlock()
-	tasks := make([]*task.Task, len(td.taskMap)+len(td.taskPausedMap))
+	tasks := make([]*task.Task, len(td.taskMap)+len(td.taskPausedMap)+len(td.taskWaitingMap))
 	i := 0
 	for _, t := range td.taskMap {
 		tasks[i] = t
@@ -462,6 +783,10 @@ func (td *taskd) ListTasksCfg() []*task.Cfg {
 		tasks[i] = t
 		i++
 	}
+	for _, w := range td.taskWaitingMap {
+		tasks[i] = w.t
+		i++
+	}
 	cfgs := make([]*task.Cfg, len(tasks))
 	for i = range tasks {
 		cfgs[i] = tasks[i].Cfg
@@ -513,6 +838,10 @@ func (td *taskd) IsTaskExists(taskID string) bool {
 		return true
 	}
 	_, exists = td.taskPausedMap[taskID]
+	if exists {
+		return true
+	}
+	_, exists = td.taskWaitingMap[taskID]
 	return exists
 }
 
@@ -552,7 +881,7 @@ func (td *taskd) IsTaskPaused(taskID string) bool {
 func (td *taskd) ListTaskIDs() []string {
 	td.mu.RLock()
 	defer td.mu.RUnlock()
-	ids := make([]string, len(td.taskIDMap)+len(td.taskPausedMap))
+	ids := make([]string, len(td.taskIDMap)+len(td.taskPausedMap)+len(td.taskWaitingMap))
 	i := 0
 	for id := range td.taskIDMap {
 		ids[i] = id
@@ -562,6 +891,10 @@ func (td *taskd) ListTaskIDs() []string {
 		ids[i] = id
 		i++
 	}
+	for id := range td.taskWaitingMap {
+		ids[i] = id
+		i++
+	}
 	return ids
 }
 
@@ -617,6 +950,23 @@ func (td *taskd) ListPausedTaskIDs() []string {
 	return ids
 }
 
+func (td *taskd) IsTaskWaiting(taskID string) bool {
+	td.mu.RLock()
+	defer td.mu.RUnlock()
+	_, exists := td.taskWaitingMap[taskID]
+	return exists
+}
+
+func (td *taskd) ListWaitingTaskIDs() []string {
+	td.mu.RLock()
+	defer td.mu.RUnlock()
+	ids := make([]string, 0, len(td.taskWaitingMap))
+	for id := range td.taskWaitingMap {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (td *taskd) GetTaskCfg(taskID string) (*task.Cfg, error) {
 	td.mu.RLock()
 	defer td.mu.RUnlock()
@@ -1,16 +1,27 @@
 package taskd
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alitto/pond/v2"
+	"github.com/donkeywon/golib/kvs"
+	"github.com/donkeywon/golib/loader/kvs/sqlitekvsloader"
 	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/ratelimit"
 	"github.com/donkeywon/golib/runner"
 	"github.com/donkeywon/golib/task"
 	"github.com/donkeywon/golib/task/step"
+	"github.com/donkeywon/golib/util/jsons"
 	"github.com/donkeywon/golib/util/rands"
+	"github.com/donkeywon/golib/util/secret"
 	"github.com/donkeywon/golib/util/tests"
 	"github.com/stretchr/testify/require"
 )
@@ -20,7 +31,7 @@ var (
 )
 
 func TestMain(m *testing.M) {
-	plugin.Reg(stepTypeTick, newTickStep, func() any { return &tickStepCfg{Interval: 1} })
+	plugin.Reg(stepTypeTick, func() step.Step { return newTickStep() }, func() any { return &tickStepCfg{Interval: 1} })
 	cfg := NewCfg()
 	cfg.Pools[0].Size = 2
 	cfg.Pools[0].QueueSize = 5
@@ -77,11 +88,320 @@ func TestPause(t *testing.T) {
 	tdtest.Info("task result", "result", tsk.Result())
 }
 
+func TestRecover(t *testing.T) {
+	cfg := NewCfg()
+	cfg.Store = &kvs.Cfg{Type: sqlitekvsloader.TypeSQLite, Cfg: &sqlitekvsloader.SQLiteKVSCfg{
+		Path:     filepath.Join(t.TempDir(), "taskd.db"),
+		Table:    "kv",
+		PoolSize: 1,
+	}}
+	cfg.StoreSecretKey = secret.String(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+	td := startTaskd(t, cfg)
+	stepDone := make(chan struct{}, 1)
+	td.OnTaskStepDone(func(*task.Task, int, step.Step) {
+		stepDone <- struct{}{}
+	})
+	taskCfg := task.NewCfg().SetID("test-recover").SetType("abc").Add(stepTypeTick, &tickStepCfg{
+		Interval: 1,
+		Count:    1,
+	}).Add(stepTypeTick, &tickStepCfg{
+		Interval: 1,
+		Count:    60,
+		Token:    "token",
+	})
+	taskCfg.Pool = DefaultPool
+	_, err := td.SubmitTask(taskCfg)
+	require.NoError(t, err)
+	<-stepDone
+	runner.StopAndWait(td)
+
+	// secret is not persisted in plaintext
+	require.NoError(t, td.store.Open())
+	v, exists, err := td.store.Load(storeKeyPrefix + "test-recover")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.NotContains(t, v, `"token"`)
+	require.NoError(t, td.store.Close())
+
+	// a task whose secret is redacted is not recovered
+	redacted := task.NewCfg().SetID("test-recover-redacted").SetType("abc").Add(stepTypeTick, &tickStepCfg{
+		Interval: 1,
+		Count:    1,
+		Token:    secret.Redacted,
+	})
+	redacted.Pool = DefaultPool
+	s, err := jsons.MarshalString(&taskRecord{Cfg: redacted, State: taskStatePending})
+	require.NoError(t, err)
+	require.NoError(t, td.store.Open())
+	require.NoError(t, td.store.Store(storeKeyPrefix+redacted.ID, s))
+	require.NoError(t, td.store.Close())
+
+	td = startTaskd(t, cfg)
+	defer runner.StopAndWait(td)
+	require.Eventually(t, func() bool { return td.IsTaskRunning("test-recover") }, 5*time.Second, 100*time.Millisecond)
+	tsk := td.getTask("test-recover")
+	require.Equal(t, 1, tsk.Cfg.CurStepIdx)
+	require.Equal(t, "1-1", tsk.Steps()[0].LoadAsString("field_test"))
+	require.Equal(t, "token", tsk.Cfg.Steps[1].Cfg.(*tickStepCfg).Token.Value())
+	require.False(t, td.IsTaskExists(redacted.ID))
+
+	require.NoError(t, td.StopTask("test-recover"))
+	<-tsk.Done()
+	require.Eventually(t, func() bool {
+		_, exists, err := td.store.Load(storeKeyPrefix + "test-recover")
+		return err == nil && !td.IsTaskExists("test-recover") && !exists
+	}, 5*time.Second, 100*time.Millisecond)
+	succeeded := td.finishedMap["test-recover"]
+	runner.StopAndWait(td)
+
+	// result of finished task is recovered for dependencies
+	td = startTaskd(t, cfg)
+	defer runner.StopAndWait(td)
+	recovered, finished := td.finishedMap["test-recover"]
+	require.True(t, finished)
+	require.Equal(t, succeeded, recovered)
+}
+
+func TestSchedule(t *testing.T) {
+	td := startTaskd(t, NewCfg())
+	defer runner.StopAndWait(td)
+
+	taskCfg := task.NewCfg().SetType("abc").Add(stepTypeTick, &tickStepCfg{
+		Interval: 1,
+		Count:    3,
+	})
+	taskCfg.Pool = DefaultPool
+	require.NoError(t, td.AddSchedule(&ScheduleCfg{
+		ID:       "test-schedule",
+		Interval: 1,
+		Task:     taskCfg,
+	}))
+	require.ErrorIs(t, td.AddSchedule(&ScheduleCfg{ID: "test-schedule", Interval: 1, Task: taskCfg}), ErrScheduleAlreadyExists)
+	require.Error(t, td.AddSchedule(&ScheduleCfg{ID: "test-invalid", Cron: "* *", Task: taskCfg}))
+
+	time.Sleep(2500 * time.Millisecond)
+	require.NoError(t, td.PauseSchedule("test-schedule"))
+	infos := td.ListSchedules()
+	require.Len(t, infos, 1)
+	require.True(t, infos[0].Paused)
+	require.True(t, infos[0].Next.IsZero())
+	require.Len(t, infos[0].History, 2)
+	require.True(t, td.IsTaskRunning(infos[0].History[0].TaskID))
+	require.True(t, infos[0].History[1].Skipped)
+
+	require.NoError(t, td.ResumeSchedule("test-schedule"))
+	require.False(t, td.ListSchedules()[0].Next.IsZero())
+	require.NoError(t, td.DeleteSchedule("test-schedule"))
+	require.ErrorIs(t, td.DeleteSchedule("test-schedule"), ErrScheduleNotExists)
+	require.Empty(t, td.ListSchedules())
+}
+
+func TestDependsOn(t *testing.T) {
+	td := startTaskd(t, NewCfg())
+	defer runner.StopAndWait(td)
+	newCfg := func(id string, tick int) *task.Cfg {
+		cfg := createTaskCfg(id, tick)
+		cfg.Pool = DefaultPool
+		cfg.Steps[0].Cfg.(*tickStepCfg).Interval = 1
+		return cfg
+	}
+
+	failed := newCfg("test-dep-a", 1)
+	failed.Steps[0].Cfg.(*tickStepCfg).Fail = true
+	_, err := td.SubmitTask(failed)
+	require.NoError(t, err)
+	onSuccess, err := td.SubmitTask(newCfg("test-dep-b", 1).DependOn("test-dep-a", task.DependOnSuccess))
+	require.NoError(t, err)
+	onFailure, err := td.SubmitTask(newCfg("test-dep-c", 1).DependOn("test-dep-a", task.DependOnFailure))
+	require.NoError(t, err)
+	require.True(t, td.IsTaskWaiting("test-dep-b"))
+	require.ElementsMatch(t, []string{"test-dep-b", "test-dep-c"}, td.ListWaitingTaskIDs())
+
+	_, err = td.SubmitTask(newCfg("test-dep-self", 1).DependOn("test-dep-self", task.DependAlways))
+	require.ErrorIs(t, err, ErrDependencyCycle)
+	_, err = td.SubmitTask(newCfg("test-dep-none", 1).DependOn("test-dep-none-upstream", task.DependAlways))
+	require.ErrorIs(t, err, ErrDependencyNotExists)
+
+	<-onSuccess.Done()
+	require.ErrorIs(t, onSuccess.Err(), ErrDependencyNotMet)
+	<-onFailure.Done()
+	require.NoError(t, onFailure.Err())
+	require.Empty(t, td.ListWaitingTaskIDs())
+
+	_, err = td.SubmitTask(newCfg("test-dep-long", 100))
+	require.NoError(t, err)
+	downstream, err := td.SubmitTask(newCfg("test-dep-d", 1).DependOn("test-dep-long", task.DependAlways))
+	require.NoError(t, err)
+	transitive, err := td.SubmitTask(newCfg("test-dep-e", 1).DependOn("test-dep-d", task.DependAlways))
+	require.NoError(t, err)
+	require.NoError(t, td.StopTask("test-dep-long"))
+	<-downstream.Done()
+	<-transitive.Done()
+	require.ErrorIs(t, downstream.Err(), ErrUpstreamTaskStopped)
+	require.ErrorIs(t, transitive.Err(), ErrUpstreamTaskStopped)
+	require.Empty(t, td.ListWaitingTaskIDs())
+}
+
+func TestPool(t *testing.T) {
+	p := newPool(&PoolCfg{Name: "test", Size: 1, QueueSize: 4, TenantLabel: "tenant"})
+	defer p.StopAndWait()
+
+	var (
+		block = make(chan struct{})
+		mu    sync.Mutex
+		order []string
+		done  <-chan struct{}
+	)
+	submit := func(id string, priority int, tenant string) error {
+		cfg := task.NewCfg().SetID(id).SetPriority(priority).SetLabel("tenant", tenant)
+		itemDone, err := p.submit(cfg, func() {
+			if id == "blocker" {
+				<-block
+			}
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+		}, nil)
+		if err == nil {
+			done = itemDone
+		}
+		return err
+	}
+
+	require.NoError(t, submit("blocker", 0, "a"))
+	require.NoError(t, submit("a1", 0, "a"))
+	require.NoError(t, submit("a2", 0, "a"))
+	require.NoError(t, submit("b1", 0, "b"))
+	require.NoError(t, submit("urgent", 10, "a"))
+	require.EqualValues(t, 1, p.RunningWorkers())
+	require.EqualValues(t, 4, p.WaitingTasks())
+	require.ErrorIs(t, submit("full", 0, "c"), ErrQueueFull)
+
+	close(block)
+	<-done
+	require.Eventually(t, func() bool { return p.RunningWorkers() == 0 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"blocker", "urgent", "b1", "a1", "a2"}, order)
+
+	// queued task is rejected if pool is stopped before it runs
+	p = newPool(&PoolCfg{Name: "test-stop", Size: 1, QueueSize: 1})
+	block = make(chan struct{})
+	_, err := p.submit(task.NewCfg(), func() { <-block }, nil)
+	require.NoError(t, err)
+	var rejectErr error
+	queuedDone, err := p.submit(task.NewCfg(), func() {}, func(err error) { rejectErr = err })
+	require.NoError(t, err)
+	p.Stop()
+	// pond stops asynchronously
+	require.Eventually(t, p.Stopped, time.Second, time.Millisecond)
+	close(block)
+	<-queuedDone
+	require.ErrorIs(t, rejectErr, pond.ErrPoolStopped)
+}
+
+func TestManagePool(t *testing.T) {
+	td := startTaskd(t, NewCfg())
+	defer runner.StopAndWait(td)
+
+	require.ErrorIs(t, td.CreatePool(&PoolCfg{Name: DefaultPool, Size: 1, QueueSize: 1}), ErrPoolAlreadyExists)
+	require.Error(t, td.CreatePool(&PoolCfg{Name: "test-pool"}))
+	require.NoError(t, td.CreatePool(&PoolCfg{Name: "test-pool", Size: 1, QueueSize: 1}))
+	require.NoError(t, td.ResizePool("test-pool", 2))
+	require.Equal(t, 2, td.getPool(&task.Cfg{Pool: "test-pool"}).MaxConcurrency())
+	require.ErrorIs(t, td.ResizePool("test-pool-none", 2), ErrPoolNotExists)
+
+	cfg := createTaskCfg("test-pool-task", 1)
+	cfg.Pool = "test-pool"
+	cfg.Steps[0].Cfg.(*tickStepCfg).Interval = 1
+	tk, err := td.SubmitTask(cfg)
+	require.NoError(t, err)
+	require.ErrorIs(t, td.DeletePool("test-pool"), ErrPoolBusy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, td.DrainPool(ctx, "test-pool"), context.DeadlineExceeded)
+	cfg = createTaskCfg("test-pool-task2", 1)
+	cfg.Pool = "test-pool"
+	_, err = td.SubmitTask(cfg)
+	require.ErrorIs(t, err, ErrPoolDraining)
+	require.NoError(t, td.DrainPool(context.Background(), "test-pool"))
+	<-tk.Done()
+	require.NoError(t, tk.Err())
+
+	require.NoError(t, td.DeletePool("test-pool"))
+	require.ErrorIs(t, td.DeletePool("test-pool"), ErrPoolNotExists)
+	_, err = td.SubmitTask(cfg)
+	require.ErrorIs(t, err, ErrPoolNotExists)
+
+	cfg2 := NewCfg()
+	cfg2.Pools[0].Size = 2
+	require.NoError(t, td.Reload(cfg2))
+	require.Equal(t, 2, td.getPool(&task.Cfg{Pool: DefaultPool}).MaxConcurrency())
+	cfg2 = NewCfg()
+	cfg2.Schedules = []*ScheduleCfg{NewScheduleCfg()}
+	require.ErrorContains(t, td.Reload(cfg2), "restart required")
+	require.Equal(t, 2, td.getPool(&task.Cfg{Pool: DefaultPool}).MaxConcurrency())
+}
+
+func TestReloadRateLimits(t *testing.T) {
+	cfg := NewCfg()
+	cfg.RateLimits = map[string]*ratelimit.FixedRateLimiterCfg{"test-rl": {N: 1, Burst: 1}}
+	td := startTaskd(t, cfg)
+	defer runner.StopAndWait(td)
+
+	srl := ratelimit.NewSharedRateLimiter()
+	srl.Cfg.Name = "test-rl"
+	srl.Inherit(td)
+	require.NoError(t, runner.Init(srl))
+	ctx := context.Background()
+	require.NoError(t, srl.RxWaitN(ctx, 1, 0))
+	require.Error(t, srl.RxWaitN(ctx, 1, 10*time.Millisecond))
+
+	cfg2 := NewCfg()
+	cfg2.RateLimits = map[string]*ratelimit.FixedRateLimiterCfg{"test-rl": {N: 0, Burst: 0}}
+	require.Error(t, td.Reload(cfg2))
+	cfg2.RateLimits["test-rl"] = &ratelimit.FixedRateLimiterCfg{N: 1000, Burst: 10}
+	require.NoError(t, td.Reload(cfg2))
+	require.NoError(t, srl.RxWaitN(ctx, 5, 10*time.Millisecond))
+}
+
+func TestAutoscaleDesired(t *testing.T) {
+	cfg := &AutoscaleCfg{MinSize: 1, MaxSize: 4, MaxProcMemory: 100}
+	idle := &hostLoad{CPUPercent: 10, MemPercent: 10}
+	require.Equal(t, 3, cfg.desired(2, 2, 1, idle))
+	require.Equal(t, 4, cfg.desired(4, 4, 10, idle))
+	require.Equal(t, 2, cfg.desired(2, 2, 0, idle))
+	require.Equal(t, 1, cfg.desired(2, 0, 0, idle))
+	require.Equal(t, 1, cfg.desired(1, 0, 0, idle))
+	require.Equal(t, 1, cfg.desired(2, 2, 1, &hostLoad{CPUPercent: 90}))
+	require.Equal(t, 1, cfg.desired(2, 2, 1, &hostLoad{MemPercent: 80}))
+	require.Equal(t, 1, cfg.desired(2, 2, 1, &hostLoad{ProcMemory: 100}))
+
+	cfg = &AutoscaleCfg{MinSize: 2, MaxSize: 10, Step: 3, QueueDepth: 5, MaxCPUPercent: 95}
+	require.Equal(t, 5, cfg.desired(5, 5, 4, &hostLoad{CPUPercent: 90}))
+	require.Equal(t, 8, cfg.desired(5, 5, 5, &hostLoad{CPUPercent: 90}))
+	require.Equal(t, 4, cfg.desired(5, 4, 0, &hostLoad{}))
+
+	load, err := sampleHostLoad(context.Background(), true)
+	require.NoError(t, err)
+	require.Positive(t, load.ProcMemory)
+}
+
+func startTaskd(t *testing.T, cfg *Cfg) *taskd {
+	td := New().(*taskd)
+	td.cfg = cfg
+	tests.Init(td)
+	require.NoError(t, runner.Init(td))
+	runner.Start(td)
+	return td
+}
+
 const stepTypeTick step.Type = "tick"
 
 type tickStepCfg struct {
 	Interval int
 	Count    int
+	Fail     bool
+	Token    secret.String
 }
 
 type tickStep struct {
@@ -106,6 +426,9 @@ func (t *tickStep) Start() error {
 		}
 	}
 	t.Store("field_test", fmt.Sprintf("%d-%d", t.Cfg.Interval, t.Cfg.Count))
+	if t.Cfg.Fail {
+		return errors.New("tick failed")
+	}
 	return nil
 }
 
@@ -0,0 +1,104 @@
+package upd
+
+import (
+	"errors"
+	"net/http"
+	"runtime"
+
+	"github.com/donkeywon/golib/boot"
+	"github.com/donkeywon/golib/buildinfo"
+	"github.com/donkeywon/golib/daemon/httpd"
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/httpu"
+)
+
+// BuildInfo is the build info of current binary.
+type BuildInfo struct {
+	Version    string `json:"version"    yaml:"version"`
+	BuildTime  string `json:"buildTime"  yaml:"buildTime"`
+	CommitTime string `json:"commitTime" yaml:"commitTime"`
+	Revision   string `json:"revision"   yaml:"revision"`
+	GoVersion  string `json:"goVersion"  yaml:"goVersion"`
+	Arch       string `json:"arch"       yaml:"arch"`
+}
+
+// registerAPI register http api to httpd, upd is initialized before httpd so the routes take effect.
+// The upgrade api runs cmd, so it is not served without basic auth or allowed ips.
+func (u *upd) registerAPI() error {
+	if u.Cfg.WebAuthUser == "" && u.Cfg.WebAuthPwd.Value() == "" && u.allowedIPsGetter == nil {
+		return errs.New("http api requires basic auth or allowed ips")
+	}
+	h := boot.Get[httpd.HTTPd](httpd.DaemonTypeHTTPd)
+	h.Handle(u.Cfg.APIPrefix+"/buildinfo", u.midSecure(http.MethodGet, u.buildInfo))
+	h.Handle(u.Cfg.APIPrefix+"/upgrade", u.midSecure(http.MethodPost, u.upgradeAPI))
+	h.Handle(u.Cfg.APIPrefix+"/progress", u.midSecure(http.MethodGet, u.progressAPI))
+	return nil
+}
+
+func (u *upd) buildInfo(w http.ResponseWriter, _ *http.Request) {
+	httpu.RespJSON(w, http.StatusOK, &BuildInfo{
+		Version:    buildinfo.Version,
+		BuildTime:  buildinfo.BuildTime,
+		CommitTime: buildinfo.CommitTime,
+		Revision:   buildinfo.Revision,
+		GoVersion:  runtime.Version(),
+		Arch:       runtime.GOARCH,
+	})
+}
+
+func (u *upd) upgradeAPI(w http.ResponseWriter, r *http.Request) {
+	vi := &VerInfo{}
+	err := httpu.ReqTo(r, vi)
+	if err != nil {
+		httpu.RespString(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// cmd and paths are only from cfg, otherwise anyone can call the api runs any cmd
+	if len(vi.UpgradeCmd) > 0 || vi.UpgradeOutputPath != "" || vi.DownloadDstPath != "" {
+		httpu.RespString(w, http.StatusBadRequest, "upgradeCmd, upgradeOutputPath and downloadDstPath can not be set over http api")
+		return
+	}
+	if u.Cfg.DownloadDstPath == "" {
+		httpu.RespString(w, http.StatusBadRequest, "download dst path is not configured")
+		return
+	}
+	vi.DownloadDstPath = u.Cfg.DownloadDstPath
+
+	err = u.Upgrade(vi)
+	switch {
+	case err == nil:
+		httpu.RespJSON(w, http.StatusAccepted, u.Progress())
+	case errors.Is(err, ErrAlreadyUpgrading):
+		httpu.RespString(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotInRollout):
+		httpu.RespString(w, http.StatusPreconditionFailed, err.Error())
+	default:
+		httpu.RespString(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+func (u *upd) progressAPI(w http.ResponseWriter, _ *http.Request) {
+	p := u.Progress()
+	if p == nil {
+		httpu.RespString(w, http.StatusNotFound, "no upgrade")
+		return
+	}
+	httpu.RespJSON(w, http.StatusOK, p)
+}
+
+func (u *upd) midSecure(method string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if u.allowedIPsGetter != nil && !httpu.IPAllowed(w, r, u.allowedIPsGetter()) {
+			return
+		}
+		if !httpu.BasicAuth(w, r, u.Cfg.WebAuthUser, u.Cfg.WebAuthPwd.Value()) {
+			return
+		}
+
+		f(w, r)
+	}
+}
@@ -0,0 +1,72 @@
+package upd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressAPI(t *testing.T) {
+	u := New().(*upd)
+	u.Cfg = NewCfg()
+	u.Cfg.WebAuthUser = "admin"
+	u.Cfg.WebAuthPwd = "pwd"
+	h := u.midSecure(http.MethodGet, u.progressAPI)
+
+	do := func(method string, auth bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/upd/progress", nil)
+		if auth {
+			r.SetBasicAuth("admin", "pwd")
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPost, true).Code)
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodGet, false).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, true).Code)
+
+	u.progress.start("v1.0.0")
+	u.progress.setDownloaded(10, 100)
+	u.progress.fail(errors.New("boom"))
+	w := do(http.MethodGet, true)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"phase":"failed"`)
+	require.Contains(t, w.Body.String(), `"downloaded":10`)
+
+	u.SetAllowedIPsGetter(func() map[string]struct{} { return map[string]struct{}{"10.0.0.1": {}} })
+	require.Equal(t, http.StatusForbidden, do(http.MethodGet, true).Code)
+}
+
+func TestUpgradeAPI(t *testing.T) {
+	u := New().(*upd)
+	u.Cfg = NewCfg()
+	require.ErrorContains(t, u.registerAPI(), "basic auth or allowed ips")
+
+	h := u.midSecure(http.MethodPost, u.upgradeAPI)
+	do := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/upd/upgrade", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"ver":"v2","upgradeCmd":["sh","-c","id"]}`,
+		`{"ver":"v2","upgradeOutputPath":"/etc/passwd"}`,
+		`{"ver":"v2","downloadDstPath":"/usr/bin/sh"}`,
+	} {
+		w := do(body)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "can not be set over http api")
+	}
+	w := do(`{"ver":"v2"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "download dst path is not configured")
+}
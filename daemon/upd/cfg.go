@@ -3,19 +3,46 @@ package upd
 import (
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/donkeywon/golib/consts"
+	"github.com/donkeywon/golib/util/secret"
+)
+
+const (
+	DefaultHandoverReadyTimeout = time.Minute
+	DefaultHealthProbeTimeout   = time.Minute
+	DefaultAPIPrefix            = "/upd"
 )
 
 var (
 	DefaultUpgradeOutputPath = filepath.Join(os.TempDir(), "upgrade.out")
+	DefaultPrevBinaryPath    = consts.ExecPath + ".prev"
 )
 
 type Cfg struct {
-	UpgradeCmd        []string `env:"UPGRADE_CMD"         long:"upgrade-cmd"         yaml:"upgradeCmd"        description:"exec cmd after download completed"`
-	UpgradeOutputPath string   `env:"UPGRADE_OUTPUT_PATH" long:"upgrade-output-path" yaml:"upgradeOutputPath" description:"upgrade cmd output path"`
+	UpgradeCmd           []string      `env:"UPGRADE_CMD"            long:"upgrade-cmd"            yaml:"upgradeCmd"           description:"exec cmd after download completed"`
+	UpgradeOutputPath    string        `env:"UPGRADE_OUTPUT_PATH"    long:"upgrade-output-path"    yaml:"upgradeOutputPath"    description:"upgrade cmd output path"`
+	DownloadDstPath      string        `env:"DOWNLOAD_DST_PATH"      long:"download-dst-path"      yaml:"downloadDstPath"      description:"path to download package to when upgrade over http api"`
+	Handover             bool          `env:"HANDOVER"               long:"handover"               yaml:"handover"             description:"pass listeners to the process started by upgrade cmd and exit after it ready, upgrade cmd must be or exec the new binary"`
+	HandoverReadyTimeout time.Duration `env:"HANDOVER_READY_TIMEOUT" long:"handover-ready-timeout" yaml:"handoverReadyTimeout" description:"max duration to wait the new process ready, it is killed and upgrade is rolled back on timeout"`
+	PublicKey            string        `env:"PUBLIC_KEY"             long:"public-key"             yaml:"publicKey"            description:"ed25519 public key in PEM or base64 to verify package signature, unsigned package is rejected if set"`
+	PrevBinaryPath       string        `env:"PREV_BINARY_PATH"       long:"prev-binary-path"       yaml:"prevBinaryPath"       description:"path to keep the previous binary before upgrade for rollback, empty means not keep"`
+	RollbackCmd          []string      `env:"ROLLBACK_CMD"           long:"rollback-cmd"           yaml:"rollbackCmd"          description:"exec cmd if new version fails, env UPD_PREV_BINARY, UPD_BINARY and UPD_UPGRADE_PID are set, if empty the previous binary is copied back and restarted without handover"`
+	HealthProbeURL       string        `env:"HEALTH_PROBE_URL"       long:"health-probe-url"       yaml:"healthProbeURL"       description:"url of new version to probe after upgrade cmd started, 2xx means healthy, empty means no probe, not used with handover"`
+	HealthProbeTimeout   time.Duration `env:"HEALTH_PROBE_TIMEOUT"   long:"health-probe-timeout"   yaml:"healthProbeTimeout"   description:"max duration to wait new version healthy, it is rolled back on timeout"`
+	EnableHTTPAPI        bool          `env:"ENABLE_HTTP_API"        long:"enable-http-api"        yaml:"enableHTTPAPI"        description:"serve buildinfo, upgrade and progress api over http, depends on httpd, basic auth or allowed ips is required"`
+	APIPrefix            string        `env:"API_PREFIX"             long:"api-prefix"             yaml:"apiPrefix"            description:"url prefix of http api"`
+	WebAuthUser          string        `env:"WEB_AUTH_USER"          long:"web-auth-user"          yaml:"webAuthUser"          description:"basic auth user of http api"`
+	WebAuthPwd           secret.String `env:"WEB_AUTH_PWD"           long:"web-auth-pwd"           yaml:"webAuthPwd"           description:"basic auth password of http api"`
 }
 
 func NewCfg() *Cfg {
 	return &Cfg{
-		UpgradeOutputPath: DefaultUpgradeOutputPath,
+		UpgradeOutputPath:    DefaultUpgradeOutputPath,
+		HandoverReadyTimeout: DefaultHandoverReadyTimeout,
+		PrevBinaryPath:       DefaultPrevBinaryPath,
+		HealthProbeTimeout:   DefaultHealthProbeTimeout,
+		APIPrefix:            DefaultAPIPrefix,
 	}
 }
@@ -0,0 +1,80 @@
+package upd
+
+import (
+	"sync"
+	"time"
+)
+
+type Phase string
+
+const (
+	PhaseDownload Phase = "download"
+	PhaseVerify   Phase = "verify"
+	PhaseUpgrade  Phase = "upgrade"  // stopping daemons and exec upgrade cmd
+	PhaseHandover Phase = "handover" // waiting the new process ready
+	PhaseProbe    Phase = "probe"    // probing health of the new version
+	PhaseRollback Phase = "rollback"
+	PhaseDone     Phase = "done"
+	PhaseFailed   Phase = "failed"
+)
+
+// Progress is the progress of the last upgrade.
+type Progress struct {
+	Ver        string    `json:"ver"           yaml:"ver"`
+	Phase      Phase     `json:"phase"         yaml:"phase"`
+	Downloaded int64     `json:"downloaded"    yaml:"downloaded"`
+	Size       int64     `json:"size"          yaml:"size"` // 0 if unknown
+	Err        string    `json:"err,omitempty" yaml:"err,omitempty"`
+	StartTime  time.Time `json:"startTime"     yaml:"startTime"`
+	UpdateTime time.Time `json:"updateTime"    yaml:"updateTime"`
+}
+
+type progress struct {
+	mu sync.Mutex
+	p  *Progress
+}
+
+func (p *progress) get() *Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.p == nil {
+		return nil
+	}
+	cp := *p.p
+	return &cp
+}
+
+func (p *progress) start(ver string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.p = &Progress{Ver: ver, Phase: PhaseDownload, StartTime: now, UpdateTime: now}
+}
+
+func (p *progress) update(f func(*Progress)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.p == nil {
+		return
+	}
+	f(p.p)
+	p.p.UpdateTime = time.Now()
+}
+
+func (p *progress) setPhase(phase Phase) {
+	p.update(func(pp *Progress) { pp.Phase = phase })
+}
+
+func (p *progress) setDownloaded(offset int64, size int64) {
+	p.update(func(pp *Progress) {
+		pp.Downloaded = offset
+		pp.Size = size
+	})
+}
+
+func (p *progress) fail(err error) {
+	p.update(func(pp *Progress) {
+		pp.Phase = PhaseFailed
+		pp.Err = err.Error()
+	})
+}
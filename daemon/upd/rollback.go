@@ -0,0 +1,187 @@
+package upd
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/donkeywon/golib/consts"
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/cmd"
+	"github.com/donkeywon/golib/util/httpc"
+)
+
+const (
+	EnvPrevBinary = "UPD_PREV_BINARY"
+	EnvBinary     = "UPD_BINARY"
+	EnvUpgradePid = "UPD_UPGRADE_PID"
+
+	healthProbeInterval = time.Second
+	killUpgradeTimeout  = 10 * time.Second
+)
+
+// keepPrevBinary copy current binary to PrevBinaryPath.
+func (u *upd) keepPrevBinary() error {
+	fi, err := os.Stat(consts.ExecPath)
+	if err != nil {
+		return errs.Wrapf(err, "stat binary failed: %s", consts.ExecPath)
+	}
+	u.binaryInfo = fi
+	if u.Cfg.PrevBinaryPath == "" {
+		return nil
+	}
+	err = copyFile(consts.ExecPath, u.Cfg.PrevBinaryPath)
+	if err != nil {
+		return errs.Wrapf(err, "keep previous binary failed: %s", u.Cfg.PrevBinaryPath)
+	}
+	u.Info("previous binary kept", "path", u.Cfg.PrevBinaryPath)
+	return nil
+}
+
+// probeHealth wait new version healthy, return true if HealthProbeURL is empty.
+func (u *upd) probeHealth() bool {
+	if u.Cfg.HealthProbeURL == "" {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), u.Cfg.HealthProbeTimeout)
+	defer cancel()
+	t := time.NewTicker(healthProbeInterval)
+	defer t.Stop()
+	for {
+		resp, err := httpc.Get(ctx, healthProbeInterval, u.Cfg.HealthProbeURL, httpc.CheckStatusCodeRange(200, 299))
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err == nil {
+			u.Info("new version is healthy", "url", u.Cfg.HealthProbeURL)
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			u.Error("new version is not healthy", err, "url", u.Cfg.HealthProbeURL, "timeout", u.Cfg.HealthProbeTimeout)
+			return false
+		case <-t.C:
+		}
+	}
+}
+
+// rollback run RollbackCmd, or copy the previous binary back if RollbackCmd is empty and the binary is replaced.
+// upgradePid is the pid of upgrade cmd, 0 if unknown.
+// It returns whether the binary is the previous one after rollback, false if RollbackCmd is run.
+func (u *upd) rollback(upgradePid int) bool {
+	u.progress.setPhase(PhaseRollback)
+	if len(u.Cfg.RollbackCmd) == 0 {
+		if !u.binaryReplaced() {
+			u.Info("binary is not replaced, skip restoring previous binary", "binary", consts.ExecPath)
+			return true
+		}
+		if u.Cfg.PrevBinaryPath == "" {
+			u.Warn("no rollback cmd and previous binary, skip rollback")
+			return false
+		}
+		err := copyFile(u.Cfg.PrevBinaryPath, consts.ExecPath)
+		if err != nil {
+			u.Error("restore previous binary failed", err, "prev", u.Cfg.PrevBinaryPath, "binary", consts.ExecPath)
+			return false
+		}
+		u.Info("previous binary restored", "prev", u.Cfg.PrevBinaryPath, "binary", consts.ExecPath)
+		return true
+	}
+
+	u.Info("start exec rollback cmd")
+	cmdCfg := cmd.NewCfg()
+	cmdCfg.Command = u.Cfg.RollbackCmd
+	cmdResult := cmd.Run(context.Background(), cmdCfg, func(c *exec.Cmd) {
+		c.Env = append(os.Environ(),
+			EnvPrevBinary+"="+u.Cfg.PrevBinaryPath,
+			EnvBinary+"="+consts.ExecPath,
+			EnvUpgradePid+"="+strconv.Itoa(upgradePid))
+	})
+	if cmdResult.Err() != nil {
+		u.Error("exec rollback cmd failed", cmdResult.Err(), "result", cmdResult.String())
+		return false
+	}
+	u.Info("rollback cmd done", "result", cmdResult.String())
+	return false
+}
+
+// binaryReplaced return whether the binary is changed since keepPrevBinary, it is treated as replaced if unknown.
+func (u *upd) binaryReplaced() bool {
+	if u.binaryInfo == nil {
+		return true
+	}
+	fi, err := os.Stat(consts.ExecPath)
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(u.binaryInfo, fi) || fi.Size() != u.binaryInfo.Size() || !fi.ModTime().Equal(u.binaryInfo.ModTime())
+}
+
+// killUpgrade kill process group of upgrade cmd, which includes the new version started by it.
+// The new version started out of the group, e.g. by a service manager, should be handled by RollbackCmd.
+func (u *upd) killUpgrade(cmdResult *cmd.Result) {
+	err := cmd.KillGroup(cmdResult.Cmd())
+	if err != nil {
+		u.Error("kill upgrade process group failed", err, "pid", cmdResult.Pid)
+	}
+	select {
+	case <-cmdResult.Done():
+	case <-time.After(killUpgradeTimeout):
+		u.Warn("upgrade process not exit after killed", "pid", cmdResult.Pid)
+	}
+}
+
+// restartPrev start the previous binary with the same args and env, daemons of current process are all stopped.
+func (u *upd) restartPrev() {
+	cmdCfg := cmd.NewCfg()
+	cmdCfg.Command = append([]string{consts.ExecPath}, os.Args[1:]...)
+	cmdCfg.SetPgid = true
+	cmdResult := cmd.Start(context.Background(), cmdCfg, func(c *exec.Cmd) {
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+	})
+	if cmdResult.Pid <= 0 {
+		u.Error("restart previous version failed", cmdResult.Err(), "binary", consts.ExecPath)
+		return
+	}
+	u.Info("previous version restarted", "pid", cmdResult.Pid)
+}
+
+// copyFile copy src to dst with the same mode, dst is replaced atomically.
+func copyFile(src string, dst string) error {
+	sf, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+	fi, err := sf.Stat()
+	if err != nil {
+		return err
+	}
+
+	tf, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tf.Name())
+	_, err = io.Copy(tf, sf)
+	if err == nil {
+		err = tf.Chmod(fi.Mode())
+	}
+	if err == nil {
+		err = tf.Sync()
+	}
+	if e := tf.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tf.Name(), dst)
+}
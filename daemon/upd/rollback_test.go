@@ -0,0 +1,18 @@
+package upd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackNotReplaced(t *testing.T) {
+	u := New().(*upd)
+	u.Cfg = NewCfg()
+	u.Cfg.PrevBinaryPath = ""
+	require.True(t, u.binaryReplaced())
+
+	require.NoError(t, u.keepPrevBinary())
+	require.False(t, u.binaryReplaced())
+	require.True(t, u.rollback(0))
+}
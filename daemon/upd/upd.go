@@ -2,6 +2,7 @@ package upd
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
 	"os"
 	"os/exec"
@@ -14,6 +15,7 @@ import (
 	"github.com/donkeywon/golib/pipeline"
 	"github.com/donkeywon/golib/runner"
 	"github.com/donkeywon/golib/util/cmd"
+	"github.com/donkeywon/golib/util/handover"
 	"github.com/donkeywon/golib/util/paths"
 	"github.com/donkeywon/golib/util/v"
 )
@@ -25,20 +27,35 @@ var (
 )
 
 var _ Upd = (*upd)(nil)
+var _ boot.Prerequisite = (*upd)(nil)
 
 type Upd interface {
 	boot.Daemon
+	// Upgrade download the new package and run upgrade cmd in the background.
+	// With Cfg.Handover, the process started by upgrade cmd inherits listeners and
+	// current process drains and exits after it ready, otherwise all daemons are stopped before upgrade cmd.
+	// The package is verified against VerInfo.Checksum and Signature before upgrade cmd, and the new version
+	// is rolled back by Cfg.RollbackCmd if it is not ready or healthy. ErrNotInRollout is returned if this
+	// host is not selected by VerInfo.RolloutPercent.
 	Upgrade(vi *VerInfo) error
+	// Progress return progress of the last upgrade, nil if never upgraded.
+	Progress() *Progress
+	SetAllowedIPsGetter(func() map[string]struct{})
 }
 
-// upd must be first daemon if need
+// upd is required by all other daemons, so it is stopped last and
+// can exec the new binary after all other daemons done.
 type upd struct {
 	runner.Runner
 	*Cfg
 
+	pubKey             ed25519.PublicKey
+	allowedIPsGetter   func() map[string]struct{}
+	progress           progress
 	upgrading          atomic.Bool
 	upgradingBlockChan chan struct{}
 	allDoneExceptMe    chan struct{}
+	binaryInfo         os.FileInfo // stat of binary before upgrade, to know whether it is replaced
 }
 
 func New() boot.Daemon {
@@ -49,6 +66,25 @@ func New() boot.Daemon {
 	}
 }
 
+func (u *upd) Init() error {
+	var err error
+	u.pubKey, err = parsePublicKey(u.Cfg.PublicKey)
+	if err != nil {
+		return errs.Wrap(err, "invalid public key")
+	}
+	if u.Cfg.EnableHTTPAPI {
+		err = u.registerAPI()
+		if err != nil {
+			return err
+		}
+	}
+	return u.Runner.Init()
+}
+
+func (u *upd) RequiredBy() []boot.DaemonType {
+	return []boot.DaemonType{boot.All}
+}
+
 func (u *upd) Stop() error {
 	u.Cancel()
 	if u.isUpgrading() {
@@ -59,6 +95,15 @@ func (u *upd) Stop() error {
 	return u.Runner.Stop()
 }
 
+func (u *upd) Progress() *Progress {
+	return u.progress.get()
+}
+
+// SetAllowedIPsGetter restrict http api to the allowed ips, it must be called before Init.
+func (u *upd) SetAllowedIPsGetter(allowedIPsGetter func() map[string]struct{}) {
+	u.allowedIPsGetter = allowedIPsGetter
+}
+
 func (u *upd) markUpgrading() bool {
 	return u.upgrading.CompareAndSwap(false, true)
 }
@@ -81,6 +126,7 @@ func (u *upd) Upgrade(vi *VerInfo) error {
 		u.unmarkUpgrading()
 		return errs.Wrap(err, "prepare upgrade failed")
 	}
+	u.progress.start(vi.Ver)
 
 	go func() {
 		defer func() {
@@ -88,16 +134,12 @@ func (u *upd) Upgrade(vi *VerInfo) error {
 
 			err := recover()
 			if err != nil {
-				u.Error("panic on upgrade", errs.PanicToErr(err))
+				u.fail("panic on upgrade", errs.PanicToErr(err))
 			}
 		}()
 
-		downloadSuccessfully := u.upgrade(vi)
-
-		if !downloadSuccessfully {
-			// download failed, just return for next upgrade
-			return
-		}
+		// upgrade returns only if failed or handed over, otherwise the process exits
+		u.upgrade(vi)
 
 		select {
 		case <-u.Stopping():
@@ -115,6 +157,15 @@ func (u *upd) prepareUpgrade(vi *VerInfo) error {
 		return errs.Wrap(err, "version info is invalid")
 	}
 
+	if !inRollout(vi.Ver, vi.RolloutPercent) {
+		return ErrNotInRollout
+	}
+
+	err = verifySignature(vi, u.pubKey, buildinfo.Version)
+	if err != nil {
+		return errs.Wrap(err, "verify package signature failed")
+	}
+
 	upgradeCmd := vi.UpgradeCmd
 	if len(upgradeCmd) == 0 {
 		upgradeCmd = u.Cfg.UpgradeCmd
@@ -154,16 +205,28 @@ func (u *upd) prepareUpgrade(vi *VerInfo) error {
 	return nil
 }
 
-func (u *upd) upgrade(vi *VerInfo) bool {
+func (u *upd) upgrade(vi *VerInfo) {
 	u.Info("start download new package", "ver", vi.Ver)
-	err := u.downloadPackage(vi.DownloadDstPath, vi.StoreCfg)
+	err := u.downloadPackage(vi)
 	if err != nil {
-		u.Error("download new package failed", err)
-		return false
+		u.fail("download new package failed", err)
+		return
+	}
+
+	err = u.keepPrevBinary()
+	if err != nil {
+		u.fail("prepare rollback failed", err)
+		return
+	}
+	u.Info("download new package done, start upgrade", "cur_ver", buildinfo.Version, "new_ver", vi.Ver, "handover", u.Cfg.Handover)
+
+	if u.Cfg.Handover {
+		u.handover(vi)
+		return
 	}
-	u.Info("download new package done, start upgrade", "cur_ver", buildinfo.Version, "new_ver", vi.Ver)
 
 	// 没有退路可言，no going back
+	u.progress.setPhase(PhaseUpgrade)
 	go func() {
 		u.Info("stopping all")
 		runner.StopAndWait(u.Parent())
@@ -176,6 +239,63 @@ func (u *upd) upgrade(vi *VerInfo) bool {
 		u.Info("all daemon stopped except me")
 	}
 
+	u.Info("start exec upgrade cmd")
+	cmdResult := u.startUpgradeCmd(vi)
+	if cmdResult.Pid <= 0 {
+		u.Error("exec upgrade cmd failed", cmdResult.Err(), "result", cmdResult.String())
+		os.Exit(1)
+	}
+
+	u.progress.setPhase(PhaseProbe)
+	if !u.probeHealth() {
+		u.killUpgrade(cmdResult)
+		if u.rollback(cmdResult.Pid) {
+			u.restartPrev()
+		}
+		os.Exit(1)
+	}
+
+	u.Info("upgrade cmd started, exit now")
+	os.Exit(0)
+}
+
+// handover start the new process with listeners while still serving, then stop all to drain after it ready.
+// If the new process is not ready in time, it is killed and current process keeps serving.
+func (u *upd) handover(vi *VerInfo) {
+	h, err := handover.New()
+	if err != nil {
+		u.fail("prepare handover failed", err)
+		return
+	}
+	defer h.Close()
+
+	u.Info("start exec upgrade cmd with handover")
+	u.progress.setPhase(PhaseHandover)
+	cmdResult := u.startUpgradeCmd(vi, h.Setup)
+	if cmdResult.Pid <= 0 {
+		u.fail("exec upgrade cmd failed", cmdResult.Err(), "result", cmdResult.String())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(u.Ctx(), u.Cfg.HandoverReadyTimeout)
+	defer cancel()
+	err = h.WaitReady(ctx, cmdResult.Done())
+	if err != nil {
+		u.Error("new process is not ready, rollback", err, "pid", cmdResult.Pid)
+		u.killUpgrade(cmdResult)
+		u.rollback(cmdResult.Pid)
+		u.fail("handover failed", err)
+		return
+	}
+
+	u.Info("new process ready, stopping all", "pid", cmdResult.Pid)
+	u.progress.setPhase(PhaseDone)
+	// stop without waiting upgrade done, the new process keeps running after exit
+	u.unmarkUpgrading()
+	go runner.StopAndWait(u.Parent())
+}
+
+func (u *upd) startUpgradeCmd(vi *VerInfo, beforeStart ...func(*exec.Cmd)) *cmd.Result {
 	upgradeCmd := vi.UpgradeCmd
 	if len(upgradeCmd) == 0 {
 		upgradeCmd = u.Cfg.UpgradeCmd
@@ -187,39 +307,73 @@ func (u *upd) upgrade(vi *VerInfo) bool {
 	upgradeOutputFile, err := os.OpenFile(upgradeOutputPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
 		u.Error("open upgrade output file failed", err, "path", upgradeOutputPath)
+	} else {
+		// the started process has its own fd
+		defer upgradeOutputFile.Close()
+		beforeStart = append([]func(*exec.Cmd){func(cmd *exec.Cmd) {
+			cmd.Stdout = upgradeOutputFile
+			cmd.Stderr = upgradeOutputFile
+		}}, beforeStart...)
 	}
 
-	u.Info("start exec upgrade cmd")
 	cmdCfg := cmd.NewCfg()
 	cmdCfg.Command = upgradeCmd
 	cmdCfg.SetPgid = true
-	cmdResult := cmd.Start(context.Background(), cmdCfg, func(cmd *exec.Cmd) {
-		cmd.Stdout = upgradeOutputFile
-		cmd.Stderr = upgradeOutputFile
-	})
-	if cmdResult.Pid <= 0 {
-		u.Error("exec upgrade cmd failed", cmdResult.Err(), "result", cmdResult.String())
-		os.Exit(1)
-	}
-
-	u.Info("upgrade cmd started, exit now")
-	os.Exit(0)
-	return true
+	return cmd.Start(context.Background(), cmdCfg, beforeStart...)
 }
 
-func (u *upd) downloadPackage(downloadDstPath string, storeCfg *pipeline.ReaderCfg) error {
+// downloadPackage download package to DownloadDstPath and verify it against checksum.
+func (u *upd) downloadPackage(vi *VerInfo) error {
+	storeCfg := *vi.StoreCfg.CommonCfgWithOption
+	if vi.Checksum != "" {
+		opt := pipeline.CommonOption{}
+		if storeCfg.CommonOption != nil {
+			opt = *storeCfg.CommonOption
+		}
+		opt.Hash = vi.hash()
+		opt.Checksum = vi.Checksum
+		storeCfg.CommonOption = &opt
+	}
+
 	cfg := pipeline.NewCfg()
 	cfg.Add(pipeline.WorkerCopy, pipeline.NewCopyCfg(), &pipeline.CommonOption{}).
-		ReadFromReader(storeCfg.CommonCfgWithOption).
-		WriteTo(pipeline.WriterFile, &pipeline.FileCfg{Path: downloadDstPath}, &pipeline.CommonOption{})
+		ReadFromReader(&storeCfg).
+		WriteTo(pipeline.WriterFile, &pipeline.FileCfg{Path: vi.DownloadDstPath}, &pipeline.CommonOption{})
 
 	p := pipeline.New()
 	p.SetCfg(cfg)
 	p.Inherit(u)
+	for _, r := range p.Workers()[0].Readers() {
+		if c, ok := r.(pipeline.Common); ok {
+			c.WithOptions(pipeline.ProgressRead(u.progress.setDownloaded))
+		}
+	}
 	err := runner.Init(p)
 	if err != nil {
 		return errs.Wrap(err, "init download pipeline failed")
 	}
 
-	return runner.Run(p)
+	err = runner.Run(p)
+	if err != nil {
+		return err
+	}
+
+	// checksum is not verified if nothing read
+	u.progress.setPhase(PhaseVerify)
+	if vi.Checksum != "" {
+		fi, err := os.Stat(vi.DownloadDstPath)
+		if err != nil {
+			return errs.Wrap(err, "stat downloaded package failed")
+		}
+		if fi.Size() == 0 {
+			return errs.New("downloaded package is empty")
+		}
+	}
+	return nil
+}
+
+// fail log and record the failure to progress.
+func (u *upd) fail(msg string, err error, kvs ...any) {
+	u.Error(msg, err, kvs...)
+	u.progress.fail(errs.Wrap(err, msg))
 }
@@ -5,9 +5,26 @@ import (
 )
 
 type VerInfo struct {
-	Ver               string              `json:"ver"               yaml:"ver"             validate:"required"`
-	StoreCfg          *pipeline.ReaderCfg `json:"storeCfg"          yaml:"storeCfg"        validate:"required"`
-	DownloadDstPath   string              `json:"downloadDstPath"   yaml:"downloadDstPath" validate:"required"`
+	Ver               string              `json:"ver"               yaml:"ver"               validate:"required"`
+	StoreCfg          *pipeline.ReaderCfg `json:"storeCfg"          yaml:"storeCfg"          validate:"required"`
+	DownloadDstPath   string              `json:"downloadDstPath"   yaml:"downloadDstPath"   validate:"required"`
 	UpgradeCmd        []string            `json:"upgradeCmd"        yaml:"upgradeCmd"`
 	UpgradeOutputPath string              `json:"upgradeOutputPath" yaml:"upgradeOutputPath"`
+
+	// Hash is the algorithm of Checksum, same as pipeline.CommonOption.Hash, default is sha256.
+	Hash string `json:"hash" yaml:"hash"`
+	// Checksum is the hex checksum of package, the package is verified on download if not empty.
+	Checksum string `json:"checksum" yaml:"checksum"`
+	// Signature is the base64 ed25519 signature of SignedMessage, which covers Ver, Checksum, UpgradeCmd
+	// and RolloutPercent, it is required if Cfg.PublicKey is set.
+	Signature string `json:"signature" yaml:"signature"`
+	// RolloutPercent upgrade only the given percent of hosts, 0 means all.
+	RolloutPercent int `json:"rolloutPercent" yaml:"rolloutPercent" validate:"min=0,max=100"`
+}
+
+func (vi *VerInfo) hash() string {
+	if vi.Hash == "" {
+		return DefaultHash
+	}
+	return vi.Hash
 }
@@ -0,0 +1,157 @@
+package upd
+
+import (
+	"cmp"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+const DefaultHash = "sha256"
+
+var ErrNotInRollout = errors.New("not in rollout")
+
+// SignedMessage return the message that VerInfo.Signature signs, it binds the package checksum to
+// Ver, UpgradeCmd and RolloutPercent, so none of them can be changed without the private key.
+// Publishers sign it by ed25519.Sign(privateKey, SignedMessage(vi)).
+func SignedMessage(vi *VerInfo) []byte {
+	var sb strings.Builder
+	sb.WriteString("upd signed message v1\n")
+	sb.WriteString("ver " + strconv.Quote(vi.Ver) + "\n")
+	sb.WriteString("hash " + strconv.Quote(vi.hash()) + "\n")
+	sb.WriteString("checksum " + strconv.Quote(strings.ToLower(vi.Checksum)) + "\n")
+	sb.WriteString("upgradeCmd")
+	for _, arg := range vi.UpgradeCmd {
+		sb.WriteString(" " + strconv.Quote(arg))
+	}
+	sb.WriteString("\n")
+	sb.WriteString("rolloutPercent " + strconv.Itoa(vi.RolloutPercent) + "\n")
+	return []byte(sb.String())
+}
+
+// verifySignature verify that SignedMessage of vi is signed by pubKey and vi.Ver is newer than curVer,
+// the package itself is verified against vi.Checksum on download, so a valid signature means
+// the package and how it is installed are authentic.
+// A signed package of an older version is rejected, otherwise it could be replayed to downgrade.
+func verifySignature(vi *VerInfo, pubKey ed25519.PublicKey, curVer string) error {
+	if pubKey == nil {
+		if vi.Signature != "" {
+			return errs.New("package is signed but public key is not configured")
+		}
+		return nil
+	}
+	if vi.Signature == "" {
+		return errs.New("package is not signed")
+	}
+	if vi.hash() != DefaultHash {
+		return errs.Errorf("signed package must use %s checksum, got %s", DefaultHash, vi.hash())
+	}
+	digest, err := hex.DecodeString(vi.Checksum)
+	if err != nil || len(digest) == 0 {
+		return errs.Errorf("invalid checksum: %s", vi.Checksum)
+	}
+	sig, err := base64.StdEncoding.DecodeString(vi.Signature)
+	if err != nil {
+		return errs.Wrap(err, "decode signature failed")
+	}
+	if !ed25519.Verify(pubKey, SignedMessage(vi), sig) {
+		return errs.New("signature mismatch")
+	}
+	if c, ok := compareVer(vi.Ver, curVer); ok && c <= 0 {
+		return errs.Errorf("version %s is not newer than current version %s", vi.Ver, curVer)
+	}
+	return nil
+}
+
+// compareVer compare versions like v1.2.3 by numeric segments, ok is false if any of them is not
+// in this form, e.g. a dev build.
+func compareVer(a, b string) (int, bool) {
+	as, ok := parseVer(a)
+	if !ok {
+		return 0, false
+	}
+	bs, ok := parseVer(b)
+	if !ok {
+		return 0, false
+	}
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y), true
+		}
+	}
+	return 0, true
+}
+
+func parseVer(s string) ([]int, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return nil, false
+	}
+	segs := strings.Split(s, ".")
+	nums := make([]int, len(segs))
+	for i, seg := range segs {
+		n, err := strconv.Atoi(seg)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
+
+// parsePublicKey parse ed25519 public key in PEM or base64 of raw key, empty s means no key.
+func parsePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errs.Wrap(err, "parse public key failed")
+		}
+		pubKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errs.Errorf("public key is not ed25519: %T", key)
+		}
+		return pubKey, nil
+	}
+
+	bs, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errs.Wrap(err, "decode public key failed")
+	}
+	if len(bs) != ed25519.PublicKeySize {
+		return nil, errs.Errorf("invalid public key size: %d", len(bs))
+	}
+	return bs, nil
+}
+
+// inRollout report whether this host is in the first percent of hosts to upgrade to ver.
+// Hosts are ordered by hash of hostname and ver, so raising percent of the same ver only adds hosts.
+func inRollout(ver string, percent int) bool {
+	if percent <= 0 || percent >= 100 {
+		return true
+	}
+	hostname, _ := os.Hostname()
+	h := fnv.New32a()
+	h.Write([]byte(hostname + "/" + ver))
+	return int(h.Sum32()%100) < percent
+}
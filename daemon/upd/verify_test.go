@@ -0,0 +1,83 @@
+package upd
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte("package"))
+	sign := func(vi *VerInfo) *VerInfo {
+		vi.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, SignedMessage(vi)))
+		return vi
+	}
+	vi := sign(&VerInfo{
+		Ver:        "v1.2.0",
+		Checksum:   hex.EncodeToString(digest[:]),
+		UpgradeCmd: []string{"sh", "upgrade.sh"},
+	})
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	for _, s := range []string{
+		base64.StdEncoding.EncodeToString(pub),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	} {
+		key, err := parsePublicKey(s)
+		require.NoError(t, err)
+		require.NoError(t, verifySignature(vi, key, "v1.1.9"))
+	}
+
+	tampered := func(f func(*VerInfo)) *VerInfo {
+		cp := *vi
+		f(&cp)
+		return &cp
+	}
+	other := sha256.Sum256([]byte("other"))
+	require.Error(t, verifySignature(tampered(func(vi *VerInfo) { vi.Checksum = hex.EncodeToString(other[:]) }), pub, ""))
+	require.Error(t, verifySignature(tampered(func(vi *VerInfo) { vi.Ver = "v1.3.0" }), pub, ""))
+	require.Error(t, verifySignature(tampered(func(vi *VerInfo) { vi.UpgradeCmd = []string{"sh", "-c", "evil"} }), pub, ""))
+	require.Error(t, verifySignature(tampered(func(vi *VerInfo) { vi.RolloutPercent = 10 }), pub, ""))
+	require.Error(t, verifySignature(tampered(func(vi *VerInfo) { vi.Signature = "" }), pub, ""))
+	require.Error(t, verifySignature(tampered(func(vi *VerInfo) { vi.Hash = "md5" }), pub, ""))
+	require.Error(t, verifySignature(vi, nil, ""))
+	require.NoError(t, verifySignature(&VerInfo{}, nil, ""))
+
+	// replay of an old signed package
+	require.ErrorContains(t, verifySignature(vi, pub, "v1.2.0"), "not newer")
+	require.ErrorContains(t, verifySignature(vi, pub, "v1.10.0"), "not newer")
+	require.NoError(t, verifySignature(vi, pub, "dev"))
+}
+
+func TestCompareVer(t *testing.T) {
+	c, ok := compareVer("v1.10.0", "v1.9")
+	require.True(t, ok)
+	require.Equal(t, 1, c)
+	c, ok = compareVer("1.2", "v1.2.0")
+	require.True(t, ok)
+	require.Equal(t, 0, c)
+	_, ok = compareVer("v1.2.0-rc1", "v1.2.0")
+	require.False(t, ok)
+}
+
+func TestInRollout(t *testing.T) {
+	require.True(t, inRollout("v1", 0))
+	require.True(t, inRollout("v1", 100))
+	in := 0
+	for p := 1; p < 100; p++ {
+		if inRollout("v1", p) {
+			in++
+			require.True(t, inRollout("v1", p+1), "hosts in rollout must stay in when percent raised")
+		}
+	}
+	require.Positive(t, in)
+}
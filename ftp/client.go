@@ -13,6 +13,7 @@ import (
 
 	"github.com/avast/retry-go/v4"
 	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/secret"
 )
 
 const (
@@ -24,11 +25,11 @@ const (
 var location, _ = time.LoadLocation("Asia/Shanghai")
 
 type Cfg struct {
-	Addr    string `json:"addr"    validate:"required" yaml:"addr"`
-	User    string `json:"user"    validate:"required" yaml:"user"`
-	Pwd     string `json:"pwd"     yaml:"pwd"`
-	Timeout int    `json:"timeout" validate:"gte=1"    yaml:"timeout"`
-	Retry   int    `json:"retry"   validate:"gte=1"    yaml:"retry"`
+	Addr    string        `json:"addr"    validate:"required" yaml:"addr"`
+	User    string        `json:"user"    validate:"required" yaml:"user"`
+	Pwd     secret.String `json:"pwd"     yaml:"pwd"`
+	Timeout int           `json:"timeout" validate:"gte=1"    yaml:"timeout"`
+	Retry   int           `json:"retry"   validate:"gte=1"    yaml:"retry"`
 }
 
 func NewCfg() *Cfg {
@@ -210,7 +211,7 @@ func (c *Client) login() error {
 	switch code {
 	case StatusLoggedIn:
 	case StatusUserOK:
-		_, _, err = c.cmd(StatusLoggedIn, "PASS %s", c.Pwd)
+		_, _, err = c.cmd(StatusLoggedIn, "PASS %s", c.Pwd.Value())
 		if err != nil {
 			return errs.Wrap(err, "ftp cmd PASS failed")
 		}
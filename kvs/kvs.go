@@ -2,7 +2,11 @@ package kvs
 
 import (
 	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/plugin"
 	"github.com/donkeywon/golib/util/conv"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/yamls"
+	"github.com/tidwall/gjson"
 )
 
 type Type string
@@ -12,6 +16,41 @@ type Cfg struct {
 	Cfg  any  `yaml:"cfg"  json:"cfg"`
 }
 
+type kvsCfgOnlyCfg struct {
+	Cfg any `json:"cfg" yaml:"cfg"`
+}
+
+func (c *Cfg) UnmarshalJSON(data []byte) error {
+	return c.customUnmarshal(data, jsons.Unmarshal)
+}
+
+func (c *Cfg) UnmarshalYAML(data []byte) error {
+	return c.customUnmarshal(data, yamls.Unmarshal)
+}
+
+func (c *Cfg) customUnmarshal(data []byte, unmarshaler func([]byte, any) error) error {
+	typ := gjson.GetBytes(data, "type")
+	if !typ.Exists() {
+		return errs.Errorf("kvs type is not present")
+	}
+	if typ.Type != gjson.String {
+		return errs.Errorf("invalid kvs type")
+	}
+	c.Type = Type(typ.Str)
+
+	cv := kvsCfgOnlyCfg{}
+	cv.Cfg = plugin.CreateCfg[any](c.Type)
+	if cv.Cfg == nil {
+		return nil
+	}
+	err := unmarshaler(data, &cv)
+	if err != nil {
+		return err
+	}
+	c.Cfg = cv.Cfg
+	return nil
+}
+
 type KVS interface {
 	Open() error
 	Close() error
@@ -129,7 +129,7 @@ func (w *AppendWriter) sealBlob() error {
 	}
 	return retry.Do(
 		func() error {
-			return oss.SealAppendBlob(w.ctx, w.cfg.URL, w.cfg.Ak, w.cfg.Sk)
+			return oss.SealAppendBlob(w.ctx, w.cfg.URL, w.cfg.Ak.Value(), w.cfg.Sk.Value())
 		},
 		retry.Attempts(uint(w.cfg.Retry)),
 		retry.RetryIf(func(err error) bool {
@@ -149,7 +149,7 @@ func (w *AppendWriter) init() error {
 		return nil
 	}
 	err := retry.Do(
-		func() error { return oss.CreateAppendBlob(w.ctx, w.cfg.URL, w.cfg.Ak, w.cfg.Sk) },
+		func() error { return oss.CreateAppendBlob(w.ctx, w.cfg.URL, w.cfg.Ak.Value(), w.cfg.Sk.Value()) },
 		retry.Attempts(uint(w.cfg.Retry)),
 		retry.RetryIf(func(err error) bool {
 			select {
@@ -169,7 +169,7 @@ func (w *AppendWriter) init() error {
 }
 
 func (w *AppendWriter) addAuth(req *http.Request) error {
-	return oss.Sign(req, w.cfg.Ak, w.cfg.Sk, w.cfg.Region)
+	return oss.Sign(req, w.cfg.Ak.Value(), w.cfg.Sk.Value(), w.cfg.Region)
 }
 
 func (w *AppendWriter) retryAppendPart(p []byte) error {
@@ -1,15 +1,17 @@
 package oss
 
+import "github.com/donkeywon/golib/util/secret"
+
 type Cfg struct {
-	URL      string `json:"url"            yaml:"url"     validate:"required"`
-	Ak       string `json:"ak"             yaml:"ak"`
-	Sk       string `json:"sk"             yaml:"sk"`
-	Region   string `json:"region"         yaml:"region"`
-	Retry    int    `json:"retry"          yaml:"retry"`
-	Timeout  int    `json:"timeout"        yaml:"timeout"`
-	Offset   int64  `json:"offset"         yaml:"offset"`
-	PartSize int64  `json:"partSize"       yaml:"partSize"`
-	Parallel int    `json:"parallel"       yaml:"parallel"`
+	URL      string        `json:"url"            yaml:"url"     validate:"required"`
+	Ak       secret.String `json:"ak"             yaml:"ak"`
+	Sk       secret.String `json:"sk"             yaml:"sk"`
+	Region   string        `json:"region"         yaml:"region"`
+	Retry    int           `json:"retry"          yaml:"retry"`
+	Timeout  int           `json:"timeout"        yaml:"timeout"`
+	Offset   int64         `json:"offset"         yaml:"offset"`
+	PartSize int64         `json:"partSize"       yaml:"partSize"`
+	Parallel int           `json:"parallel"       yaml:"parallel"`
 }
 
 func (c *Cfg) setDefaults() {
@@ -545,7 +545,7 @@ func (w *MultiPartWriter) complete() error {
 }
 
 func (w *MultiPartWriter) addAuth(req *http.Request) error {
-	return oss.Sign(req, w.cfg.Ak, w.cfg.Sk, w.cfg.Region)
+	return oss.Sign(req, w.cfg.Ak.Value(), w.cfg.Sk.Value(), w.cfg.Region)
 }
 
 func (w *MultiPartWriter) initMultiPart() (string, error) {
@@ -22,7 +22,7 @@ func NewReader(ctx context.Context, cfg *Cfg, opts ...httpc.Option) *Reader {
 	cfg.setDefaults()
 	allHttpcOptions := make([]httpc.Option, 0, 1+len(opts))
 	allHttpcOptions = append(allHttpcOptions, httpc.ReqOptionFunc(func(r *http.Request) error {
-		return oss.Sign(r, cfg.Ak, cfg.Sk, cfg.Region)
+		return oss.Sign(r, cfg.Ak.Value(), cfg.Sk.Value(), cfg.Region)
 	}))
 	allHttpcOptions = append(allHttpcOptions, opts...)
 
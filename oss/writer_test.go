@@ -66,7 +66,7 @@ type fileWithoutWriteTo struct {
 }
 
 func testWriter(t *testing.T, w io.Writer, c *Cfg, bufSize int) {
-	err := oss.Delete(context.TODO(), time.Minute, c.URL, c.Ak, c.Sk, c.Region)
+	err := oss.Delete(context.TODO(), time.Minute, c.URL, c.Ak.Value(), c.Sk.Value(), c.Region)
 	require.NoError(t, err)
 
 	f, _ := os.OpenFile("/tmp/test.file", os.O_RDWR, 0644)
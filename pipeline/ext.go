@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"io"
+
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/plugin/ext"
+	"github.com/donkeywon/golib/util/v"
+)
+
+func init() {
+	plugin.Reg(ReaderExt, func() Reader { return NewExtReader() }, func() any { return ext.NewCfg() })
+	plugin.Reg(WriterExt, func() Writer { return NewExtWriter() }, func() any { return ext.NewCfg() })
+}
+
+const (
+	ReaderExt Type = "rext"
+	WriterExt Type = "wext"
+)
+
+// RegExtReader register an out-of-process reader plugin as typ, cfg of typ is passed to the plugin as is.
+func RegExtReader(typ Type, command ...string) {
+	plugin.Reg(typ, func() Reader {
+		r := newExtReader(typ)
+		r.Command = command
+		return r
+	}, func() any { return &map[string]any{} })
+}
+
+// RegExtWriter register an out-of-process writer plugin as typ, cfg of typ is passed to the plugin as is.
+func RegExtWriter(typ Type, command ...string) {
+	plugin.Reg(typ, func() Writer {
+		w := newExtWriter(typ)
+		w.Command = command
+		return w
+	}, func() any { return &map[string]any{} })
+}
+
+func startExt(c Common, cfg *ext.Cfg, kind string) (*ext.Client, error) {
+	err := v.Struct(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.WithLoggerFields("plugin", cfg.Command[0])
+
+	client, err := ext.Start(cfg, func(line string) { c.Info("plugin stderr", "line", line) })
+	if err != nil {
+		return nil, err
+	}
+	err = client.Init(kind, cfg.Cfg)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func setExtCfg(c *ext.Cfg, cfg any) *ext.Cfg {
+	if ec, ok := cfg.(*ext.Cfg); ok {
+		return ec
+	}
+	// registered by RegExtReader or RegExtWriter, cfg is for plugin
+	c.Cfg = cfg
+	return c
+}
+
+// ExtReader read from a plugin process, see package ext.
+type ExtReader struct {
+	Reader
+	*ext.Cfg
+}
+
+func NewExtReader() *ExtReader {
+	return newExtReader(ReaderExt)
+}
+
+func newExtReader(typ Type) *ExtReader {
+	return &ExtReader{
+		Reader: CreateReader(string(typ)),
+		Cfg:    ext.NewCfg(),
+	}
+}
+
+func (e *ExtReader) Init() error {
+	client, err := startExt(e, e.Cfg, ext.KindReader)
+	if err != nil {
+		return err
+	}
+
+	e.Reader.WrapReader(ext.NewReader(client))
+
+	return e.Reader.Init()
+}
+
+func (e *ExtReader) WrapReader(io.Reader) {
+	panic(ErrInvalidWrap)
+}
+
+func (e *ExtReader) SetCfg(cfg any) {
+	e.Cfg = setExtCfg(e.Cfg, cfg)
+}
+
+// ExtWriter write to a plugin process, see package ext.
+type ExtWriter struct {
+	Writer
+	*ext.Cfg
+}
+
+func NewExtWriter() *ExtWriter {
+	return newExtWriter(WriterExt)
+}
+
+func newExtWriter(typ Type) *ExtWriter {
+	return &ExtWriter{
+		Writer: CreateWriter(string(typ)),
+		Cfg:    ext.NewCfg(),
+	}
+}
+
+func (e *ExtWriter) Init() error {
+	client, err := startExt(e, e.Cfg, ext.KindWriter)
+	if err != nil {
+		return err
+	}
+
+	e.Writer.WrapWriter(ext.NewWriter(client))
+
+	return e.Writer.Init()
+}
+
+func (e *ExtWriter) WrapWriter(io.Writer) {
+	panic(ErrInvalidWrap)
+}
+
+func (e *ExtWriter) SetCfg(cfg any) {
+	e.Cfg = setExtCfg(e.Cfg, cfg)
+}
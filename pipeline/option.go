@@ -309,14 +309,19 @@ func (p *progressLogger) Close() error {
 
 func (p *progressLogger) Set(c Common) {
 	p.Common = c
+	p.sizeGetter = sizeGetterOf(c)
+}
+
+func sizeGetterOf(c Common) func() int64 {
 	switch s := c.(type) {
 	case hasSize:
-		p.sizeGetter = s.Size
+		return s.Size
 	case hasSize2:
-		p.sizeGetter = func() int64 {
+		return func() int64 {
 			return int64(s.Size())
 		}
 	}
+	return nil
 }
 
 func ProgressLogRead(interval time.Duration) Option {
@@ -329,6 +334,36 @@ func ProgressLogWrite(interval time.Duration) Option {
 	return multiOption{MultiWrite(p), OnClose(p.Close)}
 }
 
+type progressReporter struct {
+	sizeGetter func() int64
+	offset     int64
+	f          func(offset int64, size int64)
+}
+
+func (p *progressReporter) Write(b []byte) (int, error) {
+	p.offset += int64(len(b))
+	var size int64
+	if p.sizeGetter != nil {
+		size = p.sizeGetter()
+	}
+	p.f(p.offset, size)
+	return len(b), nil
+}
+
+func (p *progressReporter) Set(c Common) {
+	p.sizeGetter = sizeGetterOf(c)
+}
+
+// ProgressRead call f with offset and total size on each read, size is 0 if unknown.
+func ProgressRead(f func(offset int64, size int64)) Option {
+	return Tee(&progressReporter{f: f})
+}
+
+// ProgressWrite call f with offset and total size on each write, size is 0 if unknown.
+func ProgressWrite(f func(offset int64, size int64)) Option {
+	return MultiWrite(&progressReporter{f: f})
+}
+
 type rateLimit struct {
 	Common
 
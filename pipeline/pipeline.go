@@ -25,6 +25,7 @@ type Result struct {
 	Cfg           *Cfg            `json:"cfg" yaml:"cfg"`
 	Data          map[string]any  `json:"data" yaml:"data"`
 	WorkersResult []*WorkerResult `json:"workersResult" yaml:"workersResult"`
+	Errs          []*runner.Error `json:"errs,omitempty" yaml:"errs,omitempty"`
 }
 
 type Cfg struct {
@@ -180,6 +181,7 @@ func (p *Pipeline) Result() *Result {
 		Cfg:           p.cfg,
 		Data:          p.LoadAll(),
 		WorkersResult: make([]*WorkerResult, len(p.ws)),
+		Errs:          runner.Errors(p.Err()),
 	}
 	for i, w := range p.ws {
 		r.WorkersResult[i] = w.Result()
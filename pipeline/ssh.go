@@ -10,6 +10,7 @@ import (
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/plugin"
 	"github.com/donkeywon/golib/util/bufferpool"
+	"github.com/donkeywon/golib/util/secret"
 	"github.com/donkeywon/golib/util/sshs"
 	"golang.org/x/crypto/ssh"
 )
@@ -21,12 +22,12 @@ func init() {
 const WorkerSSH Type = "ssh"
 
 type SSHCfg struct {
-	Addr       string `json:"addr"       yaml:"addr" validate:"required"`
-	User       string `json:"user"       yaml:"user" validate:"required"`
-	Pwd        string `json:"pwd"        yaml:"pwd"`
-	PrivateKey string `json:"privateKey" yaml:"privateKey"`
-	Path       string `json:"path"       yaml:"path" validate:"required"`
-	Timeout    int    `json:"timeout"    yaml:"timeout"`
+	Addr       string        `json:"addr"       yaml:"addr" validate:"required"`
+	User       string        `json:"user"       yaml:"user" validate:"required"`
+	Pwd        secret.String `json:"pwd"        yaml:"pwd"`
+	PrivateKey secret.String `json:"privateKey" yaml:"privateKey"`
+	Path       string        `json:"path"       yaml:"path" validate:"required"`
+	Timeout    int           `json:"timeout"    yaml:"timeout"`
 }
 
 func NewSSHCfg() *SSHCfg {
@@ -52,7 +53,7 @@ func NewSSH() *SSH {
 
 func (s *SSH) Init() error {
 	var err error
-	s.sshCli, s.sshSess, err = sshs.NewClient(s.c.Addr, s.c.User, s.c.Pwd, []byte(s.c.PrivateKey), time.Second*time.Duration(s.c.Timeout))
+	s.sshCli, s.sshSess, err = sshs.NewClient(s.c.Addr, s.c.User, s.c.Pwd.Value(), []byte(s.c.PrivateKey.Value()), time.Second*time.Duration(s.c.Timeout))
 	if err != nil {
 		return errs.Wrap(err, "failed to create ssh client and session")
 	}
@@ -0,0 +1,297 @@
+package ext
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/jsons"
+)
+
+const (
+	KindStep   = "step"
+	KindReader = "reader"
+	KindWriter = "writer"
+
+	closeTimeout = 10 * time.Second
+)
+
+var ErrClosed = errors.New("plugin closed")
+
+type Cfg struct {
+	Command    []string          `json:"command"    yaml:"command"    validate:"required,min=1"`
+	Env        map[string]string `json:"env"        yaml:"env"`
+	WorkingDir string            `json:"workingDir" yaml:"workingDir"`
+	Cfg        any               `json:"cfg"        yaml:"cfg"` // passed to plugin on init
+}
+
+func NewCfg() *Cfg {
+	return &Cfg{}
+}
+
+// Client is the host side of a plugin process.
+type Client struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	nextID atomic.Uint64
+
+	wmu     sync.Mutex
+	mu      sync.Mutex
+	pending map[uint64]chan *Frame
+
+	done      chan struct{} // closed when no more response can be read
+	err       error
+	exited    chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Start start a plugin process, stderr of it is passed to logLine line by line.
+func Start(cfg *Cfg, logLine func(line string)) (*Client, error) {
+	if len(cfg.Command) == 0 {
+		return nil, errs.New("plugin command is empty")
+	}
+
+	c := &Client{
+		cmd:     exec.Command(cfg.Command[0], cfg.Command[1:]...),
+		pending: make(map[uint64]chan *Frame),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	c.cmd.Dir = cfg.WorkingDir
+	if len(cfg.Env) > 0 {
+		c.cmd.Env = os.Environ()
+		for k, v := range cfg.Env {
+			c.cmd.Env = append(c.cmd.Env, k+"="+v)
+		}
+	}
+
+	// os.Pipe rather than StdoutPipe, so that Wait does not close the read end before all frames are read
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, errs.Wrap(err, "create stdout pipe failed")
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return nil, errs.Wrap(err, "create stderr pipe failed")
+	}
+	c.cmd.Stdout = stdoutW
+	c.cmd.Stderr = stderrW
+	c.stdin, err = c.cmd.StdinPipe()
+	if err == nil {
+		err = c.cmd.Start()
+	}
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdoutR.Close()
+		stderrR.Close()
+		return nil, errs.Wrapf(err, "start plugin failed: %s", cfg.Command[0])
+	}
+
+	go func() {
+		c.cmd.Wait()
+		close(c.exited)
+	}()
+	go c.readLoop(stdoutR)
+	go func() {
+		defer stderrR.Close()
+		s := bufio.NewScanner(stderrR)
+		for s.Scan() {
+			if logLine != nil {
+				logLine(s.Text())
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func (c *Client) readLoop(r io.ReadCloser) {
+	defer r.Close()
+	br := bufio.NewReader(r)
+	for {
+		f, err := readFrame(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = ErrClosed
+			}
+			c.err = err
+			close(c.done)
+			return
+		}
+
+		c.mu.Lock()
+		ch, exists := c.pending[f.ID]
+		delete(c.pending, f.ID)
+		c.mu.Unlock()
+		if exists {
+			ch <- f
+		}
+	}
+}
+
+// Call send req and wait its response, error is returned if the plugin responds an error.
+func (c *Client) Call(ctx context.Context, req *Frame) (*Frame, error) {
+	req.ID = c.nextID.Add(1)
+	ch := make(chan *Frame, 1)
+	c.mu.Lock()
+	c.pending[req.ID] = ch
+	c.mu.Unlock()
+
+	c.wmu.Lock()
+	err := writeFrame(c.stdin, req)
+	c.wmu.Unlock()
+	if err != nil {
+		c.forget(req.ID)
+		select {
+		case <-c.done:
+			return nil, errs.Wrapf(c.err, "plugin %s failed", req.Call)
+		default:
+			return nil, errs.Wrapf(err, "send plugin %s failed", req.Call)
+		}
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			return resp, errs.Wrapf(errors.New(resp.Error), "plugin %s failed", req.Call)
+		}
+		return resp, nil
+	case <-c.done:
+		c.forget(req.ID)
+		return nil, errs.Wrapf(c.err, "plugin %s failed", req.Call)
+	case <-ctx.Done():
+		c.forget(req.ID)
+		return nil, errs.Wrapf(ctx.Err(), "plugin %s failed", req.Call)
+	}
+}
+
+func (c *Client) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// Init send cfg in JSON to plugin.
+func (c *Client) Init(kind string, cfg any) error {
+	data, err := jsons.Marshal(cfg)
+	if err != nil {
+		return errs.Wrap(err, "marshal plugin cfg failed")
+	}
+	_, err = c.Call(context.Background(), &Frame{Call: CallInit, Kind: kind, Data: data})
+	return err
+}
+
+// Result get result of a step plugin.
+func (c *Client) Result() (map[string]any, error) {
+	resp, err := c.Call(context.Background(), &Frame{Call: CallResult})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]any)
+	err = jsons.Unmarshal(resp.Data, &result)
+	if err != nil {
+		return nil, errs.Wrap(err, "unmarshal plugin result failed")
+	}
+	return result, nil
+}
+
+// Close send close to plugin and wait it exit, the process is killed if it does not exit in time.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+		_, err := c.Call(ctx, &Frame{Call: CallClose})
+		if errors.Is(err, ErrClosed) {
+			err = nil
+		}
+		c.stdin.Close()
+
+		select {
+		case <-c.exited:
+		case <-ctx.Done():
+			c.cmd.Process.Kill()
+			<-c.exited
+			err = errors.Join(err, errs.New("plugin not exit in time, killed"))
+		}
+		c.closeErr = err
+	})
+	return c.closeErr
+}
+
+// Reader read from a reader plugin.
+type Reader struct {
+	c   *Client
+	eof bool
+}
+
+func NewReader(c *Client) *Reader {
+	return &Reader{c: c}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.eof {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	resp, err := r.c.Call(context.Background(), &Frame{Call: CallRead, Size: uint64(min(len(p), MaxFrameSize/2))})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Data) > len(p) {
+		return 0, errs.Errorf("plugin read too much: %d > %d", len(resp.Data), len(p))
+	}
+	n := copy(p, resp.Data)
+	if resp.EOF {
+		r.eof = true
+		if n == 0 {
+			return 0, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func (r *Reader) Close() error {
+	return r.c.Close()
+}
+
+// Writer write to a writer plugin.
+type Writer struct {
+	c *Client
+}
+
+func NewWriter(c *Client) *Writer {
+	return &Writer{c: c}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n := min(len(p)-written, MaxFrameSize/2)
+		_, err := w.c.Call(context.Background(), &Frame{Call: CallWrite, Data: p[written : written+n]})
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+func (w *Writer) Close() error {
+	return w.c.Close()
+}
@@ -0,0 +1,14 @@
+// Package ext runs plugins out of process, so that steps and pipeline readers/writers can be shipped
+// as separate binaries without rebuilding the daemon.
+//
+// A plugin is a binary speaking the protocol in ext.proto over its stdin and stdout:
+// host sends Frames as requests and the plugin responds Frames with the same id and call.
+// Frames are protobuf messages prefixed with their size in varint.
+//
+// A step plugin receives init, start, result and close, and stop if the step is stopped while running.
+// A reader plugin receives init, read until eof and close, a writer plugin receives init, write and close.
+//
+// Plugins written in Go implement Handler and call Serve in main.
+// Host uses plugins by step type ext and pipeline types rext/wext with the command in cfg,
+// or by types registered with step.RegExt, pipeline.RegExtReader and pipeline.RegExtWriter.
+package ext
@@ -0,0 +1,125 @@
+package ext
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/donkeywon/golib/util/v"
+	"github.com/stretchr/testify/require"
+)
+
+const envTestPlugin = "EXT_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envTestPlugin) != "" {
+		err := Serve(&testHandler{})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type testHandler struct {
+	cfg struct {
+		Block bool   `json:"block"`
+		Data  string `json:"data"`
+	}
+	r io.Reader
+	w bytes.Buffer
+}
+
+func (h *testHandler) Init(kind string, cfg []byte) error {
+	err := json.Unmarshal(cfg, &h.cfg)
+	h.r = strings.NewReader(h.cfg.Data)
+	return err
+}
+
+func (h *testHandler) Start(ctx context.Context) error {
+	if h.cfg.Block {
+		<-ctx.Done()
+	}
+	return nil
+}
+
+func (h *testHandler) Result() (any, error) {
+	return map[string]any{"hello": "world"}, nil
+}
+
+func (h *testHandler) Read(p []byte) (int, error) {
+	return h.r.Read(p)
+}
+
+func (h *testHandler) Write(p []byte) (int, error) {
+	return h.w.Write(p)
+}
+
+func (h *testHandler) Close() error {
+	fmt.Fprintln(os.Stderr, "written:"+h.w.String())
+	return nil
+}
+
+func startTestPlugin(t *testing.T, kind string, cfg any, logLine func(string)) *Client {
+	c, err := Start(&Cfg{Command: []string{os.Args[0]}, Env: map[string]string{envTestPlugin: "1"}}, logLine)
+	require.NoError(t, err)
+	require.NoError(t, c.Init(kind, cfg))
+	return c
+}
+
+func TestFrame(t *testing.T) {
+	f := &Frame{ID: 1, Call: CallRead, Kind: KindReader, Data: []byte("abc"), Size: 10, EOF: true, Error: "err"}
+	ff := &Frame{}
+	require.NoError(t, ff.unmarshal(f.marshal(nil)))
+	require.Equal(t, f, ff)
+}
+
+func TestStep(t *testing.T) {
+	require.Error(t, v.Struct(&Cfg{Command: []string{}}))
+
+	c := startTestPlugin(t, KindStep, map[string]any{}, nil)
+	_, err := c.Call(context.Background(), &Frame{Call: CallStart})
+	require.NoError(t, err)
+	result, err := c.Result()
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"hello": "world"}, result)
+	_, err = c.Call(context.Background(), &Frame{Call: CallUnspecified})
+	require.ErrorContains(t, err, "unsupported call")
+	require.NoError(t, c.Close())
+
+	c = startTestPlugin(t, KindStep, map[string]any{"block": true}, nil)
+	startErr := make(chan error, 1)
+	go func() {
+		_, err := c.Call(context.Background(), &Frame{Call: CallStart})
+		startErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	_, err = c.Call(context.Background(), &Frame{Call: CallStop})
+	require.NoError(t, err)
+	require.NoError(t, <-startErr)
+	require.NoError(t, c.Close())
+}
+
+func TestReaderWriter(t *testing.T) {
+	data := strings.Repeat("0123456789", 1000)
+	r := NewReader(startTestPlugin(t, KindReader, map[string]any{"data": data}, nil))
+	bs, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, string(bs))
+	require.NoError(t, r.Close())
+
+	lines := make(chan string, 1)
+	w := NewWriter(startTestPlugin(t, KindWriter, map[string]any{}, func(line string) { lines <- line }))
+	_, err = io.Copy(w, strings.NewReader(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, "written:"+data, <-lines)
+}
@@ -0,0 +1,173 @@
+package ext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/donkeywon/golib/errs"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MaxFrameSize is the max size of a Frame.
+const MaxFrameSize = 64 * 1024 * 1024
+
+type Call int32
+
+const (
+	CallUnspecified Call = iota
+	CallInit
+	CallStart
+	CallStop
+	CallResult
+	CallRead
+	CallWrite
+	CallClose
+)
+
+func (c Call) String() string {
+	switch c {
+	case CallInit:
+		return "init"
+	case CallStart:
+		return "start"
+	case CallStop:
+		return "stop"
+	case CallResult:
+		return "result"
+	case CallRead:
+		return "read"
+	case CallWrite:
+		return "write"
+	case CallClose:
+		return "close"
+	default:
+		return "unspecified"
+	}
+}
+
+// Frame is the message in ext.proto, it is encoded by hand so that no generated code is needed.
+type Frame struct {
+	ID    uint64
+	Call  Call
+	Kind  string
+	Data  []byte
+	Size  uint64
+	EOF   bool
+	Error string
+}
+
+const (
+	fieldID protowire.Number = iota + 1
+	fieldCall
+	fieldKind
+	fieldData
+	fieldSize
+	fieldEOF
+	fieldError
+)
+
+func (f *Frame) marshal(b []byte) []byte {
+	if f.ID != 0 {
+		b = protowire.AppendTag(b, fieldID, protowire.VarintType)
+		b = protowire.AppendVarint(b, f.ID)
+	}
+	if f.Call != CallUnspecified {
+		b = protowire.AppendTag(b, fieldCall, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(f.Call))
+	}
+	if f.Kind != "" {
+		b = protowire.AppendTag(b, fieldKind, protowire.BytesType)
+		b = protowire.AppendString(b, f.Kind)
+	}
+	if len(f.Data) > 0 {
+		b = protowire.AppendTag(b, fieldData, protowire.BytesType)
+		b = protowire.AppendBytes(b, f.Data)
+	}
+	if f.Size != 0 {
+		b = protowire.AppendTag(b, fieldSize, protowire.VarintType)
+		b = protowire.AppendVarint(b, f.Size)
+	}
+	if f.EOF {
+		b = protowire.AppendTag(b, fieldEOF, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(f.EOF))
+	}
+	if f.Error != "" {
+		b = protowire.AppendTag(b, fieldError, protowire.BytesType)
+		b = protowire.AppendString(b, f.Error)
+	}
+	return b
+}
+
+func (f *Frame) unmarshal(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.VarintType && (num == fieldID || num == fieldCall || num == fieldSize || num == fieldEOF):
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			switch num {
+			case fieldID:
+				f.ID = v
+			case fieldCall:
+				f.Call = Call(v)
+			case fieldSize:
+				f.Size = v
+			case fieldEOF:
+				f.EOF = protowire.DecodeBool(v)
+			}
+		case typ == protowire.BytesType && (num == fieldKind || num == fieldData || num == fieldError):
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			switch num {
+			case fieldKind:
+				f.Kind = string(v)
+			case fieldData:
+				f.Data = v
+			case fieldError:
+				f.Error = string(v)
+			}
+		default:
+			// unknown fields are skipped for compatibility
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func writeFrame(w io.Writer, f *Frame) error {
+	msg := f.marshal(nil)
+	buf := protowire.AppendVarint(make([]byte, 0, binary.MaxVarintLen64+len(msg)), uint64(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
+
+func readFrame(r *bufio.Reader) (*Frame, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxFrameSize {
+		return nil, errs.Errorf("frame too large: %d", size)
+	}
+	buf := make([]byte, size)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, errs.Wrap(err, "read frame failed")
+	}
+	f := &Frame{}
+	err = f.unmarshal(buf)
+	if err != nil {
+		return nil, errs.Wrap(err, "unmarshal frame failed")
+	}
+	return f, nil
+}
@@ -0,0 +1,165 @@
+package ext
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/jsons"
+)
+
+// Handler is implemented by plugins written in Go, Init is called first with the kind and cfg in JSON.
+// Calls are dispatched to optional interfaces implemented by Handler:
+//   - step: Starter, Stopper and Resulter
+//   - reader: io.Reader
+//   - writer: io.Writer
+//
+// io.Closer is called on close.
+type Handler interface {
+	Init(kind string, cfg []byte) error
+}
+
+type Starter interface {
+	// Start run the step, ctx is canceled on stop or close.
+	Start(ctx context.Context) error
+}
+
+type Stopper interface {
+	Stop() error
+}
+
+type Resulter interface {
+	// Result return result of the step which is marshaled to a JSON object.
+	Result() (any, error)
+}
+
+// Serve serve h on stdin and stdout, it returns after close is called or stdin is closed.
+// Plugins must not write to stdout, log to stderr instead.
+func Serve(h Handler) error {
+	return ServeIO(os.Stdin, os.Stdout, h)
+}
+
+// ServeIO serve h on r and w.
+func ServeIO(r io.Reader, w io.Writer, h Handler) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &server{h: h, w: w, ctx: ctx, cancel: cancel}
+	defer cancel()
+
+	br := bufio.NewReader(r)
+	for {
+		f, err := readFrame(br)
+		if err != nil {
+			cancel()
+			s.wg.Wait()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if f.Call == CallClose {
+			cancel()
+			s.wg.Wait()
+			return s.respond(f, nil, s.close())
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			data, err := s.handle(f)
+			err = s.respond(f, data, err)
+			if err != nil {
+				cancel()
+			}
+		}()
+	}
+}
+
+type server struct {
+	h      Handler
+	wmu    sync.Mutex
+	w      io.Writer
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	eof    bool
+}
+
+func (s *server) handle(f *Frame) (*Frame, error) {
+	switch f.Call {
+	case CallInit:
+		return nil, s.h.Init(f.Kind, f.Data)
+	case CallStart:
+		if st, ok := s.h.(Starter); ok {
+			return nil, st.Start(s.ctx)
+		}
+	case CallStop:
+		s.cancel()
+		if st, ok := s.h.(Stopper); ok {
+			return nil, st.Stop()
+		}
+		return nil, nil
+	case CallResult:
+		if rs, ok := s.h.(Resulter); ok {
+			result, err := rs.Result()
+			if err != nil {
+				return nil, err
+			}
+			data, err := jsons.Marshal(result)
+			if err != nil {
+				return nil, errs.Wrap(err, "marshal result failed")
+			}
+			return &Frame{Data: data}, nil
+		}
+		return nil, nil
+	case CallRead:
+		if r, ok := s.h.(io.Reader); ok {
+			return s.read(r, f.Size)
+		}
+	case CallWrite:
+		if w, ok := s.h.(io.Writer); ok {
+			_, err := w.Write(f.Data)
+			return nil, err
+		}
+	}
+	return nil, errs.Errorf("unsupported call: %s", f.Call)
+}
+
+func (s *server) read(r io.Reader, size uint64) (*Frame, error) {
+	if s.eof {
+		return &Frame{EOF: true}, nil
+	}
+	buf := make([]byte, min(size, MaxFrameSize/2))
+	n, err := r.Read(buf)
+	if errors.Is(err, io.EOF) {
+		s.eof = true
+		err = nil
+	}
+	return &Frame{Data: buf[:n], EOF: s.eof}, err
+}
+
+func (s *server) close() error {
+	if c, ok := s.h.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (s *server) respond(req *Frame, resp *Frame, err error) error {
+	if resp == nil {
+		resp = &Frame{}
+	}
+	resp.ID = req.ID
+	resp.Call = req.Call
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	return writeFrame(s.w, resp)
+}
@@ -22,42 +22,44 @@ type CfgSetter[C any] interface {
 
 type Plugin any
 
-var (
-	_pluginCreators    = make(map[any]any)
-	_pluginCfgCreators = make(map[any]any)
-)
-
 // 推荐自定义plugin的类型，不要直接使用基础类型，例如
 // type DaemonType string
 // const DaemonTypeHttpd DaemonType = "httpd"
 // Reg(DaemonTypeHttpd, func() Daemon { return NewHttpd() }, func() any { return NewHttpdCfg() }).
+// 重复注册会替换之前的注册，除非Registry是strict模式.
 func Reg[P Plugin, C any](typ any, creator Creator[P], cfgCreator CfgCreator[C]) {
-	validate(typ, creator, cfgCreator)
+	RegTo(Current(), typ, creator, cfgCreator)
+}
+
+// RegTo register to r instead of the current registry.
+func RegTo[P Plugin, C any](r *Registry, typ any, creator Creator[P], cfgCreator CfgCreator[C]) {
+	pRT := validate(typ, creator)
 
-	_pluginCreators[typ] = creator
+	kind := reflect.TypeFor[P]()
+	if kind.Kind() == reflect.Interface && kind.NumMethod() == 0 {
+		kind = pRT
+	}
+	e := &Entry{Type: typ, Kind: kind.String(), Site: callerSite(), creator: creator}
 	if cfgCreator != nil {
-		_pluginCfgCreators[typ] = cfgCreator
+		e.newCfg = func() any { return cfgCreator() }
 	}
+	r.add(e)
 }
 
-func validate[P Plugin, C any](typ any, creator Creator[P], cfgCreator CfgCreator[C]) {
+func validate[P Plugin](typ any, creator Creator[P]) reflect.Type {
 	if creator == nil {
 		panic("nil plugin creator")
 	}
 	if typ == nil {
 		panic("nil plugin type")
 	}
-	// allow duplicate reg for replacing or testing
-	// _, exists := _pluginCreators[typ]
-	// if exists {
-	// 	panic("duplicate reg")
-	// }
 
 	sample := creator()
 	pRT := reflect.TypeOf(sample)
 	if pRT == nil {
 		panic(fmt.Sprintf("plugin creator returned nil: %s(%v)", reflect.TypeOf(typ).String(), typ))
 	}
+	return pRT
 }
 
 // 创建一个注册的Plugin
@@ -68,10 +70,11 @@ func validate[P Plugin, C any](typ any, creator Creator[P], cfgCreator CfgCreato
 // 2. cfg设置失败，说明plugin本身定义的有问题
 // 这两种情况下说明代码本身有问题，所以直接panic.
 func CreateWithCfg[P Plugin, C any](typ any, cfg C) P {
-	f, exists := _pluginCreators[typ]
+	e, exists := Lookup(typ)
 	if !exists {
 		panic(fmt.Sprintf("plugin not exists: %+v", typ))
 	}
+	f := e.creator
 
 	// 这里为什么不做cfg的validate校验？
 	// 校验逻辑应该放到Create之后的Init阶段，例如runner.Init
@@ -93,20 +96,19 @@ func CreateWithCfg[P Plugin, C any](typ any, cfg C) P {
 
 func CreateCfg[C any](typ any) C {
 	var emptyC C
-	f, exists := _pluginCfgCreators[typ]
-	if !exists {
+	e, exists := Lookup(typ)
+	if !exists || e.newCfg == nil {
 		return emptyC
 	}
 
-	if ff, ok := f.(CfgCreator[C]); ok {
-		return ff()
+	cfg := e.newCfg()
+	if cfg == nil {
+		return emptyC
 	}
-
-	if ff, ok := f.(CfgCreator[*C]); ok {
-		return *ff()
+	if c, ok := cfg.(C); ok {
+		return c
 	}
-
-	return f.(CfgCreator[any])().(C)
+	return *cfg.(*C)
 }
 
 func Create[P Plugin, C any](typ any) P {
@@ -0,0 +1,243 @@
+package plugin
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// EnvStrict enables strict mode of the default registry if it is true, so that duplicate registrations
+// in init functions are caught too.
+const EnvStrict = "GOLIB_PLUGIN_STRICT"
+
+const pkgPath = "github.com/donkeywon/golib/plugin"
+
+// Entry describes a registered plugin.
+type Entry struct {
+	Type     any      // registered type, e.g. pipeline.ReaderFile
+	Kind     string   // type of plugin the creator returns, e.g. pipeline.Reader
+	Site     string   // where it is registered, e.g. pipeline/file.go:13
+	Replaced []string // sites of previous registrations replaced by this one
+
+	creator any
+	newCfg  func() any
+}
+
+// NewCfg create a cfg of the plugin, nil if registered without cfg creator.
+func (e Entry) NewCfg() any {
+	if e.newCfg == nil {
+		return nil
+	}
+	return e.newCfg()
+}
+
+// Registry holds plugin creators and cfg creators by type.
+// A sub registry falls back to its parent on lookup, registering to it does not affect the parent.
+type Registry struct {
+	parent  *Registry
+	mu      sync.RWMutex
+	strict  bool
+	entries map[any]*Entry
+}
+
+var (
+	_default  = newDefaultRegistry()
+	_registry atomic.Pointer[Registry]
+	_helpers  sync.Map
+)
+
+func init() {
+	_registry.Store(_default)
+}
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.strict, _ = strconv.ParseBool(os.Getenv(EnvStrict))
+	return r
+}
+
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[any]*Entry)}
+}
+
+// Default return the registry which plugins are registered to in init functions.
+func Default() *Registry {
+	return _default
+}
+
+// Current return the registry used by Reg, Create and so on.
+func Current() *Registry {
+	return _registry.Load()
+}
+
+// Use make r the current registry, restore sets the previous one back.
+func Use(r *Registry) (restore func()) {
+	prev := _registry.Swap(r)
+	return func() { _registry.Store(prev) }
+}
+
+// Scoped make a sub registry of the current one current, it is mostly used by tests
+// to register plugins without polluting the default registry, e.g.
+//
+//	_, restore := plugin.Scoped()
+//	defer restore()
+func Scoped() (*Registry, func()) {
+	r := Current().Sub()
+	return r, Use(r)
+}
+
+// Sub create a sub registry of r with the same strict mode.
+func (r *Registry) Sub() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sub := NewRegistry()
+	sub.parent = r
+	sub.strict = r.strict
+	return sub
+}
+
+// SetStrict set whether registering a type twice in r panics.
+// Enabling it panics if r already has duplicate registrations.
+func (r *Registry) SetStrict(strict bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strict = strict
+	if !strict {
+		return
+	}
+	for _, e := range r.entries {
+		if len(e.Replaced) > 0 {
+			panic(duplicateMsg(e))
+		}
+	}
+}
+
+// Lookup return the entry of typ, registrations in r take precedence over its parents.
+func (r *Registry) Lookup(typ any) (Entry, bool) {
+	for ; r != nil; r = r.parent {
+		r.mu.RLock()
+		e, exists := r.entries[typ]
+		r.mu.RUnlock()
+		if exists {
+			return *e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// List return all entries visible in r sorted by kind and type.
+func (r *Registry) List() []Entry {
+	seen := make(map[any]struct{})
+	var l []Entry
+	for ; r != nil; r = r.parent {
+		r.mu.RLock()
+		for typ, e := range r.entries {
+			if _, exists := seen[typ]; exists {
+				continue
+			}
+			seen[typ] = struct{}{}
+			l = append(l, *e)
+		}
+		r.mu.RUnlock()
+	}
+	slices.SortFunc(l, func(a, b Entry) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(fmt.Sprint(a.Type), fmt.Sprint(b.Type)))
+	})
+	return l
+}
+
+// Kinds return sorted kinds of all entries visible in r.
+func (r *Registry) Kinds() []string {
+	var kinds []string
+	for _, e := range r.List() {
+		if !slices.Contains(kinds, e.Kind) {
+			kinds = append(kinds, e.Kind)
+		}
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+func (r *Registry) add(e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, exists := r.entries[e.Type]; exists {
+		e.Replaced = append(slices.Clone(prev.Replaced), prev.Site)
+		if r.strict {
+			panic(duplicateMsg(e))
+		}
+	}
+	r.entries[e.Type] = e
+}
+
+func duplicateMsg(e *Entry) string {
+	return fmt.Sprintf("duplicate reg %s(%v) at %s, previous at %s",
+		e.Kind, e.Type, e.Site, strings.Join(e.Replaced, ", "))
+}
+
+// List return all entries of the current registry.
+func List() []Entry {
+	return Current().List()
+}
+
+// Kinds return all kinds of the current registry.
+func Kinds() []string {
+	return Current().Kinds()
+}
+
+// Lookup return the entry of typ in the current registry.
+func Lookup(typ any) (Entry, bool) {
+	return Current().Lookup(typ)
+}
+
+// SetStrict set strict mode of the current registry.
+func SetStrict(strict bool) {
+	Current().SetStrict(strict)
+}
+
+// Helper marks the calling function as a registration helper like testing.T.Helper,
+// so the caller of it is recorded as the registration site, e.g. boot.Reg.
+func Helper() {
+	pc, _, _, ok := runtime.Caller(1)
+	if !ok {
+		return
+	}
+	if f := runtime.FuncForPC(pc); f != nil {
+		_helpers.Store(f.Name(), struct{}{})
+	}
+}
+
+// callerSite return file:line of the first caller which is not Reg or a registration helper.
+func callerSite() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !isRegFunc(f.Function) {
+			return filepath.Base(filepath.Dir(f.File)) + "/" + filepath.Base(f.File) + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isRegFunc(fn string) bool {
+	if _, ok := _helpers.Load(fn); ok {
+		return true
+	}
+	name, ok := strings.CutPrefix(fn, pkgPath+".")
+	if !ok {
+		return false
+	}
+	name, _, _ = strings.Cut(name, "[")
+	return name == "Reg" || name == "RegTo"
+}
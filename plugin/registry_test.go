@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testType string
+
+type testPlugin struct {
+	Cfg *testCfg
+}
+
+type testCfg struct {
+	Name string
+}
+
+func newTestPlugin() *testPlugin { return &testPlugin{} }
+
+func newTestCfg() *testCfg { return &testCfg{Name: "default"} }
+
+func TestScoped(t *testing.T) {
+	r, restore := Scoped()
+	Reg(testType("a"), newTestPlugin, newTestCfg)
+	Reg(testType("a"), newTestPlugin, newTestCfg)
+
+	e, exists := Lookup(testType("a"))
+	require.True(t, exists)
+	require.Equal(t, "*plugin.testPlugin", e.Kind)
+	require.Contains(t, e.Site, "plugin/registry_test.go:")
+	require.Len(t, e.Replaced, 1)
+	require.Equal(t, "default", Create[*testPlugin, *testCfg](testType("a")).Cfg.Name)
+	require.Contains(t, Kinds(), "*plugin.testPlugin")
+
+	require.Panics(t, func() { r.SetStrict(true) })
+	restore()
+
+	_, exists = Lookup(testType("a"))
+	require.False(t, exists)
+}
+
+func TestStrict(t *testing.T) {
+	r := NewRegistry()
+	r.SetStrict(true)
+	RegTo(r, testType("a"), newTestPlugin, newTestCfg)
+	require.Panics(t, func() { RegTo(r, testType("a"), newTestPlugin, newTestCfg) })
+
+	// sub registry overrides parent
+	sub := r.Sub()
+	RegTo(sub, testType("a"), newTestPlugin, func() *testCfg { return &testCfg{Name: "sub"} })
+	e, exists := sub.Lookup(testType("a"))
+	require.True(t, exists)
+	require.Equal(t, "sub", e.NewCfg().(*testCfg).Name)
+	require.Empty(t, e.Replaced)
+	require.Len(t, sub.List(), 1)
+}
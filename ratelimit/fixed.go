@@ -25,6 +25,16 @@ func NewFixedRateLimiterCfg() *FixedRateLimiterCfg {
 	return &FixedRateLimiterCfg{}
 }
 
+func (c *FixedRateLimiterCfg) Validate() error {
+	if c.N < 0 {
+		return errs.Errorf("fixed rate limiter N must ge 0: %d", c.N)
+	}
+	if c.Burst <= 0 {
+		return errs.Errorf("fixed rate limiter Burst must gt 0: %d", c.Burst)
+	}
+	return nil
+}
+
 type FixedRateLimiter struct {
 	runner.Runner
 	*FixedRateLimiterCfg
@@ -40,17 +50,28 @@ func NewFixedRateLimiter() *FixedRateLimiter {
 }
 
 func (frl *FixedRateLimiter) Init() error {
-	if frl.N < 0 {
-		return errs.Errorf("fixed rate limiter N must ge 0: %d", frl.N)
-	}
-	if frl.Burst <= 0 {
-		return errs.Errorf("fixed rate limiter Burst must gt 0: %d", frl.Burst)
+	err := frl.FixedRateLimiterCfg.Validate()
+	if err != nil {
+		return err
 	}
 	frl.rxRl = rate.NewLimiter(rate.Limit(frl.N), frl.Burst)
 	frl.txRl = rate.NewLimiter(rate.Limit(frl.N), frl.Burst)
 	return frl.Runner.Init()
 }
 
+// Reload apply new N and Burst to both rx and tx limiter, it must be called after Init.
+func (frl *FixedRateLimiter) Reload(newCfg any) error {
+	cfg := newCfg.(*FixedRateLimiterCfg)
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+	frl.SetRxLimit(cfg.N, cfg.Burst)
+	frl.SetTxLimit(cfg.N, cfg.Burst)
+	frl.FixedRateLimiterCfg = cfg
+	return nil
+}
+
 func (frl *FixedRateLimiter) waitN(ctx context.Context, n int, timeout time.Duration, rl *rate.Limiter) error {
 	if n == 0 {
 		return nil
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/runner"
+)
+
+func init() {
+	plugin.Reg(TypeShared, func() RxTxRateLimiter { return NewSharedRateLimiter() }, func() any { return NewSharedRateLimiterCfg() })
+}
+
+const TypeShared Type = "shared"
+
+var ErrSharedNotExists = errors.New("shared rate limiter not exists")
+
+var (
+	_sharedMu sync.RWMutex
+	_shared   = make(map[string]RxTxRateLimiter)
+)
+
+// SetShared register rl as name, rate limiters of type shared with the name wait on it,
+// e.g. a limit of all tasks which is reloaded by taskd.
+func SetShared(name string, rl RxTxRateLimiter) {
+	_sharedMu.Lock()
+	defer _sharedMu.Unlock()
+	_shared[name] = rl
+}
+
+func GetShared(name string) (RxTxRateLimiter, bool) {
+	_sharedMu.RLock()
+	defer _sharedMu.RUnlock()
+	rl, exists := _shared[name]
+	return rl, exists
+}
+
+type SharedRateLimiterCfg struct {
+	Name string
+}
+
+func NewSharedRateLimiterCfg() *SharedRateLimiterCfg {
+	return &SharedRateLimiterCfg{}
+}
+
+// SharedRateLimiter wait on the rate limiter registered by SetShared, it is not stopped with SharedRateLimiter.
+type SharedRateLimiter struct {
+	runner.Runner
+	Cfg *SharedRateLimiterCfg
+	rl  RxTxRateLimiter
+}
+
+func NewSharedRateLimiter() *SharedRateLimiter {
+	return &SharedRateLimiter{
+		Runner: runner.Create("sharedRateLimiter"),
+		Cfg:    NewSharedRateLimiterCfg(),
+	}
+}
+
+func (srl *SharedRateLimiter) Init() error {
+	rl, exists := GetShared(srl.Cfg.Name)
+	if !exists {
+		return errs.Wrapf(ErrSharedNotExists, "name %s", srl.Cfg.Name)
+	}
+	srl.rl = rl
+	return srl.Runner.Init()
+}
+
+func (srl *SharedRateLimiter) RxWaitN(ctx context.Context, n int, timeout time.Duration) error {
+	return srl.rl.RxWaitN(ctx, n, timeout)
+}
+
+func (srl *SharedRateLimiter) TxWaitN(ctx context.Context, n int, timeout time.Duration) error {
+	return srl.rl.TxWaitN(ctx, n, timeout)
+}
@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/donkeywon/golib/util/jsons"
+)
+
+// Phase is the lifecycle phase in which an error occurred.
+type Phase string
+
+const (
+	PhaseInit  Phase = "init"
+	PhaseStart Phase = "start"
+	PhaseStop  Phase = "stop"
+	PhasePanic Phase = "panic"
+)
+
+// Error is an error tagged with phase, the runner it was appended to,
+// and the runner it originally came from.
+type Error struct {
+	Phase  Phase
+	Runner string
+	Origin string
+	Err    error
+}
+
+type errorView struct {
+	Phase  Phase  `json:"phase"  yaml:"phase"`
+	Runner string `json:"runner" yaml:"runner"`
+	Origin string `json:"origin" yaml:"origin"`
+	Msg    string `json:"msg"    yaml:"msg"`
+}
+
+// WithPhase tag err with phase, the runner is filled in by AppendError.
+// If err is nil, WithPhase returns nil.
+func WithPhase(phase Phase, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Phase: phase, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Runner + " " + string(e.Phase) + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) view() *errorView {
+	return &errorView{
+		Phase:  e.Phase,
+		Runner: e.Runner,
+		Origin: e.Origin,
+		Msg:    e.Err.Error(),
+	}
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return jsons.Marshal(e.view())
+}
+
+func (e *Error) MarshalYAML() (any, error) {
+	return e.view(), nil
+}
+
+// MultiError is the result of Runner.Err.
+type MultiError struct {
+	errs []*Error
+}
+
+func (m *MultiError) Error() string {
+	var sb strings.Builder
+	for i, e := range m.errs {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(e.Error())
+	}
+	return sb.String()
+}
+
+func (m *MultiError) Unwrap() []error {
+	errs := make([]error, len(m.errs))
+	for i, e := range m.errs {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Errors return all errors in order of occurrence.
+func (m *MultiError) Errors() []*Error {
+	return m.errs
+}
+
+// ByPhase return errors occurred in phase.
+func (m *MultiError) ByPhase(phase Phase) []*Error {
+	var errs []*Error
+	for _, e := range m.errs {
+		if e.Phase == phase {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+func (m *MultiError) MarshalJSON() ([]byte, error) {
+	return jsons.Marshal(m.errs)
+}
+
+func (m *MultiError) MarshalYAML() (any, error) {
+	return m.errs, nil
+}
+
+// Errors extract tagged errors from err, mostly the result of Runner.Err.
+func Errors(err error) []*Error {
+	if err == nil {
+		return nil
+	}
+	var m *MultiError
+	if errors.As(err, &m) {
+		return m.errs
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return []*Error{e}
+	}
+	return []*Error{{Err: err}}
+}
+
+func phaseOf(s State) Phase {
+	switch {
+	case s < StateRunning:
+		return PhaseInit
+	case s == StateRunning:
+		return PhaseStart
+	default:
+		return PhaseStop
+	}
+}
+
+// tagError tag err with runner name, phase of err is kept if it is tagged, e.g. by WithPhase or a child,
+// otherwise phase is derived from state.
+func tagError(err error, name string, s State) *Error {
+	e, ok := err.(*Error)
+	if !ok || e.Runner != "" {
+		phase := phaseOf(s)
+		var inner *Error
+		if errors.As(err, &inner) && inner.Phase != "" {
+			phase = inner.Phase
+		}
+		e = &Error{Phase: phase, Err: err}
+	}
+	e.Runner = name
+
+	var inner *Error
+	if errors.As(e.Err, &inner) && inner.Origin != "" {
+		e.Origin = inner.Origin
+	} else {
+		e.Origin = name
+	}
+	return e
+}
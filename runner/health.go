@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"context"
+	"errors"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+// HealthChecker is an optional interface of Runner to report whether it is healthy.
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+// Health is the aggregated health of a Runner and its descendants,
+// a Runner is healthy only if its own check and all descendants' checks pass.
+// A Runner with HealthChecker is also unhealthy if it is not running, e.g. not started yet or failed.
+type Health struct {
+	Name     string    `json:"name"               yaml:"name"`
+	Healthy  bool      `json:"healthy"            yaml:"healthy"`
+	Err      string    `json:"err,omitempty"      yaml:"err,omitempty"`
+	Children []*Health `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// CheckHealth run checks of all HealthChecker in the runner tree rooted at r.
+// Runners without HealthChecker in its subtree are omitted unless it is r itself.
+func CheckHealth(ctx context.Context, r Runner) *Health {
+	h, _ := checkHealth(ctx, r)
+	if h == nil {
+		h = &Health{Name: r.Name(), Healthy: true}
+	}
+	return h
+}
+
+func checkHealth(ctx context.Context, r Runner) (*Health, bool) {
+	h := &Health{Name: r.Name(), Healthy: true}
+
+	checked := false
+	if hc, ok := r.(HealthChecker); ok {
+		checked = true
+		var err error
+		if s := r.State(); s != StateRunning {
+			err = errs.Errorf("%s is %s", r.Name(), s)
+			if s.Terminal() && r.Err() != nil {
+				err = errors.Join(err, r.Err())
+			}
+		} else {
+			err = safeCheck(ctx, hc)
+		}
+		if err != nil {
+			h.Healthy = false
+			h.Err = err.Error()
+		}
+	}
+
+	for _, c := range r.Children() {
+		if c == nil {
+			continue
+		}
+		ch, ok := checkHealth(ctx, c)
+		if !ok {
+			continue
+		}
+		checked = true
+		h.Healthy = h.Healthy && ch.Healthy
+		h.Children = append(h.Children, ch)
+	}
+
+	if !checked {
+		return nil, false
+	}
+	return h, true
+}
+
+func safeCheck(ctx context.Context, hc HealthChecker) (err error) {
+	defer func() {
+		p := recover()
+		if p != nil {
+			err = errs.PanicToErrWithMsg(p, "panic on health check")
+		}
+	}()
+	return hc.Check(ctx)
+}
+
+// Alive report whether r is not stopping or done.
+func Alive(r Runner) bool {
+	return r.State() < StateStopping
+}
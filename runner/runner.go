@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/kvs"
@@ -30,16 +33,23 @@ type Runner interface {
 
 	Inherit(Runner)
 	Parent() Runner
+	Children() []Runner
+	CreatedAt() time.Time
+	LoggerFields() []any
 
 	MarkStarted() bool
 	MarkStopping() bool
 	MarkStopDone() bool
 	MarkDone() bool
+	MarkFailed(err error) bool
 	Started() <-chan struct{}
 	Stopping() <-chan struct{}
 	StopDone() <-chan struct{}
 	Done() <-chan struct{}
 
+	State() State
+	Subscribe() (<-chan StateEvent, func())
+
 	AppendError(err ...error)
 	Err() error
 
@@ -58,16 +68,25 @@ func Init(r Runner) (err error) {
 	defer func() {
 		p := recover()
 		if p != nil {
+			perr := errs.PanicToErrWithMsg(p, fmt.Sprintf("panic on %s init", r.Name()))
+			r.AppendError(WithPhase(PhasePanic, perr))
 			if err == nil {
-				err = errs.PanicToErrWithMsg(p, fmt.Sprintf("panic on %s init", r.Name()))
+				err = perr
 			} else {
-				err = errors.Join(err, errs.PanicToErrWithMsg(p, fmt.Sprintf("panic on %s init", r.Name())))
+				err = errors.Join(err, perr)
 			}
+			r.MarkFailed(err)
+			r.Cancel()
 		}
 	}()
+	if br := baseOf(r.Parent()); br != nil {
+		br.addChild(r)
+	}
 	r.Info("init")
 	err = r.Init()
 	if err != nil {
+		r.AppendError(WithPhase(PhaseInit, err))
+		r.MarkFailed(err)
 		r.Cancel()
 		return
 	}
@@ -107,7 +126,7 @@ func run(r Runner) {
 	defer func() {
 		err := recover()
 		if err != nil {
-			r.AppendError(errs.PanicToErrWithMsg(err, fmt.Sprintf("panic on %s running", r.Name())))
+			r.AppendError(WithPhase(PhasePanic, errs.PanicToErrWithMsg(err, fmt.Sprintf("panic on %s running", r.Name()))))
 		}
 
 		if r.MarkStopping() {
@@ -153,7 +172,7 @@ func run(r Runner) {
 	}()
 
 	r.Info("starting")
-	r.AppendError(r.Start())
+	r.AppendError(WithPhase(PhaseStart, r.Start()))
 }
 
 // Stop runner, in most scenario, Stop is notification action to notify the Runner to stop.
@@ -166,6 +185,76 @@ func StopAndWait(r Runner) {
 	stop(r, true)
 }
 
+var ErrStopTimeout = errors.New("stop timeout")
+
+// StopTimeoutError is returned by StopWithTimeout, Stuck contains the path of
+// runners in the tree which are still not done.
+type StopTimeoutError struct {
+	Name  string
+	Grace time.Duration
+	Stuck []string
+}
+
+func (e *StopTimeoutError) Error() string {
+	return fmt.Sprintf("stop %s timeout after %s, stuck: %s", e.Name, e.Grace, strings.Join(e.Stuck, ","))
+}
+
+func (e *StopTimeoutError) Is(target error) bool {
+	return target == ErrStopTimeout
+}
+
+// StopWithTimeout notify Runner to stop and wait it done up to grace,
+// then cancel its context and wait up to grace again.
+// A *StopTimeoutError is returned if Runner is still not done.
+// If grace <= 0, it behaves like StopAndWait.
+func StopWithTimeout(r Runner, grace time.Duration) error {
+	if grace <= 0 {
+		StopAndWait(r)
+		return nil
+	}
+
+	go Stop(r)
+
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case <-r.Done():
+		return nil
+	case <-t.C:
+	}
+
+	r.Warn("stop timeout, cancel", "grace", grace)
+	r.Cancel()
+
+	t.Reset(grace)
+	select {
+	case <-r.Done():
+		return nil
+	case <-t.C:
+	}
+
+	return &StopTimeoutError{
+		Name:  r.Name(),
+		Grace: grace,
+		Stuck: stuckRunners(r),
+	}
+}
+
+func stuckRunners(r Runner) []string {
+	var (
+		stuck []string
+		path  []string
+	)
+	Walk(r, func(r Runner, depth int) bool {
+		path = append(path[:depth], r.Name())
+		if !r.State().Terminal() {
+			stuck = append(stuck, strings.Join(path, "."))
+		}
+		return true
+	})
+	return stuck
+}
+
 func stop(r Runner, wait bool) {
 	if !r.MarkStopping() {
 		r.Info("already stopping", "wait", wait)
@@ -200,10 +289,10 @@ func safeStop(r Runner) {
 	defer func() {
 		err := recover()
 		if err != nil {
-			r.AppendError(errs.PanicToErrWithMsg(err, "panic on stopping"))
+			r.AppendError(WithPhase(PhasePanic, errs.PanicToErrWithMsg(err, "panic on stopping")))
 		}
 	}()
-	r.AppendError(r.Stop())
+	r.AppendError(WithPhase(PhaseStop, r.Stop()))
 }
 
 type baseRunner struct {
@@ -213,7 +302,7 @@ type baseRunner struct {
 	initialized  atomic.Bool
 	ctx          context.Context
 	cancel       context.CancelFunc
-	err          error
+	errs         []*Error
 	parent       Runner
 	started      chan struct{}
 	done         chan struct{}
@@ -226,17 +315,27 @@ type baseRunner struct {
 	stoppingOnce sync.Once
 	cancelOnce   sync.Once
 	errMu        sync.Mutex
+
+	stateMu sync.RWMutex
+	state   State
+	subs    map[chan StateEvent]struct{}
+
+	createdAt    time.Time
+	treeMu       sync.Mutex
+	children     []Runner
+	loggerFields []any
 }
 
 func newBase(name string) Runner {
 	br := &baseRunner{
-		Logger:   log.NewNopLogger(),
-		name:     name,
-		started:  make(chan struct{}),
-		stopping: make(chan struct{}),
-		stopDone: make(chan struct{}),
-		done:     make(chan struct{}),
-		NoErrKVS: kvs.NewMapKVS(),
+		Logger:    log.NewNopLogger(),
+		name:      name,
+		started:   make(chan struct{}),
+		stopping:  make(chan struct{}),
+		stopDone:  make(chan struct{}),
+		done:      make(chan struct{}),
+		NoErrKVS:  kvs.NewMapKVS(),
+		createdAt: time.Now(),
 	}
 	return br
 }
@@ -260,6 +359,7 @@ func (br *baseRunner) Init() error {
 	if !br.markInitialized() {
 		panic("init twice")
 	}
+	defer br.transit(StateInitialized, nil)
 	if br.Logger == nil {
 		br.Logger = log.NewNopLogger()
 	}
@@ -308,6 +408,86 @@ func (br *baseRunner) Parent() Runner {
 	return br.parent
 }
 
+// Children return live children which are initialized by runner.Init after Inherit,
+// children reached terminal state are removed.
+func (br *baseRunner) Children() []Runner {
+	br.treeMu.Lock()
+	defer br.treeMu.Unlock()
+	br.pruneChildren()
+	return append([]Runner(nil), br.children...)
+}
+
+func (br *baseRunner) addChild(r Runner) {
+	br.treeMu.Lock()
+	defer br.treeMu.Unlock()
+	br.pruneChildren()
+	br.children = append(br.children, r)
+}
+
+// baseOf return the baseRunner of r, which is r itself or embedded in r directly or through embedded Runners.
+// nil is returned if r is not created by newBase, e.g. Create is overridden.
+func baseOf(r Runner) *baseRunner {
+	if r == nil {
+		return nil
+	}
+	if br, ok := r.(*baseRunner); ok {
+		return br
+	}
+	v := reflect.ValueOf(r)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if !f.Anonymous || !f.IsExported() {
+			continue
+		}
+		fr, ok := v.Field(i).Interface().(Runner)
+		if !ok {
+			continue
+		}
+		if br := baseOf(fr); br != nil {
+			return br
+		}
+	}
+	return nil
+}
+
+func (br *baseRunner) pruneChildren() {
+	n := 0
+	for _, c := range br.children {
+		if !c.State().Terminal() {
+			br.children[n] = c
+			n++
+		}
+	}
+	clear(br.children[n:])
+	br.children = br.children[:n]
+}
+
+func (br *baseRunner) CreatedAt() time.Time {
+	return br.createdAt
+}
+
+func (br *baseRunner) LoggerFields() []any {
+	br.treeMu.Lock()
+	defer br.treeMu.Unlock()
+	return append([]any(nil), br.loggerFields...)
+}
+
+func (br *baseRunner) WithLoggerFields(kvs ...any) {
+	br.treeMu.Lock()
+	br.loggerFields = append(br.loggerFields, kvs...)
+	br.treeMu.Unlock()
+	br.Logger.WithLoggerFields(kvs...)
+}
+
 func (br *baseRunner) SetCtx(ctx context.Context) {
 	if br.initialized.Load() {
 		panic("set context after initialized")
@@ -358,6 +538,7 @@ func (br *baseRunner) WithLoggerFrom(r Runner, kvs ...any) {
 func (br *baseRunner) MarkStarted() bool {
 	marked := false
 	br.startedOnce.Do(func() {
+		br.transit(StateRunning, nil)
 		close(br.started)
 		marked = true
 	})
@@ -368,6 +549,7 @@ func (br *baseRunner) MarkStarted() bool {
 func (br *baseRunner) MarkStopping() bool {
 	marked := false
 	br.stoppingOnce.Do(func() {
+		br.transit(StateStopping, nil)
 		close(br.stopping)
 		marked = true
 	})
@@ -378,6 +560,7 @@ func (br *baseRunner) MarkStopping() bool {
 func (br *baseRunner) MarkStopDone() bool {
 	marked := false
 	br.stopDoneOnce.Do(func() {
+		br.transit(StateStopped, nil)
 		close(br.stopDone)
 		marked = true
 	})
@@ -388,6 +571,12 @@ func (br *baseRunner) MarkStopDone() bool {
 func (br *baseRunner) MarkDone() bool {
 	marked := false
 	br.doneOnce.Do(func() {
+		err := br.Err()
+		if err != nil {
+			br.transit(StateFailed, err)
+		} else {
+			br.transit(StateDone, nil)
+		}
 		close(br.done)
 		marked = true
 	})
@@ -395,24 +584,105 @@ func (br *baseRunner) MarkDone() bool {
 	return marked
 }
 
+// MarkFailed mark runner failed, mostly used when init failed.
+func (br *baseRunner) MarkFailed(err error) bool {
+	marked := br.transit(StateFailed, err)
+	br.Debug("mark failed", "marked", marked)
+	return marked
+}
+
+func (br *baseRunner) State() State {
+	br.stateMu.RLock()
+	defer br.stateMu.RUnlock()
+	return br.state
+}
+
+// Subscribe state change events, the channel will be closed after runner reached terminal state
+// or the returned unsubscribe func is called.
+// Events are dropped if subscriber does not receive in time, but the buffer is large enough to
+// hold all transitions of a runner.
+func (br *baseRunner) Subscribe() (<-chan StateEvent, func()) {
+	ch := make(chan StateEvent, stateEventBufSize)
+
+	br.stateMu.Lock()
+	defer br.stateMu.Unlock()
+	if br.state.Terminal() {
+		close(ch)
+		return ch, func() {}
+	}
+	if br.subs == nil {
+		br.subs = make(map[chan StateEvent]struct{})
+	}
+	br.subs[ch] = struct{}{}
+
+	return ch, func() {
+		br.stateMu.Lock()
+		defer br.stateMu.Unlock()
+		if _, exists := br.subs[ch]; exists {
+			delete(br.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (br *baseRunner) transit(to State, err error) bool {
+	br.stateMu.Lock()
+	defer br.stateMu.Unlock()
+
+	from := br.state
+	if from.Terminal() || to <= from {
+		return false
+	}
+	br.state = to
+
+	e := StateEvent{
+		Name: br.name,
+		From: from,
+		To:   to,
+		Time: time.Now(),
+		Err:  err,
+	}
+	for ch := range br.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+		if to.Terminal() {
+			close(ch)
+		}
+	}
+	if to.Terminal() {
+		br.subs = nil
+	}
+	return true
+}
+
+// AppendError append errors tagged with phase and runner name,
+// phase is derived from current state if err is not tagged, errors of a *MultiError are appended one by one.
 func (br *baseRunner) AppendError(err ...error) {
+	st := br.State()
 	br.errMu.Lock()
 	defer br.errMu.Unlock()
-	var res []error
-	switch et := br.err.(type) {
-	case interface{ UnWrap() []error }:
-		res = make([]error, 0, len(et.UnWrap())+len(err))
-		res = append(res, et.UnWrap()...)
-	default:
-		res = make([]error, 0, 1+len(err))
-		res = append(res, br.err)
+	for _, e := range err {
+		if e == nil {
+			continue
+		}
+		if m, ok := e.(*MultiError); ok {
+			for _, me := range m.errs {
+				br.errs = append(br.errs, tagError(me, br.name, st))
+			}
+			continue
+		}
+		br.errs = append(br.errs, tagError(e, br.name, st))
 	}
-	res = append(res, err...)
-	br.err = errors.Join(res...)
 }
 
+// Err return a *MultiError if any error appended, otherwise nil.
 func (br *baseRunner) Err() error {
 	br.errMu.Lock()
 	defer br.errMu.Unlock()
-	return br.err
+	if len(br.errs) == 0 {
+		return nil
+	}
+	return &MultiError{errs: append([]*Error(nil), br.errs...)}
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/stretchr/testify/require"
 )
 
 type runA struct {
@@ -120,3 +122,193 @@ func TestStopBeforeStart(_ *testing.T) {
 	Start(ra)
 	<-ra.Done()
 }
+
+func TestStateEvents(t *testing.T) {
+	r := newBase("state")
+	r.SetCtx(t.Context())
+	require.Equal(t, StateCreated, r.State())
+
+	ch, _ := r.Subscribe()
+	require.NoError(t, Init(r))
+	Start(r)
+	<-r.Started()
+	StopAndWait(r)
+
+	var states []State
+	for e := range ch {
+		states = append(states, e.To)
+	}
+	require.Equal(t, []State{StateInitialized, StateRunning, StateStopping, StateStopped, StateDone}, states)
+	require.Equal(t, StateDone, r.State())
+
+	_, unsubscribe := r.Subscribe()
+	unsubscribe()
+}
+
+type failInit struct {
+	Runner
+}
+
+func (f *failInit) Init() error {
+	return errs.New("init failed")
+}
+
+func TestStateFailed(t *testing.T) {
+	r := &failInit{Runner: newBase("failInit")}
+	r.SetCtx(t.Context())
+	ch, _ := r.Subscribe()
+	require.Error(t, Init(r))
+	e := <-ch
+	require.Equal(t, StateFailed, e.To)
+	require.Error(t, e.Err)
+	_, ok := <-ch
+	require.False(t, ok)
+}
+
+func TestDump(t *testing.T) {
+	root := newBase("root")
+	root.SetCtx(t.Context())
+	require.NoError(t, Init(root))
+
+	child := newBase("child")
+	child.Inherit(root)
+	child.WithLoggerFields("k", "v")
+	require.NoError(t, Init(child))
+	child.Store("x", 1)
+
+	n := Dump(root)
+	require.Len(t, n.Children, 1)
+	require.Equal(t, "child", n.Children[0].Name)
+	require.Equal(t, StateInitialized, n.Children[0].State)
+	require.Equal(t, "v", n.Children[0].Fields["k"])
+	require.Equal(t, 1, n.Children[0].Values["x"])
+
+	Start(child)
+	StopAndWait(child)
+	require.Empty(t, root.Children())
+
+	// parent embeds its runner through another Runner
+	parent := &stuck{Runner: &stuck{Runner: newBase("parent")}}
+	parent.SetCtx(t.Context())
+	child = newBase("child")
+	child.Inherit(parent)
+	require.NoError(t, Init(child))
+	require.Len(t, parent.Children(), 1)
+}
+
+type stuck struct {
+	Runner
+	ignoreCtx bool
+}
+
+func (s *stuck) Start() error {
+	if s.ignoreCtx {
+		select {}
+	}
+	<-s.Ctx().Done()
+	return nil
+}
+
+func TestStopWithTimeout(t *testing.T) {
+	r := &stuck{Runner: newBase("stuck")}
+	r.SetCtx(t.Context())
+	require.NoError(t, Init(r))
+	Start(r)
+	<-r.Started()
+	require.NoError(t, StopWithTimeout(r, 10*time.Millisecond))
+
+	r = &stuck{Runner: newBase("stuck"), ignoreCtx: true}
+	r.SetCtx(t.Context())
+	require.NoError(t, Init(r))
+	Start(r)
+	<-r.Started()
+	err := StopWithTimeout(r, 10*time.Millisecond)
+	require.ErrorIs(t, err, ErrStopTimeout)
+	require.Equal(t, []string{"stuck"}, err.(*StopTimeoutError).Stuck)
+}
+
+type failStartStop struct {
+	Runner
+}
+
+func (f *failStartStop) Start() error {
+	<-f.Stopping()
+	return errs.New("start failed")
+}
+
+func (f *failStartStop) Stop() error {
+	return errs.New("stop failed")
+}
+
+func TestPhaseErrors(t *testing.T) {
+	r := &failStartStop{Runner: newBase("fss")}
+	r.SetCtx(t.Context())
+	require.NoError(t, Init(r))
+	Start(r)
+	<-r.Started()
+	StopAndWait(r)
+
+	var me *MultiError
+	require.ErrorAs(t, r.Err(), &me)
+	require.Len(t, me.ByPhase(PhaseStart), 1)
+	require.Len(t, me.ByPhase(PhaseStop), 1)
+	require.Equal(t, "fss", me.ByPhase(PhaseStop)[0].Origin)
+	require.Equal(t, StateFailed, r.State())
+
+	bs, err := jsons.Marshal(Errors(r.Err()))
+	require.NoError(t, err)
+	require.Contains(t, string(bs), `"phase":"start"`)
+
+	// phases of child errors are kept in parent
+	parent := newBase("parent")
+	parent.SetCtx(t.Context())
+	require.NoError(t, Init(parent))
+	Start(parent)
+	<-parent.Started()
+	parent.AppendError(r.Err())
+	require.ErrorAs(t, parent.Err(), &me)
+	require.Len(t, me.ByPhase(PhaseStop), 1)
+	require.Equal(t, "parent", me.ByPhase(PhaseStop)[0].Runner)
+	require.Equal(t, "fss", me.ByPhase(PhaseStop)[0].Origin)
+	StopAndWait(parent)
+}
+
+type unhealthy struct {
+	Runner
+}
+
+func (u *unhealthy) Check(context.Context) error {
+	return errs.New("unhealthy")
+}
+
+func TestCheckHealth(t *testing.T) {
+	root := newBase("root")
+	root.SetCtx(t.Context())
+	require.NoError(t, Init(root))
+	require.True(t, CheckHealth(t.Context(), root).Healthy)
+
+	plain := newBase("plain")
+	plain.Inherit(root)
+	require.NoError(t, Init(plain))
+	u := &unhealthy{Runner: newBase("unhealthy")}
+	u.Inherit(plain)
+	require.NoError(t, Init(u))
+
+	h := CheckHealth(t.Context(), root)
+	require.False(t, h.Healthy)
+	require.Len(t, h.Children, 1)
+	require.Equal(t, "unhealthy is initialized", h.Children[0].Children[0].Err)
+
+	// checked only when running
+	go Start(u)
+	<-u.Started()
+	h = CheckHealth(t.Context(), root)
+	require.Equal(t, "unhealthy", h.Children[0].Children[0].Err)
+
+	// failed checker is pruned like other finished children
+	u.AppendError(errs.New("boom"))
+	Stop(u)
+	<-u.Done()
+	require.Equal(t, StateFailed, u.State())
+	require.True(t, CheckHealth(t.Context(), root).Healthy)
+}
@@ -0,0 +1,53 @@
+package runner
+
+import "time"
+
+const stateEventBufSize = 8
+
+// State is the lifecycle state of a Runner, it only moves forward.
+type State uint8
+
+const (
+	StateCreated State = iota
+	StateInitialized
+	StateRunning
+	StateStopping
+	StateStopped
+	StateDone
+	StateFailed
+)
+
+var stateNames = [...]string{
+	StateCreated:     "created",
+	StateInitialized: "initialized",
+	StateRunning:     "running",
+	StateStopping:    "stopping",
+	StateStopped:     "stopped",
+	StateDone:        "done",
+	StateFailed:      "failed",
+}
+
+func (s State) String() string {
+	if int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return "unknown"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Terminal report whether no more transition will happen.
+func (s State) Terminal() bool {
+	return s == StateDone || s == StateFailed
+}
+
+// StateEvent is emitted on every state transition of a Runner.
+type StateEvent struct {
+	Name string    `json:"name" yaml:"name"`
+	From State     `json:"from" yaml:"from"`
+	To   State     `json:"to"   yaml:"to"`
+	Time time.Time `json:"time" yaml:"time"`
+	Err  error     `json:"-"    yaml:"-"`
+}
@@ -0,0 +1,353 @@
+package runner
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+type Strategy string
+
+const (
+	// OneForOne only restart the exited child.
+	OneForOne Strategy = "oneForOne"
+	// OneForAll stop all other children and restart all of them.
+	OneForAll Strategy = "oneForAll"
+	// RestForOne stop the children added after the exited child and restart them together with it.
+	RestForOne Strategy = "restForOne"
+)
+
+type RestartPolicy string
+
+const (
+	// Permanent child is always restarted.
+	Permanent RestartPolicy = "permanent"
+	// Transient child is restarted only when it exits with error.
+	Transient RestartPolicy = "transient"
+	// Temporary child is never restarted.
+	Temporary RestartPolicy = "temporary"
+)
+
+const (
+	DefaultStrategy    = OneForOne
+	DefaultMaxRestarts = 5
+	DefaultPeriod      = time.Minute
+	DefaultMinBackoff  = 100 * time.Millisecond
+	DefaultMaxBackoff  = 30 * time.Second
+)
+
+var ErrMaxRestartsExceeded = errors.New("max restarts exceeded")
+
+type SupervisorCfg struct {
+	Strategy    Strategy      `json:"strategy"    yaml:"strategy"`
+	MaxRestarts int           `json:"maxRestarts" yaml:"maxRestarts"`
+	Period      time.Duration `json:"period"      yaml:"period"`
+	MinBackoff  time.Duration `json:"minBackoff"  yaml:"minBackoff"`
+	MaxBackoff  time.Duration `json:"maxBackoff"  yaml:"maxBackoff"`
+}
+
+func NewSupervisorCfg() *SupervisorCfg {
+	return &SupervisorCfg{
+		Strategy:    DefaultStrategy,
+		MaxRestarts: DefaultMaxRestarts,
+		Period:      DefaultPeriod,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+	}
+}
+
+// ChildSpec describe how to create a child of Supervisor.
+// Creator is called on every (re)start, a Runner can not be started twice.
+type ChildSpec struct {
+	Name    string
+	Restart RestartPolicy
+	Creator func() Runner
+}
+
+type child struct {
+	spec    *ChildSpec
+	r       Runner
+	gen     int
+	running bool
+}
+
+type childExit struct {
+	idx int
+	gen int
+	err error
+}
+
+// Supervisor owns child runners and restarts them according to Strategy.
+// If children restart more than MaxRestarts times within Period, Supervisor
+// stops all children and exits with ErrMaxRestartsExceeded.
+type Supervisor struct {
+	Runner
+	*SupervisorCfg
+
+	mu       sync.RWMutex
+	children []*child
+	restarts []time.Time
+	exitCh   chan childExit
+}
+
+func NewSupervisor(name string, cfg *SupervisorCfg) *Supervisor {
+	if cfg == nil {
+		cfg = NewSupervisorCfg()
+	}
+	return &Supervisor{
+		Runner:        Create(name),
+		SupervisorCfg: cfg,
+		exitCh:        make(chan childExit),
+	}
+}
+
+// Add children, must be called before Init.
+func (s *Supervisor) Add(spec ...*ChildSpec) {
+	for _, sp := range spec {
+		if sp == nil || sp.Creator == nil {
+			panic("nil child spec creator")
+		}
+		if sp.Restart == "" {
+			sp.Restart = Permanent
+		}
+		s.children = append(s.children, &child{spec: sp})
+	}
+}
+
+// Children return current running instance of children.
+func (s *Supervisor) Children() []Runner {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rs := make([]Runner, len(s.children))
+	for i, c := range s.children {
+		rs[i] = c.r
+	}
+	return rs
+}
+
+func (s *Supervisor) Init() error {
+	if s.MaxRestarts < 0 {
+		return errs.Errorf("supervisor max restarts must ge 0: %d", s.MaxRestarts)
+	}
+	switch s.Strategy {
+	case OneForOne, OneForAll, RestForOne:
+	case "":
+		s.Strategy = DefaultStrategy
+	default:
+		return errs.Errorf("unknown supervisor strategy: %s", s.Strategy)
+	}
+
+	for i := range s.children {
+		err := s.createChild(i)
+		if err != nil {
+			return errs.Wrapf(err, "init child(%d) %s failed", i, s.children[i].spec.Name)
+		}
+	}
+	return s.Runner.Init()
+}
+
+func (s *Supervisor) Start() error {
+	for i := range s.children {
+		s.startChild(i)
+	}
+
+	for {
+		select {
+		case <-s.Stopping():
+			s.stopChildren(0)
+			return nil
+		case e := <-s.exitCh:
+			c := s.children[e.idx]
+			if e.gen != c.gen {
+				// exit of a child stopped by supervisor itself
+				continue
+			}
+			c.running = false
+
+			err := s.onChildExit(e.idx, e.err)
+			if err != nil {
+				s.stopChildren(0)
+				return err
+			}
+			if !s.anyRunning() {
+				select {
+				case <-s.Stopping():
+					continue
+				default:
+				}
+				s.Info("all children done")
+				return nil
+			}
+		}
+	}
+}
+
+func (s *Supervisor) Stop() error {
+	return nil
+}
+
+func (s *Supervisor) createChild(idx int) (err error) {
+	defer func() {
+		p := recover()
+		if p != nil {
+			err = errs.PanicToErrWithMsg(p, "panic on create child")
+		}
+	}()
+
+	c := s.children[idx]
+	r := c.spec.Creator()
+	if r == nil {
+		return errs.New("child creator returned nil")
+	}
+	if c.spec.Name != "" && r.Name() != c.spec.Name {
+		r.SetName(c.spec.Name)
+	}
+	r.Inherit(s)
+
+	s.mu.Lock()
+	c.r = r
+	c.gen++
+	s.mu.Unlock()
+
+	return Init(r)
+}
+
+func (s *Supervisor) startChild(idx int) {
+	c := s.children[idx]
+	c.running = true
+	r, gen := c.r, c.gen
+	Start(r)
+	go func() {
+		<-r.Done()
+		select {
+		case s.exitCh <- childExit{idx: idx, gen: gen, err: r.Err()}:
+		case <-s.Done():
+		}
+	}()
+}
+
+// stopChildren stop running children from idx to the end in reverse order.
+func (s *Supervisor) stopChildren(idx int) {
+	for i := len(s.children) - 1; i >= idx; i-- {
+		c := s.children[i]
+		if !c.running {
+			continue
+		}
+		c.running = false
+		// bump gen so that the exit event of this instance will be ignored
+		s.mu.Lock()
+		c.gen++
+		s.mu.Unlock()
+		StopAndWait(c.r)
+	}
+}
+
+func (s *Supervisor) anyRunning() bool {
+	for _, c := range s.children {
+		if c.running {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Supervisor) shouldRestart(c *child, err error) bool {
+	switch c.spec.Restart {
+	case Temporary:
+		return false
+	case Transient:
+		return err != nil
+	default:
+		return true
+	}
+}
+
+func (s *Supervisor) onChildExit(idx int, err error) error {
+	for {
+		c := s.children[idx]
+		if !s.shouldRestart(c, err) {
+			s.Info("child exited, no restart", "child", c.spec.Name, "restart", c.spec.Restart, "err", err)
+			return nil
+		}
+
+		if err != nil {
+			s.Error("child exited with error", err, "child", c.spec.Name)
+		} else {
+			s.Warn("child exited", "child", c.spec.Name)
+		}
+
+		n, exceeded := s.recordRestart()
+		if exceeded {
+			return errs.Wrapf(errors.Join(ErrMaxRestartsExceeded, err), "child %s restarted more than %d times in %s", c.spec.Name, s.MaxRestarts, s.Period)
+		}
+
+		backoff := s.backoff(n)
+		s.Info("restart child", "child", c.spec.Name, "strategy", s.Strategy, "restarts", n, "backoff", backoff)
+		t := time.NewTimer(backoff)
+		select {
+		case <-s.Stopping():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+
+		from := idx
+		switch s.Strategy {
+		case OneForAll:
+			from = 0
+			s.stopChildren(0)
+		case RestForOne:
+			s.stopChildren(idx + 1)
+		}
+
+		failedIdx := -1
+		for i := from; i < len(s.children); i++ {
+			if i != idx && s.Strategy == OneForOne {
+				continue
+			}
+			if i != idx && s.children[i].spec.Restart == Temporary {
+				continue
+			}
+			err = s.createChild(i)
+			if err != nil {
+				failedIdx = i
+				break
+			}
+			s.startChild(i)
+		}
+		if failedIdx < 0 {
+			return nil
+		}
+		idx = failedIdx
+		err = errs.Wrapf(err, "init child(%d) %s failed", idx, s.children[idx].spec.Name)
+	}
+}
+
+// recordRestart return the count of restarts within Period and whether it exceeds MaxRestarts.
+func (s *Supervisor) recordRestart() (int, bool) {
+	now := time.Now()
+	i := 0
+	for ; i < len(s.restarts); i++ {
+		if now.Sub(s.restarts[i]) <= s.Period {
+			break
+		}
+	}
+	s.restarts = append(s.restarts[i:], now)
+	return len(s.restarts), len(s.restarts) > s.MaxRestarts
+}
+
+func (s *Supervisor) backoff(n int) time.Duration {
+	if s.MinBackoff <= 0 {
+		return 0
+	}
+	d := s.MinBackoff
+	for i := 1; i < n; i++ {
+		d *= 2
+		if s.MaxBackoff > 0 && d >= s.MaxBackoff {
+			return s.MaxBackoff
+		}
+	}
+	return d
+}
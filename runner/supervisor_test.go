@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type flaky struct {
+	Runner
+	failAfter time.Duration
+}
+
+func (f *flaky) Start() error {
+	select {
+	case <-f.Stopping():
+		return nil
+	case <-time.After(f.failAfter):
+		return errors.New("flaky failed")
+	}
+}
+
+func newTestSupervisor(t *testing.T, cfg *SupervisorCfg) *Supervisor {
+	s := NewSupervisor("supervisor", cfg)
+	s.SetCtx(t.Context())
+	return s
+}
+
+func TestSupervisorOneForOne(t *testing.T) {
+	cfg := NewSupervisorCfg()
+	cfg.MinBackoff = time.Millisecond
+	cfg.MaxRestarts = 100
+
+	var flakyCreated, stableCreated atomic.Int32
+	s := newTestSupervisor(t, cfg)
+	s.Add(&ChildSpec{Name: "flaky", Creator: func() Runner {
+		flakyCreated.Add(1)
+		return &flaky{Runner: Create("flaky"), failAfter: 10 * time.Millisecond}
+	}}, &ChildSpec{Name: "stable", Creator: func() Runner {
+		stableCreated.Add(1)
+		return Create("stable")
+	}})
+	require.NoError(t, Init(s))
+	Start(s)
+
+	time.Sleep(200 * time.Millisecond)
+	StopAndWait(s)
+	require.NoError(t, s.Err())
+	require.Greater(t, flakyCreated.Load(), int32(2))
+	require.Equal(t, int32(1), stableCreated.Load())
+}
+
+func TestSupervisorOneForAll(t *testing.T) {
+	cfg := NewSupervisorCfg()
+	cfg.Strategy = OneForAll
+	cfg.MinBackoff = time.Millisecond
+	cfg.MaxRestarts = 1
+
+	var stableCreated atomic.Int32
+	s := newTestSupervisor(t, cfg)
+	s.Add(&ChildSpec{Name: "stable", Creator: func() Runner {
+		stableCreated.Add(1)
+		return Create("stable")
+	}}, &ChildSpec{Name: "flaky", Creator: func() Runner {
+		return &flaky{Runner: Create("flaky"), failAfter: 10 * time.Millisecond}
+	}})
+	require.NoError(t, Init(s))
+
+	err := Run(s)
+	require.ErrorIs(t, err, ErrMaxRestartsExceeded)
+	require.Equal(t, int32(2), stableCreated.Load())
+}
+
+type oneshot struct {
+	Runner
+}
+
+func (o *oneshot) Start() error {
+	return nil
+}
+
+func TestSupervisorTransient(t *testing.T) {
+	var created atomic.Int32
+	s := newTestSupervisor(t, nil)
+	s.Add(&ChildSpec{Name: "oneshot", Restart: Transient, Creator: func() Runner {
+		created.Add(1)
+		return &oneshot{Runner: Create("oneshot")}
+	}})
+	require.NoError(t, Init(s))
+
+	require.NoError(t, Run(s))
+	require.Equal(t, int32(1), created.Load())
+}
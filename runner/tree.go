@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+)
+
+// Node is a snapshot of a Runner in the runner tree.
+type Node struct {
+	Name     string         `json:"name"             yaml:"name"`
+	State    State          `json:"state"            yaml:"state"`
+	Age      string         `json:"age"              yaml:"age"`
+	Err      string         `json:"err,omitempty"    yaml:"err,omitempty"`
+	Fields   map[string]any `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Values   map[string]any `json:"values,omitempty" yaml:"values,omitempty"`
+	Children []*Node        `json:"children"         yaml:"children"`
+}
+
+// Root return the top most ancestor of r.
+func Root(r Runner) Runner {
+	for r.Parent() != nil {
+		r = r.Parent()
+	}
+	return r
+}
+
+// Walk the runner tree rooted at r in depth-first order,
+// children of a runner are skipped if f returns false.
+func Walk(r Runner, f func(r Runner, depth int) bool) {
+	walk(r, 0, f)
+}
+
+func walk(r Runner, depth int, f func(Runner, int) bool) {
+	if r == nil || !f(r, depth) {
+		return
+	}
+	for _, c := range r.Children() {
+		walk(c, depth+1, f)
+	}
+}
+
+// Dump the runner tree rooted at r.
+func Dump(r Runner) *Node {
+	if r == nil {
+		return nil
+	}
+
+	n := &Node{
+		Name:     r.Name(),
+		State:    r.State(),
+		Age:      time.Since(r.CreatedAt()).Truncate(time.Millisecond).String(),
+		Values:   r.LoadAll(),
+		Children: []*Node{},
+	}
+	if err := r.Err(); err != nil {
+		n.Err = err.Error()
+	}
+	fields := r.LoggerFields()
+	if len(fields) > 0 {
+		n.Fields = make(map[string]any, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			n.Fields[fmt.Sprint(fields[i])] = fields[i+1]
+		}
+	}
+
+	for _, c := range r.Children() {
+		if c == nil {
+			continue
+		}
+		n.Children = append(n.Children, Dump(c))
+	}
+	return n
+}
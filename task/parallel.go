@@ -0,0 +1,191 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/runner"
+	"github.com/donkeywon/golib/task/step"
+	"github.com/donkeywon/golib/util/v"
+)
+
+func init() {
+	plugin.Reg(StepTypeParallel, func() step.Step { return newParallelStep() }, func() any { return NewParallelCfg() })
+}
+
+const StepTypeParallel step.Type = "parallel"
+
+// ParallelCfg is cfg of a parallel step, Steps run concurrently and the parallel step fails
+// with all errors of them joined. Steps can be parallel steps too.
+// Steps of a parallel step at index i can reference steps before i, values of a step j in
+// Steps are stored as ${steps[i][j].key} after it is done.
+// Done records indexes of Steps which are done, they are not run again when the task is resumed.
+type ParallelCfg struct {
+	Steps []*step.Cfg `json:"steps"          validate:"required,dive" yaml:"steps"`
+	Done  []int       `json:"done,omitempty" yaml:"done,omitempty"`
+}
+
+func NewParallelCfg() *ParallelCfg {
+	return &ParallelCfg{}
+}
+
+type parallelStep struct {
+	step.Step
+	Cfg *ParallelCfg
+
+	t      *Task
+	refIdx int
+
+	mu      sync.Mutex
+	steps   []step.Step
+	hasRefs []bool
+	started []step.Step
+}
+
+func newParallelStep() *parallelStep {
+	return &parallelStep{
+		Step: step.CreateBase(string(StepTypeParallel)),
+		Cfg:  NewParallelCfg(),
+	}
+}
+
+// bindParallel bind s to t if it is a parallel step, its steps can reference steps before refIdx.
+func (t *Task) bindParallel(s step.Step, refIdx int) {
+	if p, ok := s.(*parallelStep); ok {
+		p.t = t
+		p.refIdx = refIdx
+	}
+}
+
+func (p *parallelStep) Init() error {
+	if p.t == nil {
+		return errs.New("parallel step must run in task")
+	}
+	err := v.Struct(p.Cfg)
+	if err != nil {
+		return err
+	}
+
+	p.steps = p.steps[:0]
+	p.hasRefs = p.hasRefs[:0]
+	for i, cfg := range p.Cfg.Steps {
+		hasRefs, err := p.t.checkStep(cfg, p.refIdx)
+		if err != nil {
+			return errs.Wrapf(err, "parallel step(%d) %s is invalid", i, cfg.Type)
+		}
+		p.steps = append(p.steps, p.createStep(i, cfg))
+		p.hasRefs = append(p.hasRefs, hasRefs)
+	}
+
+	for i, s := range p.steps {
+		if p.hasRefs[i] || p.done(i) {
+			continue
+		}
+		err = runner.Init(s)
+		if err != nil {
+			return errs.Wrapf(err, "init parallel step(%d) %s failed", i, s.Name())
+		}
+	}
+
+	return p.Step.Init()
+}
+
+func (p *parallelStep) Start() error {
+	var (
+		wg    sync.WaitGroup
+		errss = make([]error, len(p.steps))
+	)
+	// steps are replaced by new instances when they are retried
+	for i, s := range slices.Clone(p.steps) {
+		if p.done(i) {
+			continue
+		}
+
+		p.markStarted(s)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errss[i] = p.runStep(i, s)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errss...)
+}
+
+func (p *parallelStep) runStep(i int, s step.Step) (err error) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = runner.WithPhase(runner.PhasePanic, errs.PanicToErrWithMsg(r, fmt.Sprintf("parallel step(%d) %s panic", i, s.Name())))
+		}
+	}()
+
+	cfg := p.Cfg.Steps[i]
+	s, err = p.t.runStep(s, cfg, p.hasRefs[i], func() step.Step {
+		ns := p.createStep(i, cfg)
+		p.markStarted(ns)
+		return ns
+	})
+	p.mu.Lock()
+	p.steps[i] = s
+	p.mu.Unlock()
+	select {
+	case <-p.t.Stopping():
+		// interrupted, run it again when the task is resumed
+		return nil
+	case <-p.t.Ctx().Done():
+		return nil
+	default:
+	}
+
+	if err != nil && !cfg.ContinueOnError {
+		return errs.Wrapf(err, "run parallel step(%d) %s failed", i, s.Name())
+	}
+	if err != nil {
+		p.Warn("run parallel step failed, continue", "err", err, "parallel_step", i, "parallel_step_type", s.Name())
+	}
+
+	p.mu.Lock()
+	p.Cfg.Done = append(p.Cfg.Done, i)
+	p.mu.Unlock()
+	p.Store(strconv.Itoa(i), decodeValues(s.LoadAll()))
+	return nil
+}
+
+func (p *parallelStep) createStep(i int, cfg *step.Cfg) step.Step {
+	s := plugin.CreateWithCfg[step.Step](cfg.Type, cfg.Cfg)
+	s.Inherit(p)
+	s.WithLoggerFields("parallel_step", i, "parallel_step_type", s.Name())
+	p.t.bindParallel(s, p.refIdx)
+	return s
+}
+
+func (p *parallelStep) markStarted(s step.Step) {
+	p.mu.Lock()
+	p.started = append(p.started, s)
+	p.mu.Unlock()
+}
+
+func (p *parallelStep) Stop() error {
+	p.Cancel()
+
+	p.mu.Lock()
+	started := slices.Clone(p.started)
+	p.mu.Unlock()
+	for _, s := range started {
+		runner.Stop(s)
+	}
+	return nil
+}
+
+func (p *parallelStep) done(i int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Contains(p.Cfg.Done, i)
+}
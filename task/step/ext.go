@@ -0,0 +1,132 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/plugin/ext"
+	"github.com/donkeywon/golib/util/v"
+)
+
+func init() {
+	plugin.Reg(TypeExt, func() Step { return NewExtStep() }, func() any { return ext.NewCfg() })
+}
+
+const TypeExt Type = "ext"
+
+// RegExt register an out-of-process step plugin as typ, cfg of typ is passed to the plugin as is.
+func RegExt(typ Type, command ...string) {
+	plugin.Reg(typ, func() Step {
+		s := newExtStep(typ)
+		s.Command = command
+		return s
+	}, func() any { return &map[string]any{} })
+}
+
+// ExtStep run a step in a plugin process, see package ext.
+// The plugin is spawned on Start, so a step which is skipped or stopped before Start spawns nothing.
+type ExtStep struct {
+	Step
+	*ext.Cfg
+
+	mu      sync.Mutex
+	client  *ext.Client
+	stopped bool
+}
+
+func NewExtStep() *ExtStep {
+	return newExtStep(TypeExt)
+}
+
+func newExtStep(typ Type) *ExtStep {
+	return &ExtStep{
+		Step: CreateBase(string(typ)),
+		Cfg:  ext.NewCfg(),
+	}
+}
+
+func (e *ExtStep) Init() error {
+	err := v.Struct(e.Cfg)
+	if err != nil {
+		return err
+	}
+	e.WithLoggerFields("plugin", e.Command[0])
+	return e.Step.Init()
+}
+
+// Start spawn plugin and run it until it is done, or the step is canceled, e.g. on timeout.
+func (e *ExtStep) Start() error {
+	client, err := e.startClient()
+	if err != nil {
+		return err
+	}
+	defer e.closeClient(client)
+
+	_, err = client.Call(e.Ctx(), &ext.Frame{Call: ext.CallStart})
+	if err != nil {
+		return errs.Wrap(err, "run plugin failed")
+	}
+
+	result, err := client.Result()
+	if err != nil {
+		return errs.Wrap(err, "get plugin result failed")
+	}
+	for k, v := range result {
+		e.Store(k, v)
+	}
+	return nil
+}
+
+func (e *ExtStep) startClient() (*ext.Client, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return nil, errs.New("stopped before plugin started")
+	}
+
+	client, err := ext.Start(e.Cfg, func(line string) { e.Info("plugin stderr", "line", line) })
+	if err != nil {
+		return nil, err
+	}
+	err = client.Init(ext.KindStep, e.Cfg.Cfg)
+	if err != nil {
+		e.closeClient(client)
+		return nil, err
+	}
+	e.client = client
+	return client, nil
+}
+
+func (e *ExtStep) Stop() error {
+	e.mu.Lock()
+	e.stopped = true
+	client := e.client
+	e.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	_, err := client.Call(e.Ctx(), &ext.Frame{Call: ext.CallStop})
+	if err != nil && !errors.Is(err, ext.ErrClosed) && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+func (e *ExtStep) SetCfg(cfg any) {
+	if c, ok := cfg.(*ext.Cfg); ok {
+		e.Cfg = c
+		return
+	}
+	// registered by RegExt, cfg is for plugin
+	e.Cfg.Cfg = cfg
+}
+
+func (e *ExtStep) closeClient(client *ext.Client) {
+	err := client.Close()
+	if err != nil {
+		e.Error("close plugin failed", err)
+	}
+}
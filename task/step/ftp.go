@@ -5,6 +5,7 @@ import (
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/ftp"
 	"github.com/donkeywon/golib/plugin"
+	"github.com/donkeywon/golib/util/secret"
 	"github.com/donkeywon/golib/util/v"
 )
 
@@ -15,11 +16,11 @@ func init() {
 const TypeFtp Type = "ftp"
 
 type FtpStepCfg struct {
-	Addr    string `json:"addr"    yaml:"addr" validate:"required"`
-	User    string `json:"user"    yaml:"user" validate:"required"`
-	Pwd     string `json:"pwd"     yaml:"pwd"`
-	Timeout int    `json:"timeout" yaml:"timeout"`
-	Retry   int    `json:"retry"   yaml:"retry"`
+	Addr    string        `json:"addr"    yaml:"addr" validate:"required"`
+	User    string        `json:"user"    yaml:"user" validate:"required"`
+	Pwd     secret.String `json:"pwd"     yaml:"pwd"`
+	Timeout int           `json:"timeout" yaml:"timeout"`
+	Retry   int           `json:"retry"   yaml:"retry"`
 
 	Cmd  string   `json:"cmd"     yaml:"cmd"     validate:"required"`
 	Args []string `json:"args"    yaml:"args"`
@@ -0,0 +1,76 @@
+package step
+
+import (
+	"regexp"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+type Backoff string
+
+const (
+	BackoffFixed       Backoff = "fixed"
+	BackoffExponential Backoff = "exponential"
+)
+
+// RetryCfg is the retry policy of a step, a failed step is run again by a new instance of it.
+// Attempts is max runs of the step including the first one. Delay and MaxDelay are in seconds,
+// Delay is doubled every attempt if Backoff is exponential and MaxDelay 0 means no limit.
+// RetryOn are regexps matched against error of the step, all errors are retryable if it is empty.
+type RetryCfg struct {
+	Attempts int      `json:"attempts"           validate:"gte=1"   yaml:"attempts"`
+	Backoff  Backoff  `json:"backoff,omitempty"  yaml:"backoff,omitempty"`
+	Delay    int      `json:"delay,omitempty"    yaml:"delay,omitempty"`
+	MaxDelay int      `json:"maxDelay,omitempty" yaml:"maxDelay,omitempty"`
+	RetryOn  []string `json:"retryOn,omitempty"  yaml:"retryOn,omitempty"`
+
+	retryOn []*regexp.Regexp // compiled RetryOn
+}
+
+// Validate check cfg and compile RetryOn, it must be called before Retryable.
+func (c *RetryCfg) Validate() error {
+	switch c.Backoff {
+	case "", BackoffFixed, BackoffExponential:
+	default:
+		return errs.Errorf("unknown backoff: %s", c.Backoff)
+	}
+	retryOn, err := compileRetryOn(c.RetryOn)
+	if err != nil {
+		return err
+	}
+	c.retryOn = retryOn
+	return nil
+}
+
+// Retryable return whether err matches RetryOn.
+func (c *RetryCfg) Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if len(c.RetryOn) == 0 {
+		return true
+	}
+	retryOn := c.retryOn
+	if len(retryOn) != len(c.RetryOn) {
+		// not validated
+		retryOn, _ = compileRetryOn(c.RetryOn)
+	}
+	for _, re := range retryOn {
+		if re.MatchString(err.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileRetryOn(retryOn []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(retryOn))
+	for _, s := range retryOn {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid retryOn: %s", s)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
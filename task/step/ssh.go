@@ -10,6 +10,7 @@ import (
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/plugin"
 	"github.com/donkeywon/golib/util/bufferpool"
+	"github.com/donkeywon/golib/util/secret"
 	"github.com/donkeywon/golib/util/sshs"
 	"github.com/donkeywon/golib/util/v"
 	"golang.org/x/crypto/ssh"
@@ -22,11 +23,11 @@ func init() {
 const TypeSSH Type = "ssh"
 
 type SSHStepCfg struct {
-	Addr       string `json:"addr"       yaml:"addr" validate:"required"`
-	User       string `json:"user"       yaml:"user" validate:"required"`
-	Pwd        string `json:"pwd"        yaml:"pwd"  validate:"required"`
-	PrivateKey string `json:"privateKey" yaml:"privateKey"`
-	Timeout    int    `json:"timeout"    yaml:"timeout"`
+	Addr       string        `json:"addr"       yaml:"addr" validate:"required"`
+	User       string        `json:"user"       yaml:"user" validate:"required"`
+	Pwd        secret.String `json:"pwd"        yaml:"pwd"  validate:"required"`
+	PrivateKey secret.String `json:"privateKey" yaml:"privateKey"`
+	Timeout    int           `json:"timeout"    yaml:"timeout"`
 
 	Cmd []string `json:"cmd"  yaml:"cmd" validate:"required"`
 }
@@ -61,7 +62,7 @@ func (s *SSHStep) Init() error {
 
 func (s *SSHStep) Start() error {
 	var err error
-	s.cli, s.sess, err = sshs.NewClient(s.SSHStepCfg.Addr, s.SSHStepCfg.User, s.SSHStepCfg.Pwd, []byte(s.SSHStepCfg.PrivateKey), time.Second*time.Duration(s.SSHStepCfg.Timeout))
+	s.cli, s.sess, err = sshs.NewClient(s.SSHStepCfg.Addr, s.SSHStepCfg.User, s.SSHStepCfg.Pwd.Value(), []byte(s.SSHStepCfg.PrivateKey.Value()), time.Second*time.Duration(s.SSHStepCfg.Timeout))
 	if err != nil {
 		return errs.Wrap(err, "create ssh client failed")
 	}
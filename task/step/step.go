@@ -16,10 +16,23 @@ type Type string
 type Cfg struct {
 	Type Type `json:"type" validate:"required" yaml:"type"`
 	Cfg  any  `json:"cfg"  validate:"required" yaml:"cfg"`
+
+	// When is a condition like ${steps[0].exit} == 0, the step is skipped if it is false, see task.Task.
+	When string `json:"when,omitempty" yaml:"when,omitempty"`
+	// ContinueOnError run next steps even if this step failed, the error is not an error of task.
+	ContinueOnError bool `json:"continueOnError,omitempty" yaml:"continueOnError,omitempty"`
+	// Retry the step if it failed, default is retry of task.
+	Retry *RetryCfg `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Timeout in seconds of every attempt, 0 means timeout of task.
+	Timeout int `json:"timeout,omitempty" yaml:"timeout,omitempty"`
 }
 
-type stepCfgOnlyCfg struct {
-	Cfg any `json:"cfg" yaml:"cfg"`
+type stepCfgWithoutType struct {
+	Cfg             any       `json:"cfg"             yaml:"cfg"`
+	When            string    `json:"when"            yaml:"when"`
+	ContinueOnError bool      `json:"continueOnError" yaml:"continueOnError"`
+	Retry           *RetryCfg `json:"retry"           yaml:"retry"`
+	Timeout         int       `json:"timeout"         yaml:"timeout"`
 }
 
 func (s *Cfg) UnmarshalJSON(data []byte) error {
@@ -40,16 +53,20 @@ func (s *Cfg) customUnmarshal(data []byte, unmarshaler func([]byte, any) error)
 	}
 	s.Type = Type(typ.Str)
 
-	cv := stepCfgOnlyCfg{}
+	cv := stepCfgWithoutType{}
 	cv.Cfg = plugin.CreateCfg[any](s.Type)
-	if cv.Cfg == nil {
-		return nil
-	}
+	cfgCreated := cv.Cfg != nil
 	err := unmarshaler(data, &cv)
 	if err != nil {
 		return err
 	}
-	s.Cfg = cv.Cfg
+	s.When = cv.When
+	s.ContinueOnError = cv.ContinueOnError
+	s.Retry = cv.Retry
+	s.Timeout = cv.Timeout
+	if cfgCreated {
+		s.Cfg = cv.Cfg
+	}
 	return nil
 }
 
@@ -1,14 +1,22 @@
 package task
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/avast/retry-go/v4"
 	"github.com/donkeywon/golib/consts"
 	"github.com/donkeywon/golib/errs"
 	"github.com/donkeywon/golib/plugin"
 	"github.com/donkeywon/golib/runner"
 	"github.com/donkeywon/golib/task/step"
+	"github.com/donkeywon/golib/util/interp"
+	"github.com/donkeywon/golib/util/jsons"
 	"github.com/donkeywon/golib/util/reflects"
 	"github.com/donkeywon/golib/util/v"
 )
@@ -19,6 +27,13 @@ func init() {
 
 const PluginTypeTask plugin.Type = "task"
 
+const (
+	refScopeTask   = "task"
+	refScopeValues = "values"
+	refScopeEnv    = "env"
+	refScopeSteps  = "steps"
+)
+
 type Type string
 
 type Collector func(*Task) any
@@ -32,14 +47,46 @@ type HookExtraData struct {
 }
 
 type Cfg struct {
-	ID              string         `json:"id"              validate:"required" yaml:"id"`
-	Type            Type           `json:"type"            validate:"required" yaml:"type"`
-	Steps           []*step.Cfg    `json:"steps"           validate:"required" yaml:"steps"`
-	DeferSteps      []*step.Cfg    `json:"deferSteps"      yaml:"deferSteps"`
-	CurStepIdx      int            `json:"curStepIdx"      yaml:"curStepIdx"`
-	CurDeferStepIdx int            `json:"curDeferStepIdx" yaml:"curDeferStepIdx"`
-	Pool            string         `json:"pool"            yaml:"pool"`
-	Values          map[string]any `json:"values"          yaml:"values"`
+	ID              string            `json:"id"                    validate:"required"             yaml:"id"`
+	Type            Type              `json:"type"                  validate:"required"             yaml:"type"`
+	Steps           []*step.Cfg       `json:"steps"                 validate:"required"             yaml:"steps"`
+	DeferSteps      []*step.Cfg       `json:"deferSteps"            yaml:"deferSteps"`
+	CurStepIdx      int               `json:"curStepIdx"            yaml:"curStepIdx"`
+	CurDeferStepIdx int               `json:"curDeferStepIdx"       yaml:"curDeferStepIdx"`
+	Pool            string            `json:"pool"                  yaml:"pool"`
+	Values          map[string]any    `json:"values"                yaml:"values"`
+	StepRetry       *step.RetryCfg    `json:"stepRetry,omitempty"   yaml:"stepRetry,omitempty"`   // default retry of steps
+	StepTimeout     int               `json:"stepTimeout,omitempty" yaml:"stepTimeout,omitempty"` // default timeout of steps in seconds
+	DependsOn       []*Dependency     `json:"dependsOn,omitempty"   validate:"dive"                 yaml:"dependsOn,omitempty"`
+	Priority        int               `json:"priority,omitempty"    yaml:"priority,omitempty"` // higher runs first when queued
+	Labels          map[string]string `json:"labels,omitempty"      yaml:"labels,omitempty"`
+}
+
+type DependCondition string
+
+const (
+	DependOnSuccess DependCondition = "success"
+	DependOnFailure DependCondition = "failure"
+	DependAlways    DependCondition = "always"
+)
+
+// Dependency is a task which must be done before the task runs, the task is canceled if Condition is not met.
+// Condition is success by default, it is not used by Task itself but the scheduler like taskd.
+type Dependency struct {
+	TaskID    string          `json:"taskId"              validate:"required"                                yaml:"taskId"`
+	Condition DependCondition `json:"condition,omitempty" validate:"omitempty,oneof=success failure always" yaml:"condition,omitempty"`
+}
+
+// Met return whether the condition is met by a done task.
+func (d *Dependency) Met(succeeded bool) bool {
+	switch d.Condition {
+	case DependOnFailure:
+		return !succeeded
+	case DependAlways:
+		return true
+	default:
+		return succeeded
+	}
 }
 
 func NewCfg() *Cfg {
@@ -56,6 +103,19 @@ func (c *Cfg) SetType(t Type) *Cfg {
 	return c
 }
 
+func (c *Cfg) SetPriority(priority int) *Cfg {
+	c.Priority = priority
+	return c
+}
+
+func (c *Cfg) SetLabel(key string, value string) *Cfg {
+	if c.Labels == nil {
+		c.Labels = make(map[string]string)
+	}
+	c.Labels[key] = value
+	return c
+}
+
 func (c *Cfg) Add(typ step.Type, cfg any) *Cfg {
 	c.Steps = append(c.Steps, &step.Cfg{Type: typ, Cfg: cfg})
 	return c
@@ -66,12 +126,41 @@ func (c *Cfg) Defer(typ step.Type, cfg any) *Cfg {
 	return c
 }
 
+func (c *Cfg) DependOn(taskID string, cond DependCondition) *Cfg {
+	c.DependsOn = append(c.DependsOn, &Dependency{TaskID: taskID, Condition: cond})
+	return c
+}
+
+var ErrStepTimeout = errors.New("step timeout")
+
+// Attempt is a failed run of a step which is retried.
+type Attempt struct {
+	Attempt       int    `json:"attempt"       yaml:"attempt"`
+	StartTimeNano int64  `json:"startTimeNano" yaml:"startTimeNano"`
+	StopTimeNano  int64  `json:"stopTimeNano"  yaml:"stopTimeNano"`
+	Err           string `json:"err"           yaml:"err"`
+}
+
 type Result struct {
 	Data           map[string]any   `json:"data"           yaml:"data"`
 	StepsData      []map[string]any `json:"stepsData"      yaml:"stepsData"`
 	DeferStepsData []map[string]any `json:"deferStepsData" yaml:"deferStepsData"`
+	Errs           []*runner.Error  `json:"errs,omitempty" yaml:"errs,omitempty"`
 }
 
+// Task run steps in order, then defer steps in reverse order.
+//
+// Strings in cfgs of steps can reference ${task.x}, ${values.x}, ${env.X} and ${steps[i].key} where key is
+// a value stored by an earlier step, e.g. ${steps[0].stdout}. A step with references is initialized
+// just before it runs with references resolved, unresolved references fail the step.
+// Values stored by steps are not checkpointed, so a resumed task can only reference steps before CurStepIdx
+// if their values are copied like taskd does.
+//
+// A step is skipped if its When condition is false, see evalWhen. A failed step with ContinueOnError
+// does not fail the task, its error can be referenced by ${steps[i].err}.
+// Steps of type parallel run their steps concurrently, see ParallelCfg.
+// A failed step is retried by its Retry or StepRetry of task, every attempt is stopped after Timeout
+// or StepTimeout of task. Attempts and failed attempts of a retried step are stored in its values.
 type Task struct {
 	runner.Runner
 	*Cfg
@@ -81,6 +170,10 @@ type Task struct {
 
 	steps      []step.Step
 	deferSteps []step.Step
+
+	// whether cfg of step has references, such step is initialized lazily
+	stepHasRefs      []bool
+	deferStepHasRefs []bool
 }
 
 func New() *Task {
@@ -96,17 +189,37 @@ func (t *Task) Init() error {
 		return err
 	}
 
+	if t.StepRetry != nil {
+		err = validateRetry(t.StepRetry)
+		if err != nil {
+			return errs.Wrap(err, "invalid step retry")
+		}
+	}
+
 	for i, cfg := range t.Cfg.Steps {
+		hasRefs, err := t.checkStep(cfg, i)
+		if err != nil {
+			return errs.Wrapf(err, "step(%d) %s is invalid", i, cfg.Type)
+		}
 		step := t.createStep(i, cfg, false)
 		t.steps = append(t.steps, step)
+		t.stepHasRefs = append(t.stepHasRefs, hasRefs)
 	}
 
 	for i, cfg := range t.Cfg.DeferSteps {
+		hasRefs, err := t.checkStep(cfg, len(t.Cfg.Steps))
+		if err != nil {
+			return errs.Wrapf(err, "defer step(%d) %s is invalid", i, cfg.Type)
+		}
 		step := t.createStep(i, cfg, true)
 		t.deferSteps = append(t.deferSteps, step)
+		t.deferStepHasRefs = append(t.deferStepHasRefs, hasRefs)
 	}
 
 	for i := t.Cfg.CurStepIdx; i < len(t.steps); i++ {
+		if t.stepHasRefs[i] {
+			continue
+		}
 		err = runner.Init(t.steps[i])
 		if err != nil {
 			return errs.Wrapf(err, "init step(%d) %s failed", i, t.steps[i].Name())
@@ -114,6 +227,9 @@ func (t *Task) Init() error {
 	}
 
 	for i := len(t.Cfg.DeferSteps) - 1 - t.Cfg.CurDeferStepIdx; i >= 0; i-- {
+		if t.deferStepHasRefs[i] {
+			continue
+		}
 		err = runner.Init(t.deferSteps[i])
 		if err != nil {
 			return errs.Wrapf(err, "init defer step(%d) %s failed", i, t.deferSteps[i].Name())
@@ -148,7 +264,9 @@ func (t *Task) HookDeferStepDone(hook ...StepHook) {
 }
 
 func (t *Task) Result() *Result {
-	r := &Result{}
+	r := &Result{
+		Errs: runner.Errors(t.Err()),
+	}
 	for _, step := range t.Steps() {
 		v := step.LoadAll()
 		r.StepsData = append(r.StepsData, v)
@@ -189,13 +307,18 @@ func (t *Task) createStep(idx int, stepCfg *step.Cfg, isDefer bool) step.Step {
 	s := plugin.CreateWithCfg[step.Step](stepCfg.Type, stepCfg.Cfg)
 	s.Inherit(t)
 	s.WithLoggerFields(stepOrDefer, idx, stepOrDefer+"_type", s.Name())
+	if isDefer {
+		t.bindParallel(s, len(t.Cfg.Steps))
+	} else {
+		t.bindParallel(s, idx)
+	}
 	return s
 }
 
 func (t *Task) recoverStepPanic() {
 	err := recover()
 	if err != nil {
-		t.AppendError(errs.PanicToErrWithMsg(err, fmt.Sprintf("step(%d) %s panic", t.CurStepIdx, t.Steps()[t.CurStepIdx].Name())))
+		t.AppendError(runner.WithPhase(runner.PhasePanic, errs.PanicToErrWithMsg(err, fmt.Sprintf("step(%d) %s panic", t.CurStepIdx, t.Steps()[t.CurStepIdx].Name()))))
 	}
 }
 
@@ -212,12 +335,20 @@ func (t *Task) runSteps() {
 		}
 
 		st := t.Steps()[t.CurStepIdx]
+		stepCfg := t.Cfg.Steps[t.CurStepIdx]
 		st.Store(consts.FieldStartTimeNano, time.Now().UnixNano())
-		err := runner.Run(st)
+		stepIdx := t.CurStepIdx
+		st, err := t.runStep(st, stepCfg, t.stepHasRefs[stepIdx], func() step.Step {
+			return t.createStep(stepIdx, stepCfg, false)
+		})
+		t.steps[stepIdx] = st
 		st.Store(consts.FieldStopTimeNano, time.Now().UnixNano())
+		// step is interrupted if task is stopped or canceled by parent, it is run again on resume
 		select {
 		case <-t.Stopping():
 			return
+		case <-t.Ctx().Done():
+			return
 		default:
 			t.CurStepIdx++
 		}
@@ -234,6 +365,10 @@ func (t *Task) runSteps() {
 			}(i, hook)
 		}
 		if err != nil {
+			if stepCfg.ContinueOnError {
+				t.Warn("run step failed, continue", "err", err, "step_idx", stepIdx, "step_type", st.Name())
+				continue
+			}
 			t.AppendError(errs.Wrapf(err, "run step(%d) %s failed", t.CurStepIdx, st.Name()))
 			return
 		}
@@ -248,21 +383,28 @@ func (t *Task) runDeferSteps() {
 		default:
 		}
 
-		deferStep := t.deferSteps[len(t.deferSteps)-1-t.CurDeferStepIdx]
+		deferStepIdx := len(t.deferSteps) - 1 - t.CurDeferStepIdx
+		deferStep := t.deferSteps[deferStepIdx]
 		func() {
 			defer func() {
 				err := recover()
 				if err != nil {
-					t.AppendError(errs.PanicToErrWithMsg(err, fmt.Sprintf("defer step(%d) %s panic", t.CurDeferStepIdx, t.CurDeferStep().Name())))
+					t.AppendError(runner.WithPhase(runner.PhasePanic, errs.PanicToErrWithMsg(err, fmt.Sprintf("defer step(%d) %s panic", t.CurDeferStepIdx, t.CurDeferStep().Name()))))
 				}
 			}()
 
+			deferStepCfg := t.Cfg.DeferSteps[deferStepIdx]
 			deferStep.Store(consts.FieldStartTimeNano, time.Now().Unix())
-			err := runner.Run(deferStep)
+			deferStep, err := t.runStep(deferStep, deferStepCfg, t.deferStepHasRefs[deferStepIdx], func() step.Step {
+				return t.createStep(deferStepIdx, deferStepCfg, true)
+			})
+			t.deferSteps[deferStepIdx] = deferStep
 			deferStep.Store(consts.FieldStopTimeNano, time.Now().Unix())
 			select {
 			case <-t.Stopping():
 				return
+			case <-t.Ctx().Done():
+				return
 			default:
 				t.CurDeferStepIdx++
 			}
@@ -278,9 +420,225 @@ func (t *Task) runDeferSteps() {
 					h(t, t.CurDeferStepIdx, deferStep)
 				}(i, hook)
 			}
-			if err != nil {
+			if err != nil && !deferStepCfg.ContinueOnError {
 				t.AppendError(errs.Wrapf(err, "run defer(%d) step %s failed", t.CurDeferStepIdx, deferStep.Name()))
 			}
 		}()
 	}
 }
+
+// checkStep validate retry of step and check references in its cfg and condition, return whether cfg has references.
+// Cfg of parallel step is not checked here, its steps are checked by itself.
+func (t *Task) checkStep(cfg *step.Cfg, stepIdx int) (bool, error) {
+	if cfg.Retry != nil {
+		err := validateRetry(cfg.Retry)
+		if err != nil {
+			return false, errs.Wrap(err, "invalid retry")
+		}
+	}
+	_, err := checkRefs(cfg.When, stepIdx)
+	if err != nil {
+		return false, err
+	}
+	if cfg.Type == StepTypeParallel {
+		return false, nil
+	}
+	return checkRefs(cfg.Cfg, stepIdx)
+}
+
+// checkRefs return whether v has references, references to unknown scopes or steps not before stepIdx are invalid.
+func checkRefs(v any, stepIdx int) (bool, error) {
+	refs, err := interp.Refs(v)
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range refs {
+		keys, _ := interp.ParseRef(ref)
+		switch keys[0] {
+		case refScopeTask, refScopeValues, refScopeEnv:
+		case refScopeSteps:
+			idx := -1
+			if len(keys) > 1 {
+				idx, _ = strconv.Atoi(keys[1])
+			}
+			if idx < 0 || idx >= stepIdx {
+				return false, errs.Errorf("${%s} must reference a step before current one", ref)
+			}
+		default:
+			return false, errs.Errorf("${%s} has unknown scope: %s", ref, keys[0])
+		}
+	}
+	return len(refs) > 0, nil
+}
+
+// runStep run st if its condition is true, st is initialized first if its cfg has references.
+// A failed st is retried by a new instance created by recreate, the last instance is returned.
+func (t *Task) runStep(st step.Step, stepCfg *step.Cfg, hasRefs bool, recreate func() step.Step) (step.Step, error) {
+	ok, err := evalWhen(stepCfg.When, t.resolver())
+	if err != nil {
+		return st, errs.Wrapf(err, "evaluate condition failed: %s", stepCfg.When)
+	}
+	if !ok {
+		st.Info("condition is false, skip", "when", stepCfg.When)
+		st.Store(consts.FieldSkipped, true)
+		return st, nil
+	}
+
+	retryCfg := stepCfg.Retry
+	if retryCfg == nil {
+		retryCfg = t.StepRetry
+	}
+	timeout := stepCfg.Timeout
+	if timeout <= 0 {
+		timeout = t.StepTimeout
+	}
+	if retryCfg == nil || retryCfg.Attempts <= 1 {
+		err = t.runAttempt(st, stepCfg, hasRefs, timeout)
+		if err != nil {
+			st.Store(consts.FieldErr, err.Error())
+		}
+		return st, err
+	}
+
+	var (
+		attempt int
+		history []*Attempt
+	)
+	startTime, hasStartTime := st.Load(consts.FieldStartTimeNano)
+	err = retry.Do(
+		func() error {
+			attempt++
+			if attempt > 1 {
+				st = recreate()
+				// the last instance is the result of step
+				if hasStartTime {
+					st.Store(consts.FieldStartTimeNano, startTime)
+				}
+				if !hasRefs {
+					err := runner.Init(st)
+					if err != nil {
+						history = append(history, &Attempt{Attempt: attempt, Err: err.Error()})
+						return errs.Wrap(err, "init failed")
+					}
+				}
+			}
+			a := &Attempt{Attempt: attempt, StartTimeNano: time.Now().UnixNano()}
+			err := t.runAttempt(st, stepCfg, hasRefs, timeout)
+			a.StopTimeNano = time.Now().UnixNano()
+			if err != nil {
+				a.Err = err.Error()
+				history = append(history, a)
+			}
+			return err
+		},
+		retryOptions(t.Ctx(), retryCfg, func(n uint, err error) {
+			st.Warn("run step failed, retry", "err", err, "attempt", n+1, "attempts", retryCfg.Attempts)
+		})...,
+	)
+	st.Store(consts.FieldAttempts, attempt)
+	if len(history) > 0 {
+		st.Store(consts.FieldRetryHistory, history)
+	}
+	if err != nil {
+		st.Store(consts.FieldErr, err.Error())
+	}
+	return st, err
+}
+
+// runAttempt run st once, st is stopped if it runs longer than timeout seconds.
+func (t *Task) runAttempt(st step.Step, stepCfg *step.Cfg, hasRefs bool, timeout int) error {
+	if hasRefs {
+		err := t.initWithRefs(st, stepCfg)
+		if err != nil {
+			return errs.Wrap(err, "init failed")
+		}
+	}
+	if timeout <= 0 {
+		return runner.Run(st)
+	}
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+		timedOut.Store(true)
+		st.Warn("timeout, cancel", "timeout", timeout)
+		st.Cancel()
+	})
+	err := runner.Run(st)
+	timer.Stop()
+	if timedOut.Load() {
+		// a canceled step may exit without error
+		return errors.Join(errs.Wrapf(ErrStepTimeout, "timeout after %ds", timeout), err)
+	}
+	return err
+}
+
+func (t *Task) resolver() interp.Resolver {
+	return interp.NewResolver(map[string]any{
+		refScopeTask:   t.LoadAll(),
+		refScopeValues: t.Values,
+		refScopeEnv:    interp.Env,
+		refScopeSteps:  interp.Getter(t.stepValues),
+	})
+}
+
+// initWithRefs set cfg of st with references resolved and initialize it, stepCfg itself is not modified.
+func (t *Task) initWithRefs(st step.Step, stepCfg *step.Cfg) error {
+	cfg, err := interp.Copy(stepCfg.Cfg, t.resolver())
+	if err != nil {
+		return errs.Wrap(err, "resolve reference failed")
+	}
+	plugin.SetCfg(st, cfg)
+	return runner.Init(st)
+}
+
+// stepValues return values stored by step which has run.
+func (t *Task) stepValues(key string) (any, bool) {
+	idx, err := strconv.Atoi(key)
+	if err != nil || idx < 0 || idx >= min(t.CurStepIdx, len(t.steps)) {
+		return nil, false
+	}
+	return decodeValues(t.steps[idx].LoadAll()), true
+}
+
+func validateRetry(cfg *step.RetryCfg) error {
+	err := v.Struct(cfg)
+	if err != nil {
+		return err
+	}
+	return cfg.Validate()
+}
+
+func retryOptions(ctx context.Context, cfg *step.RetryCfg, onRetry retry.OnRetryFunc) []retry.Option {
+	delayType := retry.FixedDelay
+	if cfg.Backoff == step.BackoffExponential {
+		delayType = retry.BackOffDelay
+	}
+	opts := []retry.Option{
+		retry.Context(ctx),
+		retry.Attempts(uint(cfg.Attempts)),
+		retry.Delay(time.Duration(cfg.Delay) * time.Second),
+		retry.DelayType(delayType),
+		retry.RetryIf(func(err error) bool {
+			return ctx.Err() == nil && cfg.Retryable(err)
+		}),
+		retry.OnRetry(onRetry),
+		retry.LastErrorOnly(true),
+	}
+	if cfg.MaxDelay > 0 {
+		opts = append(opts, retry.MaxDelay(time.Duration(cfg.MaxDelay)*time.Second))
+	}
+	return opts
+}
+
+// decodeValues decode lists like stdout lines in values of step, they are stored as JSON by StoreAsString.
+func decodeValues(vals map[string]any) map[string]any {
+	for k, v := range vals {
+		if s, ok := v.(string); ok && strings.HasPrefix(s, "[") {
+			var l []any
+			if jsons.UnmarshalString(s, &l) == nil {
+				vals[k] = l
+			}
+		}
+	}
+	return vals
+}
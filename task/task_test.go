@@ -1,14 +1,20 @@
 package task
 
 import (
+	"errors"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/donkeywon/golib/consts"
+	"github.com/donkeywon/golib/plugin"
 	"github.com/donkeywon/golib/runner"
 	"github.com/donkeywon/golib/task/step"
 	"github.com/donkeywon/golib/util/cmd"
+	"github.com/donkeywon/golib/util/interp"
 	"github.com/donkeywon/golib/util/tests"
 	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
 )
 
 func TestTask(t *testing.T) {
@@ -41,3 +47,162 @@ func TestTask(t *testing.T) {
 
 	task.Info("result", "result", task.Result())
 }
+
+func TestTaskRefs(t *testing.T) {
+	cfg := NewCfg().Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"echo", "/tmp/data"},
+	}).Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"echo", "${steps[0].stdout}.${values.ext}"},
+	}).SetID("test-task-refs").SetType(Type("test"))
+	cfg.Values = map[string]any{"ext": "gz"}
+
+	task := New()
+	task.Cfg = cfg
+	tests.DebugInit(task)
+	require.NoError(t, runner.Init(task))
+	require.NoError(t, runner.Run(task))
+	require.Equal(t, `["/tmp/data.gz"]`, task.Steps()[1].LoadAsString("stdout"))
+	require.Equal(t, "${steps[0].stdout}.${values.ext}", cfg.Steps[1].Cfg.(*cmd.Cfg).Command[1])
+
+	cfg = NewCfg().Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"echo", "${steps[1].stdout}"},
+	}).Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"echo"},
+	}).SetID("test-task-refs").SetType(Type("test"))
+	task = New()
+	task.Cfg = cfg
+	tests.DebugInit(task)
+	require.Error(t, runner.Init(task))
+
+	cfg = NewCfg().Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"echo", "${values.none}"},
+	}).SetID("test-task-refs").SetType(Type("test"))
+	task = New()
+	task.Cfg = cfg
+	tests.DebugInit(task)
+	require.NoError(t, runner.Init(task))
+	require.Error(t, runner.Run(task))
+}
+
+func TestTaskWhenAndParallel(t *testing.T) {
+	cfg := NewCfg().Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"false"},
+	}).Add(StepTypeParallel, &ParallelCfg{
+		Steps: []*step.Cfg{
+			{Type: step.TypeCmd, Cfg: &cmd.Cfg{Command: []string{"echo", "a"}}},
+			{Type: step.TypeCmd, Cfg: &cmd.Cfg{Command: []string{"echo", "${values.b}"}}},
+			{Type: step.TypeCmd, Cfg: &cmd.Cfg{Command: []string{"echo", "c"}}, When: "${values.b} != b"},
+		},
+	}).Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"echo", "${steps[1][0].stdout}${steps[1][1].stdout}"},
+	}).SetID("test-task-parallel").SetType(Type("test"))
+	cfg.Steps[0].ContinueOnError = true
+	cfg.Steps[1].When = "${steps[0].exit} != 0"
+	cfg.Values = map[string]any{"b": "b"}
+
+	task := New()
+	task.Cfg = cfg
+	tests.DebugInit(task)
+	require.NoError(t, runner.Init(task))
+	require.NoError(t, runner.Run(task))
+	require.NotEmpty(t, task.Steps()[0].LoadAsString("err"))
+	require.ElementsMatch(t, []int{0, 1, 2}, cfg.Steps[1].Cfg.(*ParallelCfg).Done)
+	require.Equal(t, `["ab"]`, task.Steps()[2].LoadAsString("stdout"))
+}
+
+func TestEvalWhen(t *testing.T) {
+	r := interp.NewResolver(map[string]any{"values": map[string]any{"a": "1", "b": "false", "c": "", "d": "p && q"}})
+	for cond, want := range map[string]bool{
+		"":                                 true,
+		"${values.a}":                      true,
+		"${values.b}":                      false,
+		"!${values.b}":                     true,
+		"${values.c}":                      false,
+		"${values.a} == 1":                 true,
+		"'${values.a}' != '1'":             false,
+		"${values.b} || ${values.a}":       true,
+		"${values.b} && ${values.a}":       false,
+		"${values.a} == 1 && !${values.c}": true,
+		"'a || b' == 'a || b'":             true,
+		"'a && b' == 'a'":                  false,
+		"\"x==y\" != \"x\"":                true,
+		"${values.d} == 'p && q'":          true,
+		"${values.d} != 'p && q' || '!'":   true,
+	} {
+		got, err := evalWhen(cond, r)
+		require.NoError(t, err, cond)
+		require.Equal(t, want, got, cond)
+	}
+
+	_, err := evalWhen("${values.x}", r)
+	require.Error(t, err)
+}
+
+func TestTaskRetry(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "count")
+	cfg := NewCfg().Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"sh", "-c", "echo >> " + f + "; [ $(wc -l < " + f + ") -ge 3 ]"},
+	}).Add(step.TypeCmd, &cmd.Cfg{
+		Command: []string{"sleep", "5"},
+	}).SetID("test-task-retry").SetType(Type("test"))
+	cfg.StepRetry = &step.RetryCfg{Attempts: 3}
+	cfg.Steps[1].Timeout = 1
+	cfg.Steps[1].Retry = &step.RetryCfg{Attempts: 2, RetryOn: []string{"exit status 2"}}
+
+	task := New()
+	task.Cfg = cfg
+	tests.DebugInit(task)
+	require.NoError(t, runner.Init(task))
+	err := runner.Run(task)
+	require.ErrorIs(t, err, ErrStepTimeout)
+
+	result := task.Result()
+	require.Equal(t, "3", result.StepsData[0]["attempts"])
+	require.Empty(t, result.StepsData[0]["err"])
+	require.Len(t, gjson.Get(result.StepsData[0]["retryHistory"].(string), "#.err").Array(), 2)
+	require.Equal(t, "1", result.StepsData[1]["attempts"])
+
+	cfg.Steps[1].Retry.RetryOn = []string{"exit status ("}
+	task = New()
+	task.Cfg = cfg
+	tests.DebugInit(task)
+	require.ErrorContains(t, runner.Init(task), "invalid retryOn")
+}
+
+const stepTypeFlaky step.Type = "flaky"
+
+type flakyStepCfg struct {
+	Fails  int
+	failed int
+}
+
+type flakyStep struct {
+	step.Step
+	Cfg *flakyStepCfg
+}
+
+func (f *flakyStep) Start() error {
+	if f.Cfg.failed < f.Cfg.Fails {
+		f.Cfg.failed++
+		return errors.New("flaky")
+	}
+	return nil
+}
+
+func TestTaskRetryStartTime(t *testing.T) {
+	plugin.Reg(stepTypeFlaky, func() step.Step { return &flakyStep{Step: step.CreateBase(string(stepTypeFlaky))} }, func() any { return &flakyStepCfg{} })
+	cfg := NewCfg().Add(stepTypeFlaky, &flakyStepCfg{Fails: 1}).SetID("test-task-retry-start-time").SetType(Type("test"))
+	cfg.StepRetry = &step.RetryCfg{Attempts: 2}
+
+	task := New()
+	task.Cfg = cfg
+	tests.DebugInit(task)
+	require.NoError(t, runner.Init(task))
+	require.NoError(t, runner.Run(task))
+
+	st := task.Steps()[0]
+	require.Equal(t, 2, st.LoadAsInt(consts.FieldAttempts))
+	startTime := st.LoadAsInt(consts.FieldStartTimeNano)
+	require.Positive(t, startTime)
+	require.LessOrEqual(t, int64(startTime), gjson.Get(st.LoadAsString(consts.FieldRetryHistory), "0.startTimeNano").Int())
+}
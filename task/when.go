@@ -0,0 +1,120 @@
+package task
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/interp"
+)
+
+// evalWhen evaluate condition of step like `${steps[0].exit} == 0 && ${values.enabled}`.
+// Operators are ||, &&, ! and ==, != between two operands, parentheses are not supported and && binds tighter.
+// An operand is a string with references, it may be quoted by ' or ", operators in quotes or references are part of operand.
+// A single operand is true unless it is empty, false or 0. Empty condition is true.
+func evalWhen(cond string, r interp.Resolver) (bool, error) {
+	if strings.TrimSpace(cond) == "" {
+		return true, nil
+	}
+
+	for _, or := range splitOp(cond, "||") {
+		all := true
+		for _, and := range splitOp(or, "&&") {
+			ok, err := evalTerm(strings.TrimSpace(and), r)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func evalTerm(term string, r interp.Resolver) (bool, error) {
+	if term == "" {
+		return false, errs.New("empty term in condition")
+	}
+	for _, op := range []string{"==", "!="} {
+		i := indexOp(term, op)
+		if i < 0 {
+			continue
+		}
+		lhs, rhs := term[:i], term[i+len(op):]
+		l, err := evalOperand(lhs, r)
+		if err != nil {
+			return false, err
+		}
+		rr, err := evalOperand(rhs, r)
+		if err != nil {
+			return false, err
+		}
+		return (l == rr) == (op == "=="), nil
+	}
+
+	if neg, found := strings.CutPrefix(term, "!"); found {
+		ok, err := evalTerm(strings.TrimSpace(neg), r)
+		return !ok, err
+	}
+
+	s, err := evalOperand(term, r)
+	if err != nil {
+		return false, err
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b, nil
+	}
+	return s != "", nil
+}
+
+func evalOperand(s string, r interp.Resolver) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return interp.Expand(s, r)
+}
+
+// splitOp split s around op which is not in quotes or references.
+func splitOp(s string, op string) []string {
+	var parts []string
+	for {
+		i := indexOp(s, op)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+len(op):]
+	}
+}
+
+// indexOp return index of the first op in s which is not in quotes or references, or -1.
+func indexOp(s string, op string) int {
+	var (
+		quote byte
+		depth int
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case c == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], op):
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,34 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2024, 2, 28, 23, 59, 30, 0, time.UTC) // Wednesday
+	for expr, want := range map[string]time.Time{
+		"* * * * *":          time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"*/15 * * * *":       time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"30 9 * * MON-FRI":   time.Date(2024, 2, 29, 9, 30, 0, 0, time.UTC),
+		"0 0 1 * *":          time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"0 12 29 2 *":        time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		"0 0 30 2 *":         {},
+		"0 0 13 * 5":         time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":          time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		"5,10 1-3/2 * JAN *": time.Date(2025, 1, 1, 1, 5, 0, 0, time.UTC),
+		"@hourly":            time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		"@weekly":            time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+	} {
+		s, err := Parse(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, want, s.Next(from), expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * FOO *"} {
+		_, err := Parse(expr)
+		require.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,214 @@
+// Package handover pass listening sockets to a new process by file descriptor inheritance,
+// so that a binary can be replaced without refusing connections.
+//
+// The old process records listeners by Listen, starts the new process with a Handover and waits it ready,
+// the new process gets the same sockets by Listen with the same names and reports ready by Ready.
+package handover
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/donkeywon/golib/errs"
+)
+
+const (
+	// EnvListeners is names of inherited listeners separated by comma, their fds start from 3 in order.
+	EnvListeners = "GOLIB_HANDOVER_LISTENERS"
+	// EnvReadyFD is the fd which the new process writes to when it is ready.
+	EnvReadyFD = "GOLIB_HANDOVER_READY_FD"
+
+	readyMsg = "ready"
+)
+
+var (
+	mu        sync.Mutex
+	listeners = make(map[string]net.Listener) // listeners to pass on handover
+	inherited = make(map[string]*os.File)     // listeners inherited from parent and not taken yet
+	readyFile *os.File
+)
+
+func init() {
+	names := os.Getenv(EnvListeners)
+	if names != "" {
+		for i, name := range strings.Split(names, ",") {
+			inherited[name] = os.NewFile(uintptr(3+i), name)
+		}
+	}
+	if fd, err := strconv.Atoi(os.Getenv(EnvReadyFD)); err == nil {
+		readyFile = os.NewFile(uintptr(fd), "handover-ready")
+	}
+	// not passed to processes started by this process
+	os.Unsetenv(EnvListeners)
+	os.Unsetenv(EnvReadyFD)
+}
+
+// Listen return the listener inherited from parent process by name, or listen on addr if not inherited
+// or the inherited one is on another port. The listener is recorded and passed to the new process on handover.
+func Listen(name string, network string, addr string) (net.Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l, err := takeInherited(name, addr)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		l, err = net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	listeners[name] = l
+	return l, nil
+}
+
+func takeInherited(name string, addr string) (net.Listener, error) {
+	f, ok := inherited[name]
+	if !ok {
+		return nil, nil
+	}
+	delete(inherited, name)
+	defer f.Close()
+
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, errs.Wrapf(err, "use inherited listener %s failed", name)
+	}
+	if !samePort(l.Addr(), addr) {
+		l.Close()
+		return nil, nil
+	}
+	return l, nil
+}
+
+func samePort(a net.Addr, addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	_, lport, err := net.SplitHostPort(a.String())
+	return err == nil && port == lport
+}
+
+// Ready tell parent process that this process is ready to serve, it is no-op if not started by handover.
+func Ready() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if readyFile == nil {
+		return nil
+	}
+	_, err := readyFile.WriteString(readyMsg + "\n")
+	readyFile.Close()
+	readyFile = nil
+	if err != nil {
+		return errs.Wrap(err, "write ready failed")
+	}
+	return nil
+}
+
+// Handover carries listeners and ready pipe to the new process.
+type Handover struct {
+	names  []string
+	files  []*os.File
+	readyR *os.File
+	readyW *os.File
+}
+
+// New create a Handover of current recorded listeners.
+func New() (*Handover, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errs.New("handover is not supported on windows")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	h := &Handover{}
+	for name := range listeners {
+		h.names = append(h.names, name)
+	}
+	slices.Sort(h.names)
+	for _, name := range h.names {
+		fl, ok := listeners[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			h.Close()
+			return nil, errs.Errorf("listener %s can not be passed", name)
+		}
+		f, err := fl.File()
+		if err != nil {
+			h.Close()
+			return nil, errs.Wrapf(err, "get file of listener %s failed", name)
+		}
+		h.files = append(h.files, f)
+	}
+
+	var err error
+	h.readyR, h.readyW, err = os.Pipe()
+	if err != nil {
+		h.Close()
+		return nil, errs.Wrap(err, "create ready pipe failed")
+	}
+	return h, nil
+}
+
+// Setup pass listeners and ready pipe to cmd, it must be called before cmd started.
+func (h *Handover) Setup(cmd *exec.Cmd) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.ExtraFiles = append(slices.Clone(h.files), h.readyW)
+	cmd.Env = append(cmd.Env,
+		EnvListeners+"="+strings.Join(h.names, ","),
+		EnvReadyFD+"="+strconv.Itoa(3+len(h.files)))
+}
+
+// WaitReady wait the started process ready, exited is closed when the process exits.
+// Error is returned if the process exits or ctx done before ready.
+func (h *Handover) WaitReady(ctx context.Context, exited <-chan struct{}) error {
+	// only the new process holds the write end now, so read gets EOF if it exits
+	h.readyW.Close()
+
+	readyCh := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(h.readyR).ReadString('\n')
+		if strings.TrimSpace(line) == readyMsg {
+			readyCh <- nil
+			return
+		}
+		if err == nil {
+			err = errs.Errorf("unexpected ready message: %s", line)
+		}
+		readyCh <- errs.Wrap(err, "read ready failed")
+	}()
+
+	select {
+	case err := <-readyCh:
+		return err
+	case <-exited:
+		return errs.New("new process exited before ready")
+	case <-ctx.Done():
+		return errs.Wrap(ctx.Err(), "wait new process ready failed")
+	}
+}
+
+// Close release files of Handover, listeners themselves are not closed.
+func (h *Handover) Close() {
+	for _, f := range h.files {
+		f.Close()
+	}
+	if h.readyR != nil {
+		h.readyR.Close()
+	}
+	if h.readyW != nil {
+		h.readyW.Close()
+	}
+}
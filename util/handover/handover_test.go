@@ -0,0 +1,65 @@
+package handover
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const envTestChildAddr = "HANDOVER_TEST_CHILD_ADDR"
+
+func TestHandover(t *testing.T) {
+	l, err := Listen("test", "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+
+	h, err := New()
+	require.NoError(t, err)
+	defer h.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandoverChild$")
+	cmd.Env = append(os.Environ(), envTestChildAddr+"="+addr)
+	h.Setup(cmd)
+	require.NoError(t, cmd.Start())
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, h.WaitReady(ctx, exited))
+
+	// child serves on the same socket after parent closed it
+	require.NoError(t, l.Close())
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	bs, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "child", string(bs))
+	<-exited
+}
+
+func TestHandoverChild(t *testing.T) {
+	addr := os.Getenv(envTestChildAddr)
+	if addr == "" {
+		t.Skip("only run as child of TestHandover")
+	}
+	l, err := Listen("test", "tcp", addr)
+	require.NoError(t, err)
+	defer l.Close()
+	require.NoError(t, Ready())
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	conn.Write([]byte("child"))
+	conn.Close()
+}
@@ -0,0 +1,37 @@
+package httpu
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// IPAllowed check remote ip of r is in allowedIPs and respond 403 if not, all ips are allowed if allowedIPs is empty.
+func IPAllowed(w http.ResponseWriter, r *http.Request, allowedIPs map[string]struct{}) bool {
+	if len(allowedIPs) == 0 {
+		return true
+	}
+
+	if _, exist := allowedIPs[GetRealRemoteIP(r)]; exist {
+		return true
+	}
+
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	return false
+}
+
+// BasicAuth check basic auth of r and respond 401 if not matched, no auth is required if user and pwd are empty.
+func BasicAuth(w http.ResponseWriter, r *http.Request, user string, pwd string) bool {
+	if user == "" && pwd == "" {
+		return true
+	}
+	u, p, ok := r.BasicAuth()
+	if ok &&
+		subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(p), []byte(pwd)) == 1 {
+		return true
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="Restricted", charset="UTF-8"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	return false
+}
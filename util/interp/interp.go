@@ -0,0 +1,228 @@
+// Package interp expands references like ${values.x}, ${steps[0].stdout} and ${env.X} in strings and cfgs.
+//
+// A reference is a scope name followed by keys and indexes, $${ is an escaped ${.
+// Expanding is strict, a reference which can not be resolved is an error rather than an empty string.
+package interp
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/conv"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/reflects"
+	"github.com/donkeywon/golib/util/secret"
+)
+
+var ErrUnresolved = errors.New("unresolved reference")
+
+var secretType = reflect.TypeFor[secret.String]()
+
+// Resolver resolve a reference without ${ and }.
+type Resolver func(ref string) (string, error)
+
+// Getter get value by key lazily, it can be used as a scope or a value in scope.
+type Getter func(key string) (any, bool)
+
+// Env is a Getter of environment variables.
+var Env Getter = func(key string) (any, bool) {
+	return os.LookupEnv(key)
+}
+
+// NewResolver create a Resolver which resolves references in scopes, e.g. values.x.y resolves
+// scopes["values"]["x"]["y"]. Values can be maps, slices, Getters and strings of JSON.
+func NewResolver(scopes map[string]any) Resolver {
+	return func(ref string) (string, error) {
+		keys, err := ParseRef(ref)
+		if err != nil {
+			return "", err
+		}
+		scope, exists := scopes[keys[0]]
+		if !exists {
+			return "", errs.Errorf("unknown scope: %s", keys[0])
+		}
+		v, ok := lookup(scope, keys[1:])
+		if !ok {
+			return "", ErrUnresolved
+		}
+		return render(v)
+	}
+}
+
+// Expand expand references in s, all unresolved references are reported.
+func Expand(s string, r Resolver) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var (
+		sb    strings.Builder
+		errss []error
+	)
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			sb.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", errs.Errorf("unterminated reference: %s", s[i:])
+		}
+		ref := s[i+2 : i+end]
+		v, err := r(ref)
+		if err != nil {
+			errss = append(errss, errs.Wrapf(err, "${%s}", ref))
+		}
+		sb.WriteString(s[:i] + v)
+		s = s[i+end+1:]
+	}
+	if len(errss) > 0 {
+		return "", errors.Join(errss...)
+	}
+	return sb.String(), nil
+}
+
+// Refs return references in all strings of v, references are not resolved.
+func Refs(v any) ([]string, error) {
+	var refs []string
+	_, err := Copy(v, func(ref string) (string, error) {
+		_, err := ParseRef(ref)
+		refs = append(refs, ref)
+		return "", err
+	})
+	return refs, err
+}
+
+// Copy deep copy v with references in all strings expanded, v is not modified so it can be expanded again.
+// Unexported fields are shallow copied. Secrets are not expanded, they may contain ${ literally.
+func Copy[T any](v T, r Resolver) (T, error) {
+	return reflects.DeepCopyFunc(v, func(t reflect.Type, s string) (string, error) {
+		if t == secretType {
+			return s, nil
+		}
+		return Expand(s, r)
+	})
+}
+
+// ParseRef parse a reference like steps[0].stdout to keys steps, 0 and stdout.
+func ParseRef(ref string) ([]string, error) {
+	var keys []string
+	s := strings.TrimSpace(ref)
+	for s != "" {
+		i := strings.IndexAny(s, ".[")
+		switch {
+		case i < 0:
+			keys = append(keys, s)
+			s = ""
+		case s[i] == '.':
+			keys = append(keys, s[:i])
+			s = s[i+1:]
+			if s == "" {
+				return nil, errs.Errorf("invalid reference: %s", ref)
+			}
+		default:
+			if i > 0 {
+				keys = append(keys, s[:i])
+			}
+			end := strings.IndexByte(s, ']')
+			if end < i {
+				return nil, errs.Errorf("invalid reference: %s", ref)
+			}
+			keys = append(keys, s[i+1:end])
+			s = strings.TrimPrefix(s[end+1:], ".")
+		}
+	}
+	if len(keys) == 0 || slices.Contains(keys, "") {
+		return nil, errs.Errorf("invalid reference: %s", ref)
+	}
+	return keys, nil
+}
+
+func lookup(v any, keys []string) (any, bool) {
+	for _, key := range keys {
+		switch vv := v.(type) {
+		case Getter:
+			var ok bool
+			v, ok = vv(key)
+			if !ok {
+				return nil, false
+			}
+			continue
+		case string:
+			// value stored as string like step result, e.g. stdout of cmd step
+			var decoded any
+			if jsons.UnmarshalString(vv, &decoded) != nil {
+				return nil, false
+			}
+			v = decoded
+		}
+
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			mv := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+			if !mv.IsValid() {
+				return nil, false
+			}
+			v = mv.Interface()
+		case reflect.Slice, reflect.Array:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= rv.Len() {
+				return nil, false
+			}
+			v = rv.Index(idx).Interface()
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// render convert v to string, a list of strings like stdout lines is joined by newline.
+func render(v any) (string, error) {
+	switch vv := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return vv, nil
+	case []string:
+		return strings.Join(vv, "\n"), nil
+	case float64:
+		return strconv.FormatFloat(vv, 'f', -1, 64), nil
+	case []any:
+		lines := make([]string, 0, len(vv))
+		for _, l := range vv {
+			s, ok := l.(string)
+			if !ok {
+				return jsons.MarshalString(vv)
+			}
+			lines = append(lines, s)
+		}
+		return strings.Join(lines, "\n"), nil
+	case map[string]any:
+		return jsons.MarshalString(vv)
+	default:
+		s, err := conv.ToString(v)
+		if err != nil {
+			return jsons.MarshalString(vv)
+		}
+		return s, nil
+	}
+}
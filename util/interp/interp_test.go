@@ -0,0 +1,72 @@
+package interp
+
+import (
+	"testing"
+
+	"github.com/donkeywon/golib/util/secret"
+	"github.com/stretchr/testify/require"
+)
+
+type testCfg struct {
+	Path    string
+	Args    []string
+	Env     map[string]string
+	Cfg     any
+	Nested  *testCfg
+	Secret  secret.String
+	private string
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("INTERP_TEST", "env")
+	r := NewResolver(map[string]any{
+		"values": map[string]any{"x": "1", "n": 2.0, "m": map[string]any{"y": []any{"a", "b"}}},
+		"steps":  []any{map[string]any{"stdout": `["line1","line2"]`}},
+		"env":    Env,
+	})
+
+	s, err := Expand("${values.x}/${values.n}/${values.m.y[1]}/${env.INTERP_TEST}/$${values.x}", r)
+	require.NoError(t, err)
+	require.Equal(t, "1/2/b/env/${values.x}", s)
+
+	s, err = Expand("${steps[0].stdout[1]}", r)
+	require.NoError(t, err)
+	require.Equal(t, "line2", s)
+
+	_, err = Expand("${values.none} ${steps[1].stdout} ${unknown.x}", r)
+	require.ErrorIs(t, err, ErrUnresolved)
+	require.ErrorContains(t, err, "${steps[1].stdout}")
+	require.ErrorContains(t, err, "unknown scope")
+
+	_, err = Expand("${values.x", r)
+	require.Error(t, err)
+}
+
+func TestCopy(t *testing.T) {
+	r := NewResolver(map[string]any{"values": map[string]any{"x": "1"}})
+	cfg := &testCfg{
+		Path:    "/${values.x}",
+		Args:    []string{"${values.x}"},
+		Env:     map[string]string{"X": "${values.x}"},
+		Cfg:     map[string]any{"k": "${values.x}"},
+		Nested:  &testCfg{Path: "${values.x}"},
+		Secret:  "pa${ss",
+		private: "${values.x}",
+	}
+
+	refs, err := Refs(cfg)
+	require.NoError(t, err)
+	require.Len(t, refs, 5)
+
+	c, err := Copy(cfg, r)
+	require.NoError(t, err)
+	require.Equal(t, "/1", c.Path)
+	require.Equal(t, []string{"1"}, c.Args)
+	require.Equal(t, "1", c.Env["X"])
+	require.Equal(t, map[string]any{"k": "1"}, c.Cfg)
+	require.Equal(t, "1", c.Nested.Path)
+	require.Equal(t, "pa${ss", c.Secret.Value())
+	require.Equal(t, "${values.x}", c.private)
+	require.Equal(t, "/${values.x}", cfg.Path)
+	require.Equal(t, "${values.x}", cfg.Cfg.(map[string]any)["k"])
+}
@@ -0,0 +1,91 @@
+package reflects
+
+import (
+	"errors"
+	"reflect"
+)
+
+// DeepCopy deep copy v, unexported fields are shallow copied.
+func DeepCopy[T any](v T) T {
+	nv, _ := DeepCopyFunc(v, nil)
+	return nv
+}
+
+// DeepCopyFunc deep copy v with all strings converted by f, f is called with the type of string,
+// which may be a named string type. v is not modified.
+// Errors of f are joined and v is returned if any.
+func DeepCopyFunc[T any](v T, f func(reflect.Type, string) (string, error)) (T, error) {
+	var errss []error
+	nv := copyValue(reflect.ValueOf(&v).Elem(), f, &errss)
+	if len(errss) > 0 {
+		return v, errors.Join(errss...)
+	}
+	return nv.Interface().(T), nil
+}
+
+func copyValue(v reflect.Value, f func(reflect.Type, string) (string, error), errss *[]error) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		if f == nil {
+			return v
+		}
+		s, err := f(v.Type(), v.String())
+		if err != nil {
+			*errss = append(*errss, err)
+			return v
+		}
+		nv := reflect.New(v.Type()).Elem()
+		nv.SetString(s)
+		return nv
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.New(v.Type().Elem())
+		nv.Elem().Set(copyValue(v.Elem(), f, errss))
+		return nv
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(copyValue(v.Elem(), f, errss))
+		return nv
+	case reflect.Struct:
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(v)
+		for i := range v.NumField() {
+			if nv.Field(i).CanSet() {
+				nv.Field(i).Set(copyValue(v.Field(i), f, errss))
+			}
+		}
+		return nv
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			nv.Index(i).Set(copyValue(v.Index(i), f, errss))
+		}
+		return nv
+	case reflect.Array:
+		nv := reflect.New(v.Type()).Elem()
+		for i := range v.Len() {
+			nv.Index(i).Set(copyValue(v.Index(i), f, errss))
+		}
+		return nv
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			nv.SetMapIndex(iter.Key(), copyValue(iter.Value(), f, errss))
+		}
+		return nv
+	default:
+		return v
+	}
+}
@@ -0,0 +1,133 @@
+package secret
+
+import (
+	"os"
+	"strings"
+
+	"github.com/donkeywon/golib/errs"
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/yamls"
+)
+
+// Redacted is shown instead of a non-empty secret.
+const Redacted = "******"
+
+// Provider resolve a reference to plaintext, ref is the part after scheme://.
+type Provider func(ref string) (string, error)
+
+var _providers = map[string]Provider{
+	"file": fileProvider,
+	"env":  envProvider,
+}
+
+// RegProvider register a Provider for references like scheme://ref, it is not concurrent safe and should be called in init.
+func RegProvider(scheme string, p Provider) {
+	if _, exists := _providers[scheme]; exists {
+		panic("duplicate register secret provider: " + scheme)
+	}
+	_providers[scheme] = p
+}
+
+// String is a secret string, e.g. password, access key and dsn.
+// When unmarshaled from json, yaml, flags or env, references like file:///path/to/file,
+// env://NAME and scheme://ref of registered Provider are resolved to plaintext,
+// other values are taken as plaintext.
+// String is always redacted when printed, logged or marshaled, so cfgs containing it
+// can not be marshaled and then unmarshaled without losing it, use Value to get the plaintext.
+type String string
+
+// Resolve s to plaintext if it is a reference of registered Provider.
+func Resolve(s string) (String, error) {
+	scheme, ref, ok := strings.Cut(s, "://")
+	if !ok {
+		return String(s), nil
+	}
+	p, exists := _providers[scheme]
+	if !exists {
+		return String(s), nil
+	}
+	v, err := p(ref)
+	if err != nil {
+		return "", errs.Wrapf(err, "resolve secret %s:// failed", scheme)
+	}
+	return String(v), nil
+}
+
+func (s String) Value() string {
+	return string(s)
+}
+
+func (s String) String() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+func (s String) GoString() string {
+	return s.String()
+}
+
+func (s String) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s String) MarshalJSON() ([]byte, error) {
+	return jsons.Marshal(s.String())
+}
+
+func (s String) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+func (s *String) UnmarshalText(data []byte) error {
+	return s.resolve(string(data))
+}
+
+func (s *String) UnmarshalJSON(data []byte) error {
+	var v string
+	err := jsons.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	return s.resolve(v)
+}
+
+func (s *String) UnmarshalYAML(data []byte) error {
+	var v string
+	err := yamls.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+	return s.resolve(v)
+}
+
+// UnmarshalFlag implements flags.Unmarshaler, so env and flags are resolved too.
+func (s *String) UnmarshalFlag(value string) error {
+	return s.resolve(value)
+}
+
+func (s *String) resolve(v string) error {
+	r, err := Resolve(v)
+	if err != nil {
+		return err
+	}
+	*s = r
+	return nil
+}
+
+func fileProvider(ref string) (string, error) {
+	bs, err := os.ReadFile(ref)
+	if err != nil {
+		return "", errs.Wrap(err, "read secret file failed")
+	}
+	return strings.TrimRight(string(bs), "\r\n"), nil
+}
+
+func envProvider(ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", errs.Errorf("env %s not exists", ref)
+	}
+	return v, nil
+}
@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/donkeywon/golib/util/jsons"
+	"github.com/donkeywon/golib/util/yamls"
+	"github.com/stretchr/testify/require"
+)
+
+type cfg struct {
+	Pwd String `json:"pwd" yaml:"pwd"`
+}
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwd")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+	t.Setenv("SECRET_TEST_PWD", "from-env")
+	RegProvider("test", func(ref string) (string, error) { return "from-" + ref, nil })
+
+	c := &cfg{}
+	require.NoError(t, jsons.UnmarshalString(`{"pwd":"file://`+path+`"}`, c))
+	require.Equal(t, "from-file", c.Pwd.Value())
+
+	require.NoError(t, yamls.UnmarshalString("pwd: env://SECRET_TEST_PWD", c))
+	require.Equal(t, "from-env", c.Pwd.Value())
+
+	require.NoError(t, c.Pwd.UnmarshalFlag("test://provider"))
+	require.Equal(t, "from-provider", c.Pwd.Value())
+
+	require.NoError(t, c.Pwd.UnmarshalFlag("http://plain"))
+	require.Equal(t, "http://plain", c.Pwd.Value())
+
+	require.Error(t, c.Pwd.UnmarshalFlag("env://SECRET_TEST_NOT_EXISTS"))
+}
+
+func TestRedact(t *testing.T) {
+	c := &cfg{Pwd: "plaintext"}
+	require.Equal(t, `{"pwd":"******"}`, jsons.MustMarshalString(c))
+	require.Equal(t, "pwd: \"******\"\n", yamls.MustMarshalString(c))
+	require.Equal(t, "&{Pwd:******}", fmt.Sprintf("%+v", c))
+	require.Equal(t, "&secret.cfg{Pwd:******}", fmt.Sprintf("%#v", c))
+	require.Equal(t, `{"pwd":""}`, jsons.MustMarshalString(&cfg{}))
+}
+
+func TestWalk(t *testing.T) {
+	type nested struct {
+		Cfgs []any
+		Map  map[string]cfg
+		pwd  String
+	}
+	v := &nested{
+		Cfgs: []any{&cfg{Pwd: "p0"}, cfg{Pwd: "p1"}},
+		Map:  map[string]cfg{"k": {Pwd: "p2"}},
+		pwd:  "unexported",
+	}
+
+	got := map[string]string{}
+	Walk(v, func(path string, s String) String {
+		got[path] = s.Value()
+		return s + "-new"
+	})
+	require.Equal(t, map[string]string{"Cfgs.0.Pwd": "p0", "Cfgs.1.Pwd": "p1", "Map.k.Pwd": "p2"}, got)
+	require.Equal(t, "p0-new", v.Cfgs[0].(*cfg).Pwd.Value())
+	require.Equal(t, "p1-new", v.Cfgs[1].(cfg).Pwd.Value())
+	require.Equal(t, "p2-new", v.Map["k"].Pwd.Value())
+	require.Equal(t, "unexported", v.pwd.Value())
+}
@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var stringType = reflect.TypeFor[String]()
+
+// Walk call f with path and value of every String in v, the value is replaced by the returned one if it is changed.
+// v must be a pointer to replace values, unexported fields are skipped.
+// Path is field names, indexes and map keys joined by dot, e.g. Steps.0.Cfg.Pwd.
+func Walk(v any, f func(path string, s String) String) {
+	walk(reflect.ValueOf(v), "", f)
+}
+
+// walk return whether any String in v is changed.
+func walk(v reflect.Value, path string, f func(string, String) String) bool {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Type() != stringType {
+			return false
+		}
+		s := String(v.String())
+		ns := f(path, s)
+		if ns == s || !v.CanSet() {
+			return false
+		}
+		v.SetString(string(ns))
+		return true
+	case reflect.Pointer:
+		if v.IsNil() {
+			return false
+		}
+		return walk(v.Elem(), path, f)
+	case reflect.Interface:
+		if v.IsNil() {
+			return false
+		}
+		e := v.Elem()
+		if e.Kind() == reflect.Pointer {
+			return walk(e, path, f)
+		}
+		// value in interface is not addressable, walk a copy and set it back if changed
+		cp := reflect.New(e.Type()).Elem()
+		cp.Set(e)
+		if !walk(cp, path, f) || !v.CanSet() {
+			return false
+		}
+		v.Set(cp)
+		return true
+	case reflect.Struct:
+		changed := false
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if field.IsExported() && walk(v.Field(i), join(path, field.Name), f) {
+				changed = true
+			}
+		}
+		return changed
+	case reflect.Slice, reflect.Array:
+		changed := false
+		for i := range v.Len() {
+			if walk(v.Index(i), join(path, strconv.Itoa(i)), f) {
+				changed = true
+			}
+		}
+		return changed
+	case reflect.Map:
+		changed := false
+		iter := v.MapRange()
+		for iter.Next() {
+			cp := reflect.New(v.Type().Elem()).Elem()
+			cp.Set(iter.Value())
+			if walk(cp, join(path, fmt.Sprint(iter.Key().Interface())), f) {
+				v.SetMapIndex(iter.Key(), cp)
+				changed = true
+			}
+		}
+		return changed
+	default:
+		return false
+	}
+}
+
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
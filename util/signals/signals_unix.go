@@ -15,4 +15,7 @@ var (
 	IntSignals = []os.Signal{
 		unix.SIGINT,
 	}
+	ReloadSignals = []os.Signal{
+		unix.SIGHUP,
+	}
 )
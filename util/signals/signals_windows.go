@@ -11,4 +11,5 @@ var (
 	IntSignals = []os.Signal{
 		os.Interrupt,
 	}
+	ReloadSignals []os.Signal
 )